@@ -4,6 +4,14 @@ package main
 # Running
 Usage: ./duckdb-tileserver [ -test ] [ --database-path /path/to/database.db ]
 
+# Exporting tiles
+Usage: ./duckdb-tileserver export --layer roads --maxzoom 14 --out roads.pmtiles
+Writes the tiles of a layer to an MBTiles or PMTiles archive (see export.go)
+
+# Seeding the cache
+Usage: ./duckdb-tileserver seed --layer roads --maxzoom 8
+Generates the tiles of a layer into the disk cache tier (see seed.go)
+
 Browser: e.g. http://localhost:9000/
 
 # Configuration
@@ -59,6 +67,17 @@ func initCommnandOptions() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			runExport(os.Args[1:])
+			return
+		case "seed":
+			runSeed(os.Args[1:])
+			return
+		}
+	}
+
 	getopt.Parse()
 
 	if flagHelp {
@@ -73,12 +92,7 @@ func main() {
 
 	log.Infof("----  %s - Version %s ----------\n", conf.AppConfig.Name, conf.AppConfig.Version)
 
-	conf.InitConfig(flagConfigFilename, flagDebugOn)
-
-	// Set DuckDB parameters from command line if provided
-	if flagDuckDBPath != "" {
-		conf.Configuration.Database.DatabasePath = flagDuckDBPath
-	}
+	initConfig()
 
 	// Set UI disable flag from command line
 	if flagDisableUi {
@@ -89,12 +103,7 @@ func main() {
 		ui.HTMLDynamicLoad = true
 		log.Info("Running in development mode")
 	}
-	// Commandline over-rides config file for debugging
-	if flagDebugOn || conf.Configuration.Server.Debug {
-		log.SetLevel(log.TraceLevel)
-		log.Debugf("Log level = DEBUG\n")
-	}
-	conf.DumpConfig()
+	initLogging()
 
 	//-- Initialize catalog (with DB conn if used)
 	var catalog data.Catalog
@@ -111,3 +120,23 @@ func main() {
 	service.Initialize()
 	service.Serve(catalog)
 }
+
+// initConfig reads the configuration, with command line overrides
+func initConfig() {
+	conf.InitConfig(flagConfigFilename, flagDebugOn)
+
+	// Set DuckDB parameters from command line if provided
+	if flagDuckDBPath != "" {
+		conf.Configuration.Database.DatabasePath = flagDuckDBPath
+	}
+}
+
+// initLogging sets the log level and logs the configuration
+func initLogging() {
+	// Commandline over-rides config file for debugging
+	if flagDebugOn || conf.Configuration.Server.Debug {
+		log.SetLevel(log.TraceLevel)
+		log.Debugf("Log level = DEBUG\n")
+	}
+	conf.DumpConfig()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pborman/getopt/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/archive"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+	"github.com/tobilg/duckdb-tileserver/internal/data"
+)
+
+// runExport runs the export command, which writes the tiles of a layer
+// to an MBTiles or PMTiles archive.
+// Usage: ./duckdb-tileserver export --layer roads --minzoom 0 --maxzoom 14 --bbox ... --out roads.pmtiles
+func runExport(args []string) {
+	var layer, bbox, out string
+	var params []string
+	minZoom, maxZoom, workers := -1, -1, 0
+	var help bool
+
+	set := getopt.New()
+	set.SetProgram(conf.AppConfig.Name + " export")
+	set.SetParameters("")
+	set.FlagLong(&help, "help", '?', "Show command usage")
+	set.FlagLong(&flagConfigFilename, "config", 'c', "", "config file name")
+	set.FlagLong(&flagDebugOn, "debug", 'd', "Set logging level to TRACE")
+	set.FlagLong(&flagDuckDBPath, "database-path", 0, "", "Path to DuckDB database file")
+	set.FlagLong(&layer, "layer", 'l', "Layer to export (comma-separated layers for a composite archive)", "name")
+	set.FlagLong(&out, "out", 'o', "Archive file (.mbtiles or .pmtiles)", "file")
+	set.FlagLong(&minZoom, "minzoom", 0, "Minimum zoom level (default: layer minimum zoom)", "z")
+	set.FlagLong(&maxZoom, "maxzoom", 0, "Maximum zoom level (default: layer maximum zoom)", "z")
+	set.FlagLong(&bbox, "bbox", 0, "Area to export in EPSG:4326 (default: layer bounds)", "minx,miny,maxx,maxy")
+	set.FlagLong(&params, "param", 'p', "Layer parameter value (repeatable)", "name=value")
+	set.FlagLong(&workers, "workers", 0, "Number of tiles generated concurrently (default: number of CPUs)", "n")
+	set.Parse(args)
+
+	if help {
+		set.PrintUsage(os.Stderr)
+		os.Exit(1)
+	}
+	if layer == "" || out == "" {
+		fmt.Fprintln(os.Stderr, "--layer and --out are required")
+		set.PrintUsage(os.Stderr)
+		os.Exit(1)
+	}
+
+	layerParams, err := parseParams(params)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := data.GenerateOptions{
+		Layer:   layer,
+		MinZoom: minZoom,
+		MaxZoom: maxZoom,
+		Params:  layerParams,
+		Workers: workers,
+	}
+	if bbox != "" {
+		bounds, err := parseBbox(bbox)
+		if err != nil {
+			log.Fatalf("Invalid bbox: %v", err)
+		}
+		opts.Bounds = bounds
+	}
+	format, err := archive.FormatFromPath(out)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	initConfig()
+	initLogging()
+
+	catalog := data.CatDBInstance()
+	catalog.SetIncludeExclude(conf.Configuration.Database.TableIncludes, conf.Configuration.Database.TableExcludes)
+	catDB := catalog.(*data.CatalogDB)
+
+	// interrupting the export discards the archive
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var w archive.Writer
+	if format == archive.FormatMBTiles {
+		w, err = archive.NewMBTilesWriter(ctx, catDB.GetDB(), out)
+	} else {
+		w, err = archive.NewPMTilesWriter(out)
+	}
+	if err != nil {
+		log.Fatalf("Error creating archive %s: %v", out, err)
+	}
+
+	written, err := catDB.ExportTiles(ctx, opts, w)
+	if err != nil {
+		w.Abort()
+		catDB.Close()
+		log.Fatalf("Export failed: %v", err)
+	}
+	catDB.Close()
+	log.Infof("Wrote %d tiles to %s", written, out)
+}
+
+// parseBbox parses a bounding box given as minx,miny,maxx,maxy
+func parseBbox(bbox string) (*data.Extent, error) {
+	parts := strings.Split(bbox, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("expected 4 values, got %d", len(parts))
+	}
+	var values [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	if values[0] > values[2] || values[1] > values[3] {
+		return nil, fmt.Errorf("minimum greater than maximum")
+	}
+	return &data.Extent{Minx: values[0], Miny: values[1], Maxx: values[2], Maxy: values[3]}, nil
+}
+
+// parseParams parses layer parameter values given as name=value
+func parseParams(params []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, param := range params {
+		name, value, found := strings.Cut(param, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid parameter %q (expected name=value)", param)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,161 @@
+// Package archive reads and writes tile archives (MBTiles and PMTiles)
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Supported archive formats
+const (
+	FormatMBTiles = "mbtiles"
+	FormatPMTiles = "pmtiles"
+)
+
+// Metadata describes the tiles of an archive.
+// Bounds and center are in longitude/latitude (EPSG:4326).
+type Metadata struct {
+	Name         string
+	Description  string
+	Version      string
+	MinZoom      int
+	MaxZoom      int
+	Bounds       []float64       // minlon, minlat, maxlon, maxlat
+	Center       []float64       // lon, lat, zoom
+	VectorLayers json.RawMessage // TileJSON vector_layers
+}
+
+// Writer writes the tiles of an archive.
+// Tiles can be written in any order, the archive is complete once Close has been called.
+type Writer interface {
+	// WriteTile stores an (uncompressed) MVT tile in XYZ tile coordinates
+	WriteTile(z, x, y int, tile []byte) error
+	// Close writes the metadata and finalizes the archive
+	Close(meta *Metadata) error
+	// Abort discards the archive
+	Abort() error
+}
+
+// Reader reads the tiles of an archive.
+// Readers are safe for concurrent use.
+type Reader interface {
+	// Tile returns the (uncompressed) MVT tile in XYZ tile coordinates, or nil if the archive has no such tile
+	Tile(ctx context.Context, z, x, y int) ([]byte, error)
+	// GzipTile returns the MVT tile compressed with gzip, or nil if the archive has no such tile.
+	// Tiles stored with gzip compression are returned as stored.
+	GzipTile(ctx context.Context, z, x, y int) ([]byte, error)
+	// Metadata returns the metadata of the archive
+	Metadata() *Metadata
+	// Close closes the archive
+	Close() error
+}
+
+// Open opens a tile archive for reading.
+// The format is given by the file extension.
+// MBTiles archives are read through the DuckDB database db.
+func Open(ctx context.Context, db *sql.DB, path string) (Reader, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatMBTiles {
+		return OpenMBTiles(ctx, db, path)
+	}
+	return OpenPMTiles(path)
+}
+
+// FormatFromPath returns the archive format matching the extension of a file
+func FormatFromPath(path string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".mbtiles":
+		return FormatMBTiles, nil
+	case ".pmtiles":
+		return FormatPMTiles, nil
+	default:
+		return "", fmt.Errorf("unsupported archive format: %q (expected .mbtiles or .pmtiles)", ext)
+	}
+}
+
+// metadataJSON returns the metadata as a JSON object
+// (the format of the PMTiles metadata and of the MBTiles "json" metadata row)
+func (meta *Metadata) metadataJSON() map[string]interface{} {
+	content := map[string]interface{}{
+		"name":    meta.Name,
+		"format":  "pbf",
+		"type":    "overlay",
+		"minzoom": meta.MinZoom,
+		"maxzoom": meta.MaxZoom,
+	}
+	if meta.Description != "" {
+		content["description"] = meta.Description
+	}
+	if meta.Version != "" {
+		content["version"] = meta.Version
+	}
+	if len(meta.VectorLayers) > 0 {
+		content["vector_layers"] = meta.VectorLayers
+	}
+	return content
+}
+
+// parseMetadataJSON reads the metadata fields of a JSON object
+// (the PMTiles metadata, or the MBTiles "json" metadata row)
+func (meta *Metadata) parseMetadataJSON(content []byte) error {
+	var fields struct {
+		Name         string          `json:"name"`
+		Description  string          `json:"description"`
+		Version      string          `json:"version"`
+		VectorLayers json.RawMessage `json:"vector_layers"`
+	}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return err
+	}
+	if fields.Name != "" {
+		meta.Name = fields.Name
+	}
+	if fields.Description != "" {
+		meta.Description = fields.Description
+	}
+	if fields.Version != "" {
+		meta.Version = fields.Version
+	}
+	if len(fields.VectorLayers) > 0 {
+		meta.VectorLayers = fields.VectorLayers
+	}
+	return nil
+}
+
+// gzipBytes compresses data with gzip
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzipBytes decompresses gzip data
+func gunzipBytes(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// isGzip tests whether data is gzip compressed
+func isGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
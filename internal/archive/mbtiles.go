@@ -0,0 +1,334 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MBTiles archives are SQLite databases.
+// They are accessed through DuckDB with the sqlite extension,
+// attached to the DuckDB database under a unique alias.
+// See https://github.com/mapbox/mbtiles-spec/blob/master/1.3/spec.md
+
+// attachCount makes the aliases of attached archives unique
+var attachCount atomic.Int64
+
+// MBTilesWriter writes an MBTiles archive
+type MBTilesWriter struct {
+	path  string
+	alias string
+	conn  *sql.Conn
+	tx    *sql.Tx
+}
+
+// NewMBTilesWriter creates an MBTiles archive writer.
+// An existing file at path is replaced.
+func NewMBTilesWriter(ctx context.Context, db *sql.DB, path string) (*MBTilesWriter, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// use a dedicated connection, to write all tiles in a single transaction
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mw := &MBTilesWriter{
+		path:  path,
+		alias: "mbtiles_" + strconv.FormatInt(attachCount.Add(1), 10),
+		conn:  conn,
+	}
+	if err := attachSQLite(ctx, conn, path, mw.alias, false); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE %s.metadata (name TEXT, value TEXT)", mw.alias),
+		fmt.Sprintf("CREATE TABLE %s.tiles (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_data BLOB)", mw.alias),
+	}
+	for _, stmt := range stmts {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			mw.Abort()
+			return nil, fmt.Errorf("error creating MBTiles archive: %w", err)
+		}
+	}
+
+	mw.tx, err = conn.BeginTx(ctx, nil)
+	if err != nil {
+		mw.Abort()
+		return nil, err
+	}
+	return mw, nil
+}
+
+// WriteTile stores a tile in the archive (empty tiles are omitted)
+func (mw *MBTilesWriter) WriteTile(z, x, y int, tile []byte) error {
+	if len(tile) == 0 {
+		return nil
+	}
+	compressed, err := gzipBytes(tile)
+	if err != nil {
+		return err
+	}
+	// MBTiles uses the TMS tiling scheme (y axis pointing up)
+	row := (1 << uint(z)) - 1 - y
+	_, err = mw.tx.Exec(fmt.Sprintf("INSERT INTO %s.tiles VALUES (?, ?, ?, ?)", mw.alias), z, x, row, compressed)
+	return err
+}
+
+// Close writes the metadata and closes the archive
+func (mw *MBTilesWriter) Close(meta *Metadata) error {
+	rows := [][2]string{
+		{"name", meta.Name},
+		{"format", "pbf"},
+		{"type", "overlay"},
+		{"minzoom", strconv.Itoa(meta.MinZoom)},
+		{"maxzoom", strconv.Itoa(meta.MaxZoom)},
+	}
+	if meta.Description != "" {
+		rows = append(rows, [2]string{"description", meta.Description})
+	}
+	if meta.Version != "" {
+		rows = append(rows, [2]string{"version", meta.Version})
+	}
+	if len(meta.Bounds) == 4 {
+		rows = append(rows, [2]string{"bounds", joinFloats(meta.Bounds)})
+	}
+	if len(meta.Center) == 3 {
+		rows = append(rows, [2]string{"center", joinFloats(meta.Center)})
+	}
+	if len(meta.VectorLayers) > 0 {
+		content, err := json.Marshal(map[string]interface{}{"vector_layers": meta.VectorLayers})
+		if err != nil {
+			mw.Abort()
+			return err
+		}
+		rows = append(rows, [2]string{"json", string(content)})
+	}
+	for _, row := range rows {
+		if _, err := mw.tx.Exec(fmt.Sprintf("INSERT INTO %s.metadata VALUES (?, ?)", mw.alias), row[0], row[1]); err != nil {
+			mw.Abort()
+			return err
+		}
+	}
+	if err := mw.tx.Commit(); err != nil {
+		mw.Abort()
+		return err
+	}
+	mw.tx = nil
+
+	ctx := context.Background()
+	// the index name can't be qualified, it is created in the schema of the table
+	index := fmt.Sprintf("CREATE UNIQUE INDEX tile_index ON %s.tiles (zoom_level, tile_column, tile_row)", mw.alias)
+	if _, err := mw.conn.ExecContext(ctx, index); err != nil {
+		mw.Abort()
+		return fmt.Errorf("error creating MBTiles tile index: %w", err)
+	}
+	return mw.detach()
+}
+
+// Abort discards the archive
+func (mw *MBTilesWriter) Abort() error {
+	if mw.tx != nil {
+		mw.tx.Rollback()
+		mw.tx = nil
+	}
+	err := mw.detach()
+	if errRemove := os.Remove(mw.path); errRemove != nil && !errors.Is(errRemove, fs.ErrNotExist) {
+		return errRemove
+	}
+	return err
+}
+
+// detach detaches the archive and releases the connection
+func (mw *MBTilesWriter) detach() error {
+	if mw.conn == nil {
+		return nil
+	}
+	_, err := mw.conn.ExecContext(context.Background(), "DETACH "+mw.alias)
+	mw.conn.Close()
+	mw.conn = nil
+	return err
+}
+
+// MBTilesReader reads the tiles of an MBTiles archive
+type MBTilesReader struct {
+	db    *sql.DB
+	alias string
+	meta  *Metadata
+}
+
+// OpenMBTiles opens an MBTiles archive through the DuckDB database db
+func OpenMBTiles(ctx context.Context, db *sql.DB, path string) (*MBTilesReader, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	mr := &MBTilesReader{
+		db:    db,
+		alias: "mbtiles_" + strconv.FormatInt(attachCount.Add(1), 10),
+	}
+	if err := attachSQLite(ctx, db, path, mr.alias, true); err != nil {
+		return nil, err
+	}
+	meta, err := mr.readMetadata(ctx)
+	if err != nil {
+		mr.Close()
+		return nil, fmt.Errorf("error reading MBTiles metadata %s: %w", path, err)
+	}
+	if meta.Name == "" {
+		meta.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	mr.meta = meta
+	return mr, nil
+}
+
+// readMetadata reads the metadata table of the archive
+func (mr *MBTilesReader) readMetadata(ctx context.Context) (*Metadata, error) {
+	rows, err := mr.db.QueryContext(ctx, fmt.Sprintf("SELECT name, value FROM %s.metadata", mr.alias))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	meta := &Metadata{MinZoom: 0, MaxZoom: 22}
+	for rows.Next() {
+		var name, value sql.NullString
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		switch name.String {
+		case "name":
+			meta.Name = value.String
+		case "description":
+			meta.Description = value.String
+		case "version":
+			meta.Version = value.String
+		case "minzoom":
+			if z, err := strconv.Atoi(value.String); err == nil {
+				meta.MinZoom = z
+			}
+		case "maxzoom":
+			if z, err := strconv.Atoi(value.String); err == nil {
+				meta.MaxZoom = z
+			}
+		case "bounds":
+			if bounds, err := splitFloats(value.String, 4); err == nil {
+				meta.Bounds = bounds
+			}
+		case "center":
+			if center, err := splitFloats(value.String, 3); err == nil {
+				meta.Center = center
+			}
+		case "json":
+			if err := meta.parseMetadataJSON([]byte(value.String)); err != nil {
+				log.Warnf("Invalid MBTiles json metadata: %v", err)
+			}
+		}
+	}
+	return meta, rows.Err()
+}
+
+// Tile returns a tile of the archive, or nil if the archive has no such tile
+func (mr *MBTilesReader) Tile(ctx context.Context, z, x, y int) ([]byte, error) {
+	tile, err := mr.storedTile(ctx, z, x, y)
+	if err != nil || !isGzip(tile) {
+		return tile, err
+	}
+	return gunzipBytes(tile)
+}
+
+// GzipTile returns a tile of the archive compressed with gzip, or nil if the archive has no such tile
+func (mr *MBTilesReader) GzipTile(ctx context.Context, z, x, y int) ([]byte, error) {
+	tile, err := mr.storedTile(ctx, z, x, y)
+	if err != nil || tile == nil || isGzip(tile) {
+		return tile, err
+	}
+	return gzipBytes(tile)
+}
+
+// storedTile returns the tile data as stored in the archive (usually gzip compressed)
+func (mr *MBTilesReader) storedTile(ctx context.Context, z, x, y int) ([]byte, error) {
+	// MBTiles uses the TMS tiling scheme (y axis pointing up)
+	row := (1 << uint(z)) - 1 - y
+	query := fmt.Sprintf("SELECT tile_data FROM %s.tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?", mr.alias)
+
+	var tile []byte
+	err := mr.db.QueryRowContext(ctx, query, z, x, row).Scan(&tile)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tile, nil
+}
+
+// Metadata returns the metadata of the archive
+func (mr *MBTilesReader) Metadata() *Metadata {
+	return mr.meta
+}
+
+// Close detaches the archive
+func (mr *MBTilesReader) Close() error {
+	_, err := mr.db.Exec("DETACH " + mr.alias)
+	return err
+}
+
+// execer executes statements on a database or connection
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// attachSQLite attaches a SQLite database file to DuckDB
+func attachSQLite(ctx context.Context, conn execer, path string, alias string, readOnly bool) error {
+	if _, err := conn.ExecContext(ctx, "INSTALL sqlite; LOAD sqlite;"); err != nil {
+		return fmt.Errorf("error loading sqlite extension: %w", err)
+	}
+	options := "TYPE SQLITE"
+	if readOnly {
+		options += ", READ_ONLY"
+	}
+	attach := fmt.Sprintf("ATTACH '%s' AS %s (%s)", strings.ReplaceAll(path, "'", "''"), alias, options)
+	if _, err := conn.ExecContext(ctx, attach); err != nil {
+		return fmt.Errorf("error opening MBTiles archive %s: %w", path, err)
+	}
+	return nil
+}
+
+// joinFloats formats numbers as a comma-separated list
+func joinFloats(values []float64) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// splitFloats parses a comma-separated list of n numbers
+func splitFloats(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d values, got %d", n, len(parts))
+	}
+	values := make([]float64, n)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
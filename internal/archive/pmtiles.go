@@ -0,0 +1,646 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// PMTiles v3 format constants
+// See https://github.com/protomaps/PMTiles/blob/main/spec/v3/spec.md
+const (
+	pmtilesHeaderLength = 127
+	// the header and the root directory must fit in the first 16 KiB of the archive
+	pmtilesRootMaxLength = 16384 - pmtilesHeaderLength
+
+	pmtilesCompressionNone = 1
+	pmtilesCompressionGzip = 2
+	pmtilesTileTypeMVT     = 1
+
+	// pmtilesMaxDepth limits the nesting of leaf directories
+	pmtilesMaxDepth = 4
+	// pmtilesLeafCacheSize is the number of leaf directories kept in memory by a reader
+	pmtilesLeafCacheSize = 64
+)
+
+var pmtilesMagic = []byte("PMTiles")
+
+// pmtilesHeader is the fixed size header of a PMTiles archive
+type pmtilesHeader struct {
+	RootOffset          uint64
+	RootLength          uint64
+	MetadataOffset      uint64
+	MetadataLength      uint64
+	LeafDirsOffset      uint64
+	LeafDirsLength      uint64
+	TileDataOffset      uint64
+	TileDataLength      uint64
+	AddressedTiles      uint64
+	TileEntries         uint64
+	TileContents        uint64
+	Clustered           bool
+	InternalCompression uint8
+	TileCompression     uint8
+	TileType            uint8
+	MinZoom             uint8
+	MaxZoom             uint8
+	MinLonE7            int32
+	MinLatE7            int32
+	MaxLonE7            int32
+	MaxLatE7            int32
+	CenterZoom          uint8
+	CenterLonE7         int32
+	CenterLatE7         int32
+}
+
+// pmtilesEntry is a directory entry.
+// Entries with a run length of 0 point to a leaf directory.
+type pmtilesEntry struct {
+	TileID    uint64
+	Offset    uint64
+	Length    uint32
+	RunLength uint32
+}
+
+// PMTilesWriter writes a PMTiles v3 archive.
+// Tiles are spooled to a temporary file, and written in tile id order
+// (clustered, with identical tiles stored once) when the archive is closed.
+type PMTilesWriter struct {
+	path   string
+	spool  *os.File
+	size   uint64
+	tiles  []pmtilesEntry // offsets in the spool file
+	stored map[[sha256.Size]byte]int
+}
+
+// NewPMTilesWriter creates a PMTiles archive writer
+func NewPMTilesWriter(path string) (*PMTilesWriter, error) {
+	spool, err := os.CreateTemp(filepath.Dir(path), ".pmtiles-*")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create temporary file: %w", err)
+	}
+	return &PMTilesWriter{
+		path:   path,
+		spool:  spool,
+		stored: make(map[[sha256.Size]byte]int),
+	}, nil
+}
+
+// WriteTile stores a tile in the archive (empty tiles are omitted)
+func (pw *PMTilesWriter) WriteTile(z, x, y int, tile []byte) error {
+	if len(tile) == 0 {
+		return nil
+	}
+	tileID := ZxyToTileID(z, x, y)
+
+	// identical tiles (e.g. ocean or land tiles) are stored once
+	hash := sha256.Sum256(tile)
+	if i, ok := pw.stored[hash]; ok {
+		stored := pw.tiles[i]
+		pw.tiles = append(pw.tiles, pmtilesEntry{TileID: tileID, Offset: stored.Offset, Length: stored.Length, RunLength: 1})
+		return nil
+	}
+
+	compressed, err := gzipBytes(tile)
+	if err != nil {
+		return err
+	}
+	if _, err := pw.spool.Write(compressed); err != nil {
+		return err
+	}
+	pw.stored[hash] = len(pw.tiles)
+	pw.tiles = append(pw.tiles, pmtilesEntry{TileID: tileID, Offset: pw.size, Length: uint32(len(compressed)), RunLength: 1})
+	pw.size += uint64(len(compressed))
+	return nil
+}
+
+// Close writes the archive
+func (pw *PMTilesWriter) Close(meta *Metadata) error {
+	defer pw.Abort()
+
+	sort.Slice(pw.tiles, func(i, j int) bool {
+		return pw.tiles[i].TileID < pw.tiles[j].TileID
+	})
+
+	// lay out the tile data in tile id order
+	var entries []pmtilesEntry
+	var contents []pmtilesEntry // spool offset and length of the tile data, in output order
+	dataOffsets := make(map[uint64]uint64)
+	var dataLength uint64
+	var addressed uint64
+	for _, tile := range pw.tiles {
+		if i := len(entries) - 1; i >= 0 && tile.TileID == entries[i].TileID+uint64(entries[i].RunLength) {
+			if offset, ok := dataOffsets[tile.Offset]; ok && offset == entries[i].Offset {
+				entries[i].RunLength++
+				addressed++
+				continue
+			}
+		}
+		offset, ok := dataOffsets[tile.Offset]
+		if !ok {
+			offset = dataLength
+			dataOffsets[tile.Offset] = offset
+			dataLength += uint64(tile.Length)
+			contents = append(contents, tile)
+		}
+		entries = append(entries, pmtilesEntry{TileID: tile.TileID, Offset: offset, Length: tile.Length, RunLength: 1})
+		addressed++
+	}
+
+	rootDir, leafDirs, err := buildDirectories(entries)
+	if err != nil {
+		return err
+	}
+	metaJSON, err := json.Marshal(meta.metadataJSON())
+	if err != nil {
+		return err
+	}
+	metadata, err := gzipBytes(metaJSON)
+	if err != nil {
+		return err
+	}
+
+	header := pmtilesHeader{
+		RootOffset:          pmtilesHeaderLength,
+		RootLength:          uint64(len(rootDir)),
+		MetadataOffset:      pmtilesHeaderLength + uint64(len(rootDir)),
+		MetadataLength:      uint64(len(metadata)),
+		TileDataLength:      dataLength,
+		AddressedTiles:      addressed,
+		TileEntries:         uint64(len(entries)),
+		TileContents:        uint64(len(contents)),
+		Clustered:           true,
+		InternalCompression: pmtilesCompressionGzip,
+		TileCompression:     pmtilesCompressionGzip,
+		TileType:            pmtilesTileTypeMVT,
+		MinZoom:             uint8(meta.MinZoom),
+		MaxZoom:             uint8(meta.MaxZoom),
+	}
+	header.LeafDirsOffset = header.MetadataOffset + header.MetadataLength
+	header.LeafDirsLength = uint64(len(leafDirs))
+	header.TileDataOffset = header.LeafDirsOffset + header.LeafDirsLength
+	if len(meta.Bounds) == 4 {
+		header.MinLonE7, header.MinLatE7 = toE7(meta.Bounds[0]), toE7(meta.Bounds[1])
+		header.MaxLonE7, header.MaxLatE7 = toE7(meta.Bounds[2]), toE7(meta.Bounds[3])
+	}
+	if len(meta.Center) == 3 {
+		header.CenterLonE7, header.CenterLatE7 = toE7(meta.Center[0]), toE7(meta.Center[1])
+		header.CenterZoom = uint8(meta.Center[2])
+	}
+
+	out, err := os.Create(pw.path)
+	if err != nil {
+		return err
+	}
+	err = pw.writeArchive(out, [][]byte{header.serialize(), rootDir, metadata, leafDirs}, contents)
+	if errClose := out.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		os.Remove(pw.path)
+	}
+	return err
+}
+
+// writeArchive writes the header and directory sections, followed by the tile data copied from the spool file
+func (pw *PMTilesWriter) writeArchive(out io.Writer, sections [][]byte, contents []pmtilesEntry) error {
+	for _, section := range sections {
+		if _, err := out.Write(section); err != nil {
+			return err
+		}
+	}
+	for _, content := range contents {
+		tile := io.NewSectionReader(pw.spool, int64(content.Offset), int64(content.Length))
+		if _, err := io.Copy(out, tile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Abort discards the tiles written so far
+func (pw *PMTilesWriter) Abort() error {
+	if pw.spool == nil {
+		return nil
+	}
+	pw.spool.Close()
+	err := os.Remove(pw.spool.Name())
+	pw.spool = nil
+	return err
+}
+
+// PMTilesReader reads the tiles of a PMTiles v3 archive
+type PMTilesReader struct {
+	path   string
+	file   *os.File
+	header *pmtilesHeader
+	root   []pmtilesEntry
+	leaves *lru.Cache[uint64, []pmtilesEntry]
+	meta   *Metadata
+}
+
+// OpenPMTiles opens a PMTiles archive
+func OpenPMTiles(path string) (*PMTilesReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	pr := &PMTilesReader{path: path, file: file}
+	if err := pr.load(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading PMTiles archive %s: %w", path, err)
+	}
+	return pr, nil
+}
+
+// load reads the header, root directory and metadata of the archive
+func (pr *PMTilesReader) load() error {
+	buf := make([]byte, pmtilesHeaderLength)
+	if _, err := pr.file.ReadAt(buf, 0); err != nil {
+		return err
+	}
+	header, err := deserializePMTilesHeader(buf)
+	if err != nil {
+		return err
+	}
+	if header.TileType != pmtilesTileTypeMVT {
+		return fmt.Errorf("unsupported tile type %d (only MVT tiles are supported)", header.TileType)
+	}
+	if header.TileCompression != pmtilesCompressionNone && header.TileCompression != pmtilesCompressionGzip {
+		return fmt.Errorf("unsupported tile compression %d", header.TileCompression)
+	}
+	pr.header = header
+
+	rootDir, err := pr.read(header.RootOffset, header.RootLength)
+	if err != nil {
+		return err
+	}
+	if pr.root, err = deserializeEntries(rootDir, header.InternalCompression); err != nil {
+		return err
+	}
+	if pr.leaves, err = lru.New[uint64, []pmtilesEntry](pmtilesLeafCacheSize); err != nil {
+		return err
+	}
+
+	pr.meta = &Metadata{
+		Name:    strings.TrimSuffix(filepath.Base(pr.path), filepath.Ext(pr.path)),
+		MinZoom: int(header.MinZoom),
+		MaxZoom: int(header.MaxZoom),
+		Bounds: []float64{
+			fromE7(header.MinLonE7), fromE7(header.MinLatE7),
+			fromE7(header.MaxLonE7), fromE7(header.MaxLatE7),
+		},
+		Center: []float64{fromE7(header.CenterLonE7), fromE7(header.CenterLatE7), float64(header.CenterZoom)},
+	}
+	if header.MetadataLength > 0 {
+		content, err := pr.read(header.MetadataOffset, header.MetadataLength)
+		if err != nil {
+			return err
+		}
+		if content, err = decompress(content, header.InternalCompression); err != nil {
+			return err
+		}
+		if err := pr.meta.parseMetadataJSON(content); err != nil {
+			return fmt.Errorf("invalid metadata: %w", err)
+		}
+	}
+	return nil
+}
+
+// Tile returns a tile of the archive, or nil if the archive has no such tile
+func (pr *PMTilesReader) Tile(ctx context.Context, z, x, y int) ([]byte, error) {
+	tile, err := pr.storedTile(z, x, y)
+	if err != nil || tile == nil {
+		return nil, err
+	}
+	return decompress(tile, pr.header.TileCompression)
+}
+
+// GzipTile returns a tile of the archive compressed with gzip, or nil if the archive has no such tile
+func (pr *PMTilesReader) GzipTile(ctx context.Context, z, x, y int) ([]byte, error) {
+	tile, err := pr.storedTile(z, x, y)
+	if err != nil || tile == nil || pr.header.TileCompression == pmtilesCompressionGzip {
+		return tile, err
+	}
+	if tile, err = decompress(tile, pr.header.TileCompression); err != nil {
+		return nil, err
+	}
+	return gzipBytes(tile)
+}
+
+// storedTile returns the tile data as stored in the archive (compressed with the tile compression)
+func (pr *PMTilesReader) storedTile(z, x, y int) ([]byte, error) {
+	if z < int(pr.header.MinZoom) || z > int(pr.header.MaxZoom) {
+		return nil, nil
+	}
+	tileID := ZxyToTileID(z, x, y)
+
+	entries := pr.root
+	for depth := 0; depth < pmtilesMaxDepth; depth++ {
+		entry, ok := findEntry(entries, tileID)
+		if !ok {
+			return nil, nil
+		}
+		if entry.RunLength > 0 {
+			return pr.read(pr.header.TileDataOffset+entry.Offset, uint64(entry.Length))
+		}
+
+		leafOffset := pr.header.LeafDirsOffset + entry.Offset
+		leaf, ok := pr.leaves.Get(leafOffset)
+		if !ok {
+			leafDir, err := pr.read(leafOffset, uint64(entry.Length))
+			if err != nil {
+				return nil, err
+			}
+			if leaf, err = deserializeEntries(leafDir, pr.header.InternalCompression); err != nil {
+				return nil, err
+			}
+			pr.leaves.Add(leafOffset, leaf)
+		}
+		entries = leaf
+	}
+	return nil, errors.New("too many nested leaf directories")
+}
+
+// Metadata returns the metadata of the archive
+func (pr *PMTilesReader) Metadata() *Metadata {
+	return pr.meta
+}
+
+// Close closes the archive
+func (pr *PMTilesReader) Close() error {
+	return pr.file.Close()
+}
+
+// read reads a section of the archive
+func (pr *PMTilesReader) read(offset uint64, length uint64) ([]byte, error) {
+	buf := make([]byte, length)
+	if _, err := pr.file.ReadAt(buf, int64(offset)); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// findEntry finds the directory entry containing a tile id:
+// the entry of the tile (or of a run of tiles including it),
+// or the entry of the leaf directory which may contain it
+func findEntry(entries []pmtilesEntry, tileID uint64) (pmtilesEntry, bool) {
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].TileID > tileID
+	}) - 1
+	if i < 0 {
+		return pmtilesEntry{}, false
+	}
+	entry := entries[i]
+	if entry.RunLength == 0 || tileID-entry.TileID < uint64(entry.RunLength) {
+		return entry, true
+	}
+	return pmtilesEntry{}, false
+}
+
+// decompress decompresses data compressed with a PMTiles compression type
+func decompress(data []byte, compression uint8) ([]byte, error) {
+	switch compression {
+	case pmtilesCompressionNone:
+		return data, nil
+	case pmtilesCompressionGzip:
+		return gunzipBytes(data)
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", compression)
+	}
+}
+
+// buildDirectories serializes the root directory,
+// splitting the entries into leaf directories if they don't fit in the root directory
+func buildDirectories(entries []pmtilesEntry) ([]byte, []byte, error) {
+	if len(entries) < 16384 {
+		root, err := serializeEntries(entries)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(root) <= pmtilesRootMaxLength {
+			return root, nil, nil
+		}
+	}
+
+	leafSize := math.Max(4096, float64(len(entries))/3500)
+	for {
+		var rootEntries []pmtilesEntry
+		var leaves []byte
+		for i := 0; i < len(entries); i += int(leafSize) {
+			leaf, err := serializeEntries(entries[i:min(i+int(leafSize), len(entries))])
+			if err != nil {
+				return nil, nil, err
+			}
+			rootEntries = append(rootEntries, pmtilesEntry{TileID: entries[i].TileID, Offset: uint64(len(leaves)), Length: uint32(len(leaf))})
+			leaves = append(leaves, leaf...)
+		}
+		root, err := serializeEntries(rootEntries)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(root) <= pmtilesRootMaxLength {
+			return root, leaves, nil
+		}
+		leafSize *= 1.2
+	}
+}
+
+// serializeEntries encodes a directory (gzip compressed)
+func serializeEntries(entries []pmtilesEntry) ([]byte, error) {
+	var buf []byte
+	buf = binary.AppendUvarint(buf, uint64(len(entries)))
+	var lastID uint64
+	for _, entry := range entries {
+		buf = binary.AppendUvarint(buf, entry.TileID-lastID)
+		lastID = entry.TileID
+	}
+	for _, entry := range entries {
+		buf = binary.AppendUvarint(buf, uint64(entry.RunLength))
+	}
+	for _, entry := range entries {
+		buf = binary.AppendUvarint(buf, uint64(entry.Length))
+	}
+	for i, entry := range entries {
+		// 0 means the data directly follows the data of the previous entry
+		if i > 0 && entry.Offset == entries[i-1].Offset+uint64(entries[i-1].Length) {
+			buf = binary.AppendUvarint(buf, 0)
+		} else {
+			buf = binary.AppendUvarint(buf, entry.Offset+1)
+		}
+	}
+	return gzipBytes(buf)
+}
+
+// deserializeEntries decodes a directory compressed with the given compression
+func deserializeEntries(data []byte, compression uint8) ([]pmtilesEntry, error) {
+	data, err := decompress(data, compression)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(data)
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > math.MaxInt32 {
+		return nil, errors.New("invalid directory")
+	}
+	entries := make([]pmtilesEntry, count)
+	var lastID uint64
+	for i := range entries {
+		delta, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		lastID += delta
+		entries[i].TileID = lastID
+	}
+	for i := range entries {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		entries[i].RunLength = uint32(v)
+	}
+	for i := range entries {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		entries[i].Length = uint32(v)
+	}
+	for i := range entries {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if v == 0 && i > 0 {
+			entries[i].Offset = entries[i-1].Offset + uint64(entries[i-1].Length)
+		} else {
+			entries[i].Offset = v - 1
+		}
+	}
+	return entries, nil
+}
+
+// serialize encodes the header
+func (h *pmtilesHeader) serialize() []byte {
+	buf := make([]byte, pmtilesHeaderLength)
+	copy(buf, pmtilesMagic)
+	buf[7] = 3
+	le := binary.LittleEndian
+	le.PutUint64(buf[8:], h.RootOffset)
+	le.PutUint64(buf[16:], h.RootLength)
+	le.PutUint64(buf[24:], h.MetadataOffset)
+	le.PutUint64(buf[32:], h.MetadataLength)
+	le.PutUint64(buf[40:], h.LeafDirsOffset)
+	le.PutUint64(buf[48:], h.LeafDirsLength)
+	le.PutUint64(buf[56:], h.TileDataOffset)
+	le.PutUint64(buf[64:], h.TileDataLength)
+	le.PutUint64(buf[72:], h.AddressedTiles)
+	le.PutUint64(buf[80:], h.TileEntries)
+	le.PutUint64(buf[88:], h.TileContents)
+	if h.Clustered {
+		buf[96] = 1
+	}
+	buf[97] = h.InternalCompression
+	buf[98] = h.TileCompression
+	buf[99] = h.TileType
+	buf[100] = h.MinZoom
+	buf[101] = h.MaxZoom
+	le.PutUint32(buf[102:], uint32(h.MinLonE7))
+	le.PutUint32(buf[106:], uint32(h.MinLatE7))
+	le.PutUint32(buf[110:], uint32(h.MaxLonE7))
+	le.PutUint32(buf[114:], uint32(h.MaxLatE7))
+	buf[118] = h.CenterZoom
+	le.PutUint32(buf[119:], uint32(h.CenterLonE7))
+	le.PutUint32(buf[123:], uint32(h.CenterLatE7))
+	return buf
+}
+
+// deserializePMTilesHeader decodes the header of a PMTiles archive
+func deserializePMTilesHeader(buf []byte) (*pmtilesHeader, error) {
+	if len(buf) < pmtilesHeaderLength || !bytes.Equal(buf[:7], pmtilesMagic) {
+		return nil, errors.New("not a PMTiles archive")
+	}
+	if buf[7] != 3 {
+		return nil, fmt.Errorf("unsupported PMTiles version %d", buf[7])
+	}
+	le := binary.LittleEndian
+	return &pmtilesHeader{
+		RootOffset:          le.Uint64(buf[8:]),
+		RootLength:          le.Uint64(buf[16:]),
+		MetadataOffset:      le.Uint64(buf[24:]),
+		MetadataLength:      le.Uint64(buf[32:]),
+		LeafDirsOffset:      le.Uint64(buf[40:]),
+		LeafDirsLength:      le.Uint64(buf[48:]),
+		TileDataOffset:      le.Uint64(buf[56:]),
+		TileDataLength:      le.Uint64(buf[64:]),
+		AddressedTiles:      le.Uint64(buf[72:]),
+		TileEntries:         le.Uint64(buf[80:]),
+		TileContents:        le.Uint64(buf[88:]),
+		Clustered:           buf[96] == 1,
+		InternalCompression: buf[97],
+		TileCompression:     buf[98],
+		TileType:            buf[99],
+		MinZoom:             buf[100],
+		MaxZoom:             buf[101],
+		MinLonE7:            int32(le.Uint32(buf[102:])),
+		MinLatE7:            int32(le.Uint32(buf[106:])),
+		MaxLonE7:            int32(le.Uint32(buf[110:])),
+		MaxLatE7:            int32(le.Uint32(buf[114:])),
+		CenterZoom:          buf[118],
+		CenterLonE7:         int32(le.Uint32(buf[119:])),
+		CenterLatE7:         int32(le.Uint32(buf[123:])),
+	}, nil
+}
+
+// ZxyToTileID returns the PMTiles tile id of a tile:
+// tiles are numbered by zoom level, then along a Hilbert curve
+func ZxyToTileID(z, x, y int) uint64 {
+	acc := (uint64(1)<<(2*uint(z)) - 1) / 3
+	n := uint64(1) << uint(z)
+	tx, ty := uint64(x), uint64(y)
+	for s := n / 2; s > 0; s /= 2 {
+		var rx, ry uint64
+		if tx&s > 0 {
+			rx = 1
+		}
+		if ty&s > 0 {
+			ry = 1
+		}
+		acc += s * s * ((3 * rx) ^ ry)
+		// rotate the quadrant
+		if ry == 0 {
+			if rx == 1 {
+				tx = n - 1 - tx
+				ty = n - 1 - ty
+			}
+			tx, ty = ty, tx
+		}
+	}
+	return acc
+}
+
+// fromE7 decodes a coordinate encoded as an integer with 7 decimal digits
+func fromE7(v int32) float64 {
+	return float64(v) / 1e7
+}
+
+// toE7 encodes a coordinate as an integer with 7 decimal digits
+func toE7(v float64) int32 {
+	return int32(math.Round(v * 1e7))
+}
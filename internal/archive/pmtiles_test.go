@@ -0,0 +1,278 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZxyToTileID(t *testing.T) {
+	tests := []struct {
+		z, x, y int
+		id      uint64
+	}{
+		{0, 0, 0, 0},
+		{1, 0, 0, 1},
+		{1, 0, 1, 2},
+		{1, 1, 1, 3},
+		{1, 1, 0, 4},
+		{2, 0, 0, 5},
+		{12, 3423, 1763, 19078479},
+	}
+	for _, test := range tests {
+		if id := ZxyToTileID(test.z, test.x, test.y); id != test.id {
+			t.Errorf("ZxyToTileID(%d, %d, %d) = %d, expected %d", test.z, test.x, test.y, id, test.id)
+		}
+	}
+}
+
+func TestSerializeEntries(t *testing.T) {
+	entries := []pmtilesEntry{
+		{TileID: 1, Offset: 0, Length: 10, RunLength: 1},
+		{TileID: 2, Offset: 10, Length: 20, RunLength: 2},
+		{TileID: 4, Offset: 0, Length: 10, RunLength: 1},
+		{TileID: 100, Offset: 4096, Length: 5, RunLength: 0},
+	}
+	data, err := serializeEntries(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := deserializeEntries(data, pmtilesCompressionGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(entries) {
+		t.Fatalf("expected %d entries, got %d", len(entries), len(decoded))
+	}
+	for i := range entries {
+		if decoded[i] != entries[i] {
+			t.Errorf("entry %d: expected %+v, got %+v", i, entries[i], decoded[i])
+		}
+	}
+}
+
+func TestPMTilesWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.pmtiles")
+	pw, err := NewPMTilesWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// written out of order, with duplicates and an empty tile
+	tiles := []struct {
+		z, x, y int
+		data    string
+	}{
+		{1, 1, 0, "ocean"},
+		{0, 0, 0, "world"},
+		{1, 0, 0, "ocean"},
+		{1, 0, 1, "ocean"},
+		{1, 1, 1, ""},
+	}
+	for _, tile := range tiles {
+		if err := pw.WriteTile(tile.z, tile.x, tile.y, []byte(tile.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	meta := &Metadata{
+		Name:         "roads",
+		MinZoom:      0,
+		MaxZoom:      1,
+		Bounds:       []float64{-180, -85, 180, 85},
+		Center:       []float64{0, 0, 0},
+		VectorLayers: json.RawMessage(`[{"id":"roads"}]`),
+	}
+	if err := pw.Close(meta); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := deserializePMTilesHeader(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.AddressedTiles != 4 || header.TileEntries != 3 || header.TileContents != 2 || !header.Clustered {
+		t.Errorf("unexpected header counts: %+v", header)
+	}
+	if header.MaxZoom != 1 || header.MinLonE7 != -1800000000 || header.MaxLatE7 != 850000000 {
+		t.Errorf("unexpected header metadata: %+v", header)
+	}
+
+	// the identical tiles 1 and 2 (1/0/0, 1/0/1) form a run,
+	// tile 4 (1/1/0) is not contiguous as tile 3 (1/1/1) is empty
+	entries, err := deserializeEntries(content[header.RootOffset:header.RootOffset+header.RootLength], header.InternalCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []pmtilesEntry{
+		{TileID: 0, Offset: 0, Length: entries[0].Length, RunLength: 1},
+		{TileID: 1, Offset: uint64(entries[0].Length), Length: entries[1].Length, RunLength: 2},
+		{TileID: 4, Offset: uint64(entries[0].Length), Length: entries[1].Length, RunLength: 1},
+	}
+	if len(entries) != 3 || entries[0] != expected[0] || entries[1] != expected[1] || entries[2] != expected[2] {
+		t.Fatalf("unexpected root directory: %+v", entries)
+	}
+	tileData := content[header.TileDataOffset+entries[1].Offset : header.TileDataOffset+entries[1].Offset+uint64(entries[1].Length)]
+	if tile := gunzip(t, tileData); tile != "ocean" {
+		t.Errorf("expected tile data %q, got %q", "ocean", tile)
+	}
+
+	var metadata map[string]interface{}
+	metaJSON := gunzip(t, content[header.MetadataOffset:header.MetadataOffset+header.MetadataLength])
+	if err := json.Unmarshal([]byte(metaJSON), &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata["name"] != "roads" || metadata["vector_layers"] == nil {
+		t.Errorf("unexpected metadata: %s", metaJSON)
+	}
+
+	// the spool file is removed
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".pmtiles-*"))
+	if len(files) != 0 {
+		t.Errorf("expected temporary files to be removed, got %v", files)
+	}
+}
+
+func TestBuildDirectoriesLeaves(t *testing.T) {
+	// tile ids with gaps, so that the entries don't compress into a small root directory
+	entries := make([]pmtilesEntry, 100000)
+	for i := range entries {
+		entries[i] = pmtilesEntry{TileID: uint64(i)*3 + uint64(i*i%5), Offset: uint64(i * 1000), Length: uint32(i%997 + 1), RunLength: 1}
+	}
+	root, leaves, err := buildDirectories(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root) > pmtilesRootMaxLength || len(leaves) == 0 {
+		t.Fatalf("expected leaf directories and a root directory below %d bytes, got %d", pmtilesRootMaxLength, len(root))
+	}
+	rootEntries, err := deserializeEntries(root, pmtilesCompressionGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, rootEntry := range rootEntries {
+		if rootEntry.RunLength != 0 {
+			t.Fatalf("expected leaf directory entry, got %+v", rootEntry)
+		}
+		leaf, err := deserializeEntries(leaves[rootEntry.Offset:rootEntry.Offset+uint64(rootEntry.Length)], pmtilesCompressionGzip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if leaf[0].TileID != rootEntry.TileID {
+			t.Errorf("expected leaf to start at tile %d, got %d", rootEntry.TileID, leaf[0].TileID)
+		}
+		count += len(leaf)
+	}
+	if count != len(entries) {
+		t.Errorf("expected %d entries in leaf directories, got %d", len(entries), count)
+	}
+}
+
+func TestPMTilesReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.pmtiles")
+	pw, err := NewPMTilesWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// enough tiles to require leaf directories
+	for z := 0; z <= 8; z++ {
+		for x := 0; x < 1<<z; x++ {
+			for y := 0; y < 1<<z; y++ {
+				tile := fmt.Sprintf("%d/%d/%d", z, x, y)
+				if z == 8 && x >= 128 {
+					tile = "ocean"
+				}
+				if err := pw.WriteTile(z, x, y, []byte(tile)); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	meta := &Metadata{
+		Name:         "basemap",
+		Description:  "Base map",
+		MinZoom:      0,
+		MaxZoom:      8,
+		Bounds:       []float64{-180, -85.0511287, 180, 85.0511287},
+		Center:       []float64{8.5, 47.3, 6},
+		VectorLayers: json.RawMessage(`[{"id":"water","fields":{"name":"String"}}]`),
+	}
+	if err := pw.Close(meta); err != nil {
+		t.Fatal(err)
+	}
+
+	pr, err := OpenPMTiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	if pr.header.LeafDirsLength == 0 {
+		t.Error("expected leaf directories")
+	}
+
+	tests := []struct {
+		z, x, y int
+		tile    string
+	}{
+		{0, 0, 0, "0/0/0"},
+		{5, 17, 3, "5/17/3"},
+		{8, 127, 255, "8/127/255"},
+		{8, 200, 13, "ocean"},
+	}
+	for _, test := range tests {
+		tile, err := pr.Tile(context.Background(), test.z, test.x, test.y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(tile) != test.tile {
+			t.Errorf("tile %d/%d/%d: expected %q, got %q", test.z, test.x, test.y, test.tile, tile)
+		}
+	}
+	// tiles are stored compressed with gzip, and returned as stored
+	gzipTile, err := pr.GzipTile(context.Background(), 5, 17, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isGzip(gzipTile) {
+		t.Fatalf("expected gzip compressed tile, got %q", gzipTile)
+	}
+	if tile, err := gunzipBytes(gzipTile); err != nil || string(tile) != "5/17/3" {
+		t.Errorf("expected compressed tile 5/17/3, got %q (%v)", tile, err)
+	}
+	// beyond the zoom range of the archive
+	if tile, err := pr.Tile(context.Background(), 9, 0, 0); tile != nil || err != nil {
+		t.Errorf("expected no tile at zoom 9, got %q (%v)", tile, err)
+	}
+
+	readMeta := pr.Metadata()
+	if readMeta.Name != "basemap" || readMeta.Description != "Base map" || readMeta.MaxZoom != 8 {
+		t.Errorf("unexpected metadata: %+v", readMeta)
+	}
+	if readMeta.Center[0] != 8.5 || readMeta.Center[2] != 6 || readMeta.Bounds[3] != 85.0511287 {
+		t.Errorf("unexpected bounds and center: %v %v", readMeta.Bounds, readMeta.Center)
+	}
+	if string(readMeta.VectorLayers) != `[{"id":"water","fields":{"name":"String"}}]` {
+		t.Errorf("unexpected vector layers: %s", readMeta.VectorLayers)
+	}
+}
+
+func gunzip(t *testing.T, data []byte) string {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
@@ -0,0 +1,346 @@
+package cache
+
+import (
+	"bufio"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// diskFileExt is the extension of the tile files written by the disk cache
+const diskFileExt = ".tile"
+
+// DiskCache is a persistent LRU tile cache stored in a local directory.
+// Each tile is stored in its own file, named after the hash of its key.
+// The file starts with a line containing the cache key, followed by the tile data,
+// so that the index can be rebuilt when the server restarts.
+type DiskCache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front = most recently used
+	bytes   int64
+
+	evictions atomic.Int64
+}
+
+// diskEntry is the index entry of a tile stored on disk
+type diskEntry struct {
+	key  string
+	size int64
+}
+
+// NewDiskCache opens (or creates) a disk cache in the given directory.
+// Tiles already present in the directory are indexed,
+// using their modification time as the last access time.
+func NewDiskCache(dir string, maxSizeMB int) (*DiskCache, error) {
+	if dir == "" {
+		return nil, errors.New("disk cache directory must be set")
+	}
+	if maxSizeMB <= 0 {
+		return nil, fmt.Errorf("disk cache max size must be positive, got %d", maxSizeMB)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create disk cache directory: %w", err)
+	}
+
+	dc := &DiskCache{
+		dir:      dir,
+		maxBytes: int64(maxSizeMB) * 1024 * 1024,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+	if err := dc.load(); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Initialized disk tile cache: path=%s max_size=%dMB tiles=%d size=%dMB",
+		dir, maxSizeMB, dc.lru.Len(), dc.bytes/1024/1024)
+	return dc, nil
+}
+
+// load indexes the tiles stored in the cache directory
+func (dc *DiskCache) load() error {
+	type storedTile struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var tiles []storedTile
+
+	err := filepath.WalkDir(dc.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// leftovers of interrupted writes
+		if strings.HasPrefix(d.Name(), ".tmp-") {
+			os.Remove(path)
+			return nil
+		}
+		if filepath.Ext(path) != diskFileExt {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		key, err := readDiskKey(path)
+		if err != nil || dc.path(key) != path {
+			log.Warnf("Removing invalid disk cache file %s", path)
+			os.Remove(path)
+			return nil
+		}
+		tiles = append(tiles, storedTile{key: key, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot read disk cache directory: %w", err)
+	}
+
+	// least recently used last
+	sort.Slice(tiles, func(i, j int) bool {
+		return tiles[i].modTime.After(tiles[j].modTime)
+	})
+	for _, tile := range tiles {
+		dc.entries[tile.key] = dc.lru.PushBack(&diskEntry{key: tile.key, size: tile.size})
+		dc.bytes += tile.size
+	}
+
+	dc.mu.Lock()
+	dc.removeFiles(dc.evictLocked())
+	dc.mu.Unlock()
+	return nil
+}
+
+// Get retrieves a tile from the disk cache
+func (dc *DiskCache) Get(key string) ([]byte, bool) {
+	dc.mu.Lock()
+	elem, ok := dc.entries[key]
+	if ok {
+		dc.lru.MoveToFront(elem)
+	}
+	dc.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	path := dc.path(key)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.Warnf("Error reading disk cache file %s: %v", path, err)
+		dc.Remove(key)
+		return nil, false
+	}
+	header, tile, found := strings.Cut(string(content), "\n")
+	if !found || header != key {
+		log.Warnf("Invalid disk cache file %s", path)
+		dc.Remove(key)
+		return nil, false
+	}
+
+	// record the access, so that the LRU order survives a restart
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return []byte(tile), true
+}
+
+// Set stores a tile in the disk cache, evicting the least recently used tiles
+// if the cache grows beyond its maximum size
+func (dc *DiskCache) Set(key string, data []byte) error {
+	size := int64(len(key) + 1 + len(data))
+	if size > dc.maxBytes {
+		return nil
+	}
+
+	// the tile is written to a temporary file, which is renamed under the lock,
+	// so that it can't be deleted by a concurrent eviction of the previous tile
+	path := dc.path(key)
+	tmpPath, err := writeDiskTempFile(path, key, data)
+	if err != nil {
+		return fmt.Errorf("error writing disk cache file %s: %w", path, err)
+	}
+	defer os.Remove(tmpPath)
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("error writing disk cache file %s: %w", path, err)
+	}
+	if elem, ok := dc.entries[key]; ok {
+		entry := elem.Value.(*diskEntry)
+		dc.bytes += size - entry.size
+		entry.size = size
+		dc.lru.MoveToFront(elem)
+	} else {
+		dc.entries[key] = dc.lru.PushFront(&diskEntry{key: key, size: size})
+		dc.bytes += size
+	}
+	dc.removeFiles(dc.evictLocked())
+	return nil
+}
+
+// Remove deletes a tile from the disk cache
+func (dc *DiskCache) Remove(key string) bool {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	elem, ok := dc.entries[key]
+	if ok {
+		dc.removeLocked(elem)
+		dc.removeFiles([]string{key})
+	}
+	return ok
+}
+
+// Keys returns the keys of all tiles in the disk cache
+func (dc *DiskCache) Keys() []string {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	keys := make([]string, 0, len(dc.entries))
+	for key := range dc.entries {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Clear removes all tiles from the disk cache
+func (dc *DiskCache) Clear() {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	keys := make([]string, 0, len(dc.entries))
+	for key := range dc.entries {
+		keys = append(keys, key)
+	}
+	dc.entries = make(map[string]*list.Element)
+	dc.lru.Init()
+	dc.bytes = 0
+	dc.removeFiles(keys)
+}
+
+// Len returns the number of tiles in the disk cache
+func (dc *DiskCache) Len() int {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.lru.Len()
+}
+
+// Bytes returns the size of the disk cache in bytes
+func (dc *DiskCache) Bytes() int64 {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.bytes
+}
+
+// Evictions returns the number of tiles evicted from the disk cache
+func (dc *DiskCache) Evictions() int64 {
+	return dc.evictions.Load()
+}
+
+// evictLocked removes the least recently used entries from the index
+// until the cache fits in its maximum size, and returns their keys.
+// The caller must hold the lock, and delete the files of the returned keys before releasing it.
+func (dc *DiskCache) evictLocked() []string {
+	var removed []string
+	for dc.bytes > dc.maxBytes {
+		elem := dc.lru.Back()
+		if elem == nil {
+			break
+		}
+		key := dc.removeLocked(elem)
+		removed = append(removed, key)
+		dc.evictions.Add(1)
+		log.Debugf("Disk cache EVICT: %s", key)
+	}
+	return removed
+}
+
+// removeLocked removes an entry from the index and returns its key
+func (dc *DiskCache) removeLocked(elem *list.Element) string {
+	entry := dc.lru.Remove(elem).(*diskEntry)
+	delete(dc.entries, entry.key)
+	dc.bytes -= entry.size
+	return entry.key
+}
+
+// removeFiles deletes the files of the given keys.
+// The caller must hold the lock, so that a file written meanwhile for the same key is kept.
+func (dc *DiskCache) removeFiles(keys []string) {
+	for _, key := range keys {
+		path := dc.path(key)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Warnf("Error removing disk cache file %s: %v", path, err)
+		}
+	}
+}
+
+// path returns the path of the file storing the tile with the given key.
+// Files are spread over subdirectories to keep directories small.
+func (dc *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(dc.dir, name[:2], name+diskFileExt)
+}
+
+// writeDiskTempFile writes a tile file to a temporary file in the directory of the tile file,
+// and returns its path. The file is stored by renaming it to the tile file.
+func writeDiskTempFile(path string, key string, data []byte) (string, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+
+	w := bufio.NewWriter(tmp)
+	w.WriteString(key)
+	w.WriteByte('\n')
+	w.Write(data)
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// readDiskKey reads the cache key stored in the header of a tile file
+func readDiskKey(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	key, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("missing cache key")
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(key, "\n"), nil
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskCacheGetSet(t *testing.T) {
+	dc, err := NewDiskCache(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := dc.Get("roads:1:0:0"); ok {
+		t.Fatal("expected miss on empty cache")
+	}
+	if err := dc.Set("roads:1:0:0", []byte("tile\ndata")); err != nil {
+		t.Fatal(err)
+	}
+	tile, ok := dc.Get("roads:1:0:0")
+	if !ok || string(tile) != "tile\ndata" {
+		t.Fatalf("expected stored tile, got %q (found=%v)", tile, ok)
+	}
+
+	// overwriting a tile does not count it twice
+	if err := dc.Set("roads:1:0:0", []byte("other")); err != nil {
+		t.Fatal(err)
+	}
+	if dc.Len() != 1 || dc.Bytes() != int64(len("roads:1:0:0\nother")) {
+		t.Errorf("expected 1 tile of %d bytes, got %d tiles of %d bytes",
+			len("roads:1:0:0\nother"), dc.Len(), dc.Bytes())
+	}
+
+	if !dc.Remove("roads:1:0:0") {
+		t.Error("expected tile to be removed")
+	}
+	if _, ok := dc.Get("roads:1:0:0"); ok {
+		t.Error("expected miss after remove")
+	}
+}
+
+func TestDiskCacheRestart(t *testing.T) {
+	dir := t.TempDir()
+	dc, err := NewDiskCache(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := dc.Set(fmt.Sprintf("roads:2:%d:0", i), []byte("tile")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// leftover of an interrupted write
+	os.WriteFile(filepath.Join(dir, ".tmp-123"), []byte("partial"), 0o644)
+
+	reopened, err := NewDiskCache(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != 3 || reopened.Bytes() != dc.Bytes() {
+		t.Errorf("expected %d tiles of %d bytes after restart, got %d tiles of %d bytes",
+			3, dc.Bytes(), reopened.Len(), reopened.Bytes())
+	}
+	if tile, ok := reopened.Get("roads:2:1:0"); !ok || string(tile) != "tile" {
+		t.Errorf("expected stored tile after restart, got %q (found=%v)", tile, ok)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".tmp-123")); !os.IsNotExist(err) {
+		t.Error("expected temporary file to be removed")
+	}
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	dc, err := NewDiskCache(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile := []byte(strings.Repeat("x", 300*1024))
+	for i := 0; i < 3; i++ {
+		if err := dc.Set(fmt.Sprintf("roads:3:%d:0", i), tile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// make the first tile the most recently used
+	if _, ok := dc.Get("roads:3:0:0"); !ok {
+		t.Fatal("expected tile to be cached")
+	}
+	if err := dc.Set("roads:3:3:0", tile); err != nil {
+		t.Fatal(err)
+	}
+
+	if dc.Bytes() > 1024*1024 {
+		t.Errorf("expected cache size below 1MB, got %d bytes", dc.Bytes())
+	}
+	if dc.Evictions() != 1 {
+		t.Errorf("expected 1 eviction, got %d", dc.Evictions())
+	}
+	if _, ok := dc.Get("roads:3:1:0"); ok {
+		t.Error("expected least recently used tile to be evicted")
+	}
+	if _, ok := dc.Get("roads:3:0:0"); !ok {
+		t.Error("expected recently used tile to be kept")
+	}
+
+	// the access order is restored from the file times on restart
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(dc.path("roads:3:2:0"), old, old)
+	reopened, err := NewDiskCache(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Set("roads:3:4:0", tile); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("roads:3:2:0"); ok {
+		t.Error("expected oldest tile to be evicted after restart")
+	}
+}
+
+func TestTileCacheDiskTier(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dc, err := NewDiskCache(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, err := NewTileCache(10, 1, dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Set(ctx, "roads:1:0:0", []byte("roads"))
+	tc.Set(ctx, "roads,water:1:0:0", []byte("composite"))
+	tc.Set(ctx, "water:1:0:0", []byte("water"))
+
+	// a new memory cache on the same directory is served from disk
+	dc, err = NewDiskCache(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, err = NewTileCache(10, 1, dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile, ok := tc.Get(ctx, "roads:1:0:0")
+	if !ok || string(tile) != "roads" {
+		t.Fatalf("expected tile from disk, got %q (found=%v)", tile, ok)
+	}
+	stats := tc.Stats()
+	if stats.Hits != 1 || stats.DiskHits != 1 || stats.Size != 1 || stats.DiskSize != 3 {
+		t.Errorf("unexpected stats after disk hit: %+v", stats)
+	}
+
+	// the tile is now in memory
+	tc.Get(ctx, "roads:1:0:0")
+	if stats := tc.Stats(); stats.DiskHits != 1 {
+		t.Errorf("expected memory hit, got %d disk hits", stats.DiskHits)
+	}
+
+	if removed := tc.ClearLayer("roads"); removed != 2 {
+		t.Errorf("expected 2 tiles removed, got %d", removed)
+	}
+	if _, ok := tc.Get(ctx, "roads,water:1:0:0"); ok {
+		t.Error("expected composite tile to be removed from disk")
+	}
+	if _, ok := tc.Get(ctx, "water:1:0:0"); !ok {
+		t.Error("expected tile of other layer to be kept")
+	}
+
+	tc.Clear()
+	if dc.Len() != 0 {
+		t.Errorf("expected empty disk cache after clear, got %d tiles", dc.Len())
+	}
+}
+
+func TestTileCacheClearTiles(t *testing.T) {
+	ctx := context.Background()
+	dc, err := NewDiskCache(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, err := NewTileCache(10, 1, dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Set(ctx, "roads:1:0:0", []byte("roads"))
+	tc.Set(ctx, "roads:1:1:0", []byte("roads"))
+	tc.Set(ctx, "roads,water:1:0:0", []byte("composite"))
+	tc.Set(ctx, "water:1:0:0", []byte("water"))
+	// only on disk
+	dc.Set("roads:2:0:0", []byte("roads"))
+
+	removed := tc.ClearTiles("roads", func(key string) bool {
+		return strings.HasSuffix(key, ":0:0")
+	})
+	if removed != 3 {
+		t.Errorf("expected 3 tiles removed, got %d", removed)
+	}
+	for _, key := range []string{"roads:1:0:0", "roads,water:1:0:0", "roads:2:0:0"} {
+		if _, ok := tc.Get(ctx, key); ok {
+			t.Errorf("expected tile %s to be removed", key)
+		}
+	}
+	for _, key := range []string{"roads:1:1:0", "water:1:0:0"} {
+		if _, ok := tc.Get(ctx, key); !ok {
+			t.Errorf("expected tile %s to be kept", key)
+		}
+	}
+}
@@ -4,20 +4,30 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	lru "github.com/hashicorp/golang-lru/v2"
 	log "github.com/sirupsen/logrus"
 )
 
-// TileCache provides thread-safe LRU caching for MVT tiles
+// TileCache provides thread-safe LRU caching for MVT tiles.
+// Tiles are kept in memory, and optionally in a persistent disk tier
+// which is checked on memory misses.
+// The memory tier is limited by the number of tiles and by the total size of the tiles.
 type TileCache struct {
 	cache       *lru.Cache[string, []byte]
+	disk        *DiskCache
 	enabled     bool
 	maxMemoryMB int64
 
+	// memoryMutex serializes the changes of the memory tier,
+	// so that the byte count is exact when tiles are replaced or removed concurrently
+	memoryMutex sync.Mutex
+
 	// Metrics (atomic counters for thread-safety)
 	hits         atomic.Int64
+	diskHits     atomic.Int64
 	misses       atomic.Int64
 	evictions    atomic.Int64
 	currentSize  atomic.Int64
@@ -32,15 +42,23 @@ type Stats struct {
 	Size        int     `json:"size"` // Number of items
 	MemoryBytes int64   `json:"memory_bytes"`
 	HitRate     float64 `json:"hit_rate"` // Percentage
+
+	// Disk tier statistics (only set if the disk tier is enabled)
+	DiskHits      int64 `json:"disk_hits,omitempty"` // Memory misses served from disk
+	DiskSize      int   `json:"disk_size,omitempty"` // Number of items
+	DiskBytes     int64 `json:"disk_bytes,omitempty"`
+	DiskEvictions int64 `json:"disk_evictions,omitempty"`
 }
 
-// NewTileCache creates a new LRU tile cache
-func NewTileCache(maxItems int, maxMemoryMB int) (*TileCache, error) {
+// NewTileCache creates a new LRU tile cache.
+// If disk is not nil, it is used as a second cache tier.
+func NewTileCache(maxItems int, maxMemoryMB int, disk *DiskCache) (*TileCache, error) {
 	if maxItems <= 0 {
 		return nil, fmt.Errorf("maxItems must be positive, got %d", maxItems)
 	}
 
 	tc := &TileCache{
+		disk:        disk,
 		enabled:     true,
 		maxMemoryMB: int64(maxMemoryMB),
 	}
@@ -74,6 +92,16 @@ func (tc *TileCache) Get(ctx context.Context, key string) ([]byte, bool) {
 		return tile, true
 	}
 
+	if tc.disk != nil {
+		if tile, ok := tc.disk.Get(key); ok {
+			tc.hits.Add(1)
+			tc.diskHits.Add(1)
+			log.Debugf("Cache HIT (disk): %s", key)
+			tc.addMemory(key, tile)
+			return tile, true
+		}
+	}
+
 	tc.misses.Add(1)
 	log.Debugf("Cache MISS: %s", key)
 	return nil, false
@@ -85,34 +113,54 @@ func (tc *TileCache) Set(ctx context.Context, key string, data []byte) error {
 		return nil
 	}
 
-	tileSize := int64(len(data))
+	// Make a copy to avoid referencing request data
+	tileCopy := make([]byte, len(data))
+	copy(tileCopy, data)
 
-	// Check memory limit before adding
-	if tc.maxMemoryMB > 0 {
-		currentMB := tc.currentBytes.Load() / 1024 / 1024
-		tileMB := tileSize / 1024 / 1024
+	tc.addMemory(key, tileCopy)
+	log.Debugf("Cache SET: %s (%d bytes)", key, len(data))
 
-		if currentMB+tileMB > tc.maxMemoryMB {
-			log.Debugf("Cache memory limit reached, evicting to make space")
-			// LRU will automatically evict oldest items
+	if tc.disk != nil {
+		if err := tc.disk.Set(key, tileCopy); err != nil {
+			log.Warnf("Disk cache SET failed: %v", err)
+			return err
 		}
 	}
+	return nil
+}
 
-	// Make a copy to avoid referencing request data
-	tileCopy := make([]byte, len(data))
-	copy(tileCopy, data)
+// addMemory stores a tile in the memory tier.
+// Least recently used tiles are evicted while the memory limit is exceeded.
+func (tc *TileCache) addMemory(key string, tile []byte) {
+	tc.memoryMutex.Lock()
+	defer tc.memoryMutex.Unlock()
 
-	tc.cache.Add(key, tileCopy)
-	tc.currentBytes.Add(tileSize)
+	// a replaced tile is not evicted, its size is subtracted here
+	if previous, ok := tc.cache.Peek(key); ok {
+		tc.currentBytes.Add(-int64(len(previous)))
+		tc.currentSize.Add(-1)
+	}
+	if tc.cache.Add(key, tile) {
+		tc.evictions.Add(1)
+	}
+	tc.currentBytes.Add(int64(len(tile)))
 	tc.currentSize.Add(1)
 
-	log.Debugf("Cache SET: %s (%d bytes)", key, tileSize)
-	return nil
+	if tc.maxMemoryMB <= 0 {
+		return
+	}
+	maxBytes := tc.maxMemoryMB * 1024 * 1024
+	for tc.currentBytes.Load() > maxBytes {
+		// a tile larger than the limit evicts itself
+		if _, _, ok := tc.cache.RemoveOldest(); !ok {
+			break
+		}
+		tc.evictions.Add(1)
+	}
 }
 
-// onEvict is called when an item is evicted from the LRU cache
+// onEvict is called when an item is evicted or removed from the LRU cache
 func (tc *TileCache) onEvict(key string, value []byte) {
-	tc.evictions.Add(1)
 	tc.currentSize.Add(-1)
 	tc.currentBytes.Add(-int64(len(value)))
 	log.Debugf("Cache EVICT: %s", key)
@@ -124,31 +172,72 @@ func (tc *TileCache) Clear() {
 		return
 	}
 
+	tc.memoryMutex.Lock()
 	tc.cache.Purge()
 	tc.currentSize.Store(0)
 	tc.currentBytes.Store(0)
+	tc.memoryMutex.Unlock()
+	if tc.disk != nil {
+		tc.disk.Clear()
+	}
 	log.Info("Cache cleared")
 }
 
-// ClearLayer removes all tiles for a specific layer
+// ClearLayer removes all tiles for a specific layer,
+// including composite tiles (e.g. "roads,water:z:x:y") containing the layer
 func (tc *TileCache) ClearLayer(layerName string) int {
+	removed := tc.ClearTiles(layerName, nil)
+	log.Infof("Cleared %d tiles for layer %s", removed, layerName)
+	return removed
+}
+
+// ClearTiles removes the tiles of a layer (including composite tiles containing the layer)
+// for which match returns true. A nil match removes all tiles of the layer.
+func (tc *TileCache) ClearTiles(layerName string, match func(key string) bool) int {
 	if !tc.enabled {
 		return 0
 	}
 
-	removed := 0
-	keys := tc.cache.Keys()
+	removedKeys := make(map[string]bool)
+	matches := func(key string) bool {
+		return keyHasLayer(key, layerName) && (match == nil || match(key))
+	}
 
-	prefix := layerName + ":"
-	for _, key := range keys {
-		if strings.HasPrefix(key, prefix) {
+	tc.memoryMutex.Lock()
+	for _, key := range tc.cache.Keys() {
+		if matches(key) {
 			tc.cache.Remove(key)
-			removed++
+			removedKeys[key] = true
+		}
+	}
+	tc.memoryMutex.Unlock()
+
+	// tiles in memory are usually also on disk, they are only counted once
+	if tc.disk != nil {
+		for _, key := range tc.disk.Keys() {
+			if removedKeys[key] {
+				tc.disk.Remove(key)
+			} else if matches(key) && tc.disk.Remove(key) {
+				removedKeys[key] = true
+			}
 		}
 	}
+	return len(removedKeys)
+}
 
-	log.Infof("Cleared %d tiles for layer %s", removed, layerName)
-	return removed
+// keyHasLayer tests whether a tile cache key ("layer:z:x:y") belongs to a layer.
+// The layer part of the key may list several comma-separated layers.
+func keyHasLayer(key string, layerName string) bool {
+	layers, _, found := strings.Cut(key, ":")
+	if !found {
+		return false
+	}
+	for _, name := range strings.Split(layers, ",") {
+		if name == layerName {
+			return true
+		}
+	}
+	return false
 }
 
 // Stats returns current cache statistics
@@ -166,7 +255,7 @@ func (tc *TileCache) Stats() Stats {
 		hitRate = float64(hits) / float64(total) * 100.0
 	}
 
-	return Stats{
+	stats := Stats{
 		Hits:        hits,
 		Misses:      misses,
 		Evictions:   tc.evictions.Load(),
@@ -174,9 +263,21 @@ func (tc *TileCache) Stats() Stats {
 		MemoryBytes: tc.currentBytes.Load(),
 		HitRate:     hitRate,
 	}
+	if tc.disk != nil {
+		stats.DiskHits = tc.diskHits.Load()
+		stats.DiskSize = tc.disk.Len()
+		stats.DiskBytes = tc.disk.Bytes()
+		stats.DiskEvictions = tc.disk.Evictions()
+	}
+	return stats
 }
 
 // Enabled returns whether the cache is enabled
 func (tc *TileCache) Enabled() bool {
 	return tc.enabled
 }
+
+// HasDisk returns whether the cache has a persistent disk tier
+func (tc *TileCache) HasDisk() bool {
+	return tc.enabled && tc.disk != nil
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestTileCacheMemoryLimit(t *testing.T) {
+	tc, err := NewTileCache(100, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	tile := make([]byte, 400*1024)
+
+	for i := 0; i < 3; i++ {
+		tc.Set(ctx, fmt.Sprintf("roads:10:%d:0", i), tile)
+	}
+	// the third tile exceeds 1MB, the least recently used tile is evicted
+	stats := tc.Stats()
+	if stats.Size != 2 || stats.MemoryBytes != 2*400*1024 || stats.Evictions != 1 {
+		t.Errorf("expected 2 tiles of %d bytes and 1 eviction, got %d tiles of %d bytes and %d evictions",
+			2*400*1024, stats.Size, stats.MemoryBytes, stats.Evictions)
+	}
+	if _, ok := tc.Get(ctx, "roads:10:0:0"); ok {
+		t.Error("expected least recently used tile to be evicted")
+	}
+
+	// a tile larger than the limit is not kept
+	tc.Set(ctx, "roads:10:9:0", make([]byte, 2*1024*1024))
+	if _, ok := tc.Get(ctx, "roads:10:9:0"); ok {
+		t.Error("expected tile larger than the limit to be evicted")
+	}
+	if stats := tc.Stats(); stats.Size != 0 || stats.MemoryBytes != 0 {
+		t.Errorf("expected empty cache, got %d tiles of %d bytes", stats.Size, stats.MemoryBytes)
+	}
+}
+
+func TestTileCacheReplaceAccounting(t *testing.T) {
+	tc, err := NewTileCache(100, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// replacing a tile does not count it twice
+	tc.Set(ctx, "roads:1:0:0", []byte("tile"))
+	tc.Set(ctx, "roads:1:0:0", []byte("larger tile"))
+	stats := tc.Stats()
+	if stats.Size != 1 || stats.MemoryBytes != int64(len("larger tile")) || stats.Evictions != 0 {
+		t.Errorf("expected 1 tile of %d bytes without evictions, got %d tiles of %d bytes and %d evictions",
+			len("larger tile"), stats.Size, stats.MemoryBytes, stats.Evictions)
+	}
+
+	tc.Set(ctx, "water:1:0:0", []byte("tile"))
+	tc.ClearLayer("roads")
+	if stats := tc.Stats(); stats.Size != 1 || stats.MemoryBytes != int64(len("tile")) {
+		t.Errorf("expected 1 tile of %d bytes after clearing a layer, got %d tiles of %d bytes",
+			len("tile"), stats.Size, stats.MemoryBytes)
+	}
+	tc.Clear()
+	if stats := tc.Stats(); stats.Size != 0 || stats.MemoryBytes != 0 {
+		t.Errorf("expected empty cache, got %d tiles of %d bytes", stats.Size, stats.MemoryBytes)
+	}
+}
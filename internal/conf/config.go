@@ -46,19 +46,25 @@ func setDefaultConfig() {
 	viper.SetDefault("Cache.Enabled", true)
 	viper.SetDefault("Cache.MaxItems", 10000)
 	viper.SetDefault("Cache.MaxMemoryMB", 1024)
+	viper.SetDefault("Cache.DiskPath", "")
+	viper.SetDefault("Cache.DiskMaxSizeMB", 1024)
 	viper.SetDefault("Cache.BrowserCacheMaxAge", 3600) // 1 hour in seconds
+	viper.SetDefault("Cache.SeedWorkers", 4)
+	viper.SetDefault("Cache.ChangePollSec", 0)
 	viper.SetDefault("Cache.DisableApi", false)
 	viper.SetDefault("Cache.ApiKey", "")
 }
 
 // Config for system
 type Config struct {
-	Server   Server
-	Paging   Paging
-	Metadata Metadata
-	Database Database
-	Website  Website
-	Cache    Cache
+	Server         Server
+	Paging         Paging
+	Metadata       Metadata
+	Database       Database
+	Website        Website
+	Cache          Cache
+	Layers         []Layer
+	TileMatrixSets []TileMatrixSet
 }
 
 // Server config
@@ -112,11 +118,76 @@ type Cache struct {
 	Enabled            bool
 	MaxItems           int
 	MaxMemoryMB        int
+	DiskPath           string // Directory of the persistent disk cache tier (empty = disabled)
+	DiskMaxSizeMB      int    // Maximum size of the disk cache tier in MB
 	BrowserCacheMaxAge int    // Browser cache max-age in seconds
+	SeedWorkers        int    // Maximum number of tiles generated concurrently by a seed job
+	ChangePollSec      int    // Interval of the checks for changed layer data in seconds (0 = disabled)
 	DisableApi         bool   // Disable cache management API endpoints
 	ApiKey             string // API key for cache management endpoints
 }
 
+// Layer config (optional per-layer settings, declared as [[Layers]])
+type Layer struct {
+	Name           string   // Name of the layer (table name, or name of a query layer)
+	Title          string   // Display name of the layer
+	Description    string   // Description of the layer
+	Attribution    string   // Attribution of the layer data (TileJSON attribution, may contain HTML links)
+	MinZoom        int      // Minimum zoom level at which tiles contain data
+	MaxZoom        int      // Maximum zoom level at which tiles contain data (0 = default)
+	DataMaxZoom    int      // Zoom level above which tiles are cut from the parent tile at this zoom (0 = disabled)
+	SimplifyFactor float64  // Tolerance of the geometry simplification in pixels at the tile zoom level (0 = disabled)
+	ClusterMaxZoom int      // Zoom level up to which the points of a point layer are aggregated into clusters (0 = disabled)
+	ClusterSize    int      // Size of the cluster grid cells in pixels (default 64)
+	ClusterFields  []string // Aggregates added as cluster properties (e.g. "max(value) AS max_value")
+	MaxFeatures    int      // Maximum number of features in a tile (0 = unlimited)
+	MaxTileSizeKB  int      // Maximum size of a tile in KB (0 = unlimited)
+	Priority       string   // Features kept first in tiles exceeding the budget: a column (highest values), "area" or "random" (default)
+	Extent         int      // Extent of the tile geometries in MVT units (default 4096)
+	Buffer         *int     // Buffer around the tile in MVT units, included in the tile geometries (default 256)
+	Clip           *bool    // Clip geometries to the tile extent and buffer (default true)
+	Properties     []string // Property columns to include in tiles (default is all columns)
+	Filter         string   // SQL condition (WHERE clause) restricting the features served
+	Sql            string   // SELECT statement providing the layer data (query-backed layer)
+	Source         string   // File path, glob or table function providing the layer data (file-backed layer)
+	GeometryColumn string   // Geometry column of a query or file layer (default is the first geometry column)
+	Srid           int      // EPSG code of the layer geometries (default is detected from the coordinates: 4326 or 3857)
+	IdColumn       string   // Integer column written as the MVT feature id (default is the integer primary key of a table)
+	Archive        string   // Path of an MBTiles or PMTiles archive providing pre-built tiles (archive layer)
+	VersionQuery   string   // Query returning a value which changes with the layer data (polled to invalidate cached tiles)
+	TileMatrixSet  string   // Id of the tile matrix set of the layer tiles (default WebMercatorQuad)
+	Parameters     []LayerParameter
+}
+
+// LayerParameter config (named parameter bound from the tile request query string)
+type LayerParameter struct {
+	Name    string // Name of the parameter, referenced as $name in Sql and Filter
+	Type    string // DuckDB type of the parameter value (default VARCHAR)
+	Default string // Value used if the request does not provide one (default NULL)
+}
+
+// TileMatrixSet config (custom tiling scheme, declared as [[TileMatrixSets]])
+type TileMatrixSet struct {
+	Id          string    // Identifier of the tile matrix set, used in tile URLs
+	Title       string    // Display name of the tile matrix set
+	Srid        int       // EPSG code of the CRS of the tiles
+	Extent      []float64 // Extent covered by the tiles (minx, miny, maxx, maxy) in the CRS of the tiles
+	Origin      []float64 // Top-left corner of the tile matrices (x, y) (default is the top-left corner of the extent)
+	Resolutions []float64 // Size of a pixel in CRS units for each zoom level
+	TileSize    int       // Width and height of a tile in pixels (default 256)
+}
+
+// LayerConfig returns the configuration for the named layer.
+// It returns nil if the layer has no configuration section
+func (conf *Config) LayerConfig(name string) *Layer {
+	for i := range conf.Layers {
+		if conf.Layers[i].Name == name {
+			return &conf.Layers[i]
+		}
+	}
+	return nil
+}
+
 // IsHTTPSEnabled tests whether HTTPS is enabled
 func (conf *Config) IsTLSEnabled() bool {
 	return conf.Server.TlsServerCertificateFile != "" && conf.Server.TlsServerPrivateKeyFile != ""
@@ -197,4 +268,15 @@ func DumpConfig() {
 	log.Debugf("  TableExcludes = %v", Configuration.Database.TableExcludes)
 	log.Debugf("  FunctionIncludes = %v", Configuration.Database.FunctionIncludes)
 	log.Debugf("  TransformFunctions = %v", Configuration.Server.TransformFunctions)
+	log.Debugf("  Cache: Enabled = %v, DiskPath = %q, DiskMaxSizeMB = %v",
+		Configuration.Cache.Enabled, Configuration.Cache.DiskPath, Configuration.Cache.DiskMaxSizeMB)
+	log.Debugf("  Cache: SeedWorkers = %v, ChangePollSec = %v", Configuration.Cache.SeedWorkers, Configuration.Cache.ChangePollSec)
+	for _, layer := range Configuration.Layers {
+		log.Debugf("  Layer %s: MinZoom = %v, MaxZoom = %v, DataMaxZoom = %v, Properties = %v, Filter = %q",
+			layer.Name, layer.MinZoom, layer.MaxZoom, layer.DataMaxZoom, layer.Properties, layer.Filter)
+	}
+	for _, tms := range Configuration.TileMatrixSets {
+		log.Debugf("  TileMatrixSet %s: Srid = %v, Extent = %v, Resolutions = %v",
+			tms.Id, tms.Srid, tms.Extent, len(tms.Resolutions))
+	}
 }
@@ -216,6 +216,108 @@ TableExcludes = ["config_exclude"]
 	equals(t, expectedExcludes, Configuration.Database.TableExcludes, "TableExcludes from config")
 }
 
+// TestLayersConfig tests that [[Layers]] sections are read from the config file
+func TestLayersConfig(t *testing.T) {
+	clearConfigEnvVars()
+	defer clearConfigEnvVars()
+
+	configContent := `
+[[Layers]]
+Name = "roads"
+Title = "Road Network"
+Description = "Major roads"
+MinZoom = 6
+MaxZoom = 14
+Properties = ["name", "class"]
+Filter = "class <> 'service'"
+
+[[Layers]]
+Name = "buildings"
+MinZoom = 13
+`
+
+	tempDir, err := os.MkdirTemp("", "duckdb-tileserver_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	configFile := filepath.Join(tempDir, "test_config.toml")
+	err = os.WriteFile(configFile, []byte(configContent), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	InitConfig(configFile, false)
+
+	equals(t, 2, len(Configuration.Layers), "Number of layers")
+
+	roads := Configuration.LayerConfig("roads")
+	if roads == nil {
+		t.Fatal("Expected config for layer roads")
+	}
+	equals(t, "Road Network", roads.Title, "Title")
+	equals(t, "Major roads", roads.Description, "Description")
+	equals(t, 6, roads.MinZoom, "MinZoom")
+	equals(t, 14, roads.MaxZoom, "MaxZoom")
+	equals(t, []string{"name", "class"}, roads.Properties, "Properties")
+	equals(t, "class <> 'service'", roads.Filter, "Filter")
+
+	buildings := Configuration.LayerConfig("buildings")
+	if buildings == nil {
+		t.Fatal("Expected config for layer buildings")
+	}
+	equals(t, 13, buildings.MinZoom, "MinZoom")
+	equals(t, 0, buildings.MaxZoom, "MaxZoom")
+
+	if Configuration.LayerConfig("unknown") != nil {
+		t.Error("Expected no config for unknown layer")
+	}
+}
+
+// TestTileMatrixSetsConfig tests that [[TileMatrixSets]] sections are read from the config file
+func TestTileMatrixSetsConfig(t *testing.T) {
+	clearConfigEnvVars()
+	defer clearConfigEnvVars()
+
+	configContent := `
+[[TileMatrixSets]]
+Id = "LAEA"
+Srid = 3035
+Extent = [2000000, 1000000.0, 6500000.0, 5500000.0]
+Resolutions = [17578.125, 8789.0625]
+
+[[Layers]]
+Name = "roads"
+TileMatrixSet = "LAEA"
+`
+
+	tempDir, err := os.MkdirTemp("", "duckdb-tileserver_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	configFile := filepath.Join(tempDir, "test_config.toml")
+	err = os.WriteFile(configFile, []byte(configContent), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	InitConfig(configFile, false)
+
+	equals(t, 1, len(Configuration.TileMatrixSets), "Number of tile matrix sets")
+	tms := Configuration.TileMatrixSets[0]
+	equals(t, "LAEA", tms.Id, "Id")
+	equals(t, 3035, tms.Srid, "Srid")
+	equals(t, []float64{2000000, 1000000, 6500000, 5500000}, tms.Extent, "Extent")
+	equals(t, []float64{17578.125, 8789.0625}, tms.Resolutions, "Resolutions")
+	equals(t, 0, len(tms.Origin), "Origin")
+	equals(t, "LAEA", Configuration.LayerConfig("roads").TileMatrixSet, "Layer TileMatrixSet")
+}
+
 // TestDefaultValues tests that default values are used when no config file or environment variables are set
 func TestDefaultValues(t *testing.T) {
 	clearConfigEnvVars()
@@ -96,6 +96,10 @@ type Table struct {
 	DbTypes        map[string]string
 	JSONTypes      []string
 	ColDesc        []string
+	// Filter is the configured SQL condition restricting the features served,
+	// with the arguments of its parameters
+	Filter     string
+	FilterArgs []interface{}
 }
 
 // Extent of a table
@@ -12,6 +12,7 @@ import (
 
 	_ "github.com/duckdb/duckdb-go/v2"
 	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/archive"
 	"github.com/tobilg/duckdb-tileserver/internal/conf"
 )
 
@@ -40,24 +41,35 @@ type CatalogDB struct {
 	tableExcludes map[string]string
 	tables        []*Table
 	tableMap      map[string]*Table
+	tablesMutex   sync.RWMutex
 	functions     []*Function
 	functionMap   map[string]*Function
 
+	// Tables whose extent has been loaded
+	extentLoaded map[string]bool
+	extentMutex  sync.Mutex
+
 	// Layer metadata cache (infinite cache - no expiration)
 	layerMetadataCache map[string]*Layer
 	layerCacheMutex    sync.RWMutex
+
+	// Bounds of the layers in Web Mercator, computed on first use
+	// (guarded by layerCacheMutex, and discarded with the layer metadata)
+	layerBounds map[string]*Extent
+
+	// Tile archives of archive layers, by path (opened on first use)
+	archives     map[string]archive.Reader
+	archiveMutex sync.Mutex
+
+	// Parent tiles of overzoomed tiles
+	parentTiles parentTileCache
 }
 
-var isStartup bool
 var isFunctionsLoaded bool
 var instanceDB *CatalogDB
 
 const fmtQueryStats = "Database query result: %v rows in %v"
 
-func init() {
-	isStartup = true
-}
-
 // CatDBInstance tbd
 func CatDBInstance() Catalog {
 	// TODO: make a singleton
@@ -73,7 +85,9 @@ func newCatalogDB() *CatalogDB {
 	cat := &CatalogDB{
 		dbconn:             conn,
 		dbPath:             dbPath,
+		extentLoaded:       make(map[string]bool),
 		layerMetadataCache: make(map[string]*Layer),
+		archives:           make(map[string]archive.Reader),
 	}
 	log.Info("Layer metadata cache initialized")
 	return cat
@@ -141,6 +155,7 @@ func (cat *CatalogDB) SetIncludeExclude(includeList []string, excludeList []stri
 }
 
 func (cat *CatalogDB) Close() {
+	cat.closeArchives()
 	cat.dbconn.Close()
 }
 
@@ -153,10 +168,12 @@ func (cat *CatalogDB) InvalidateLayerMetadataCache(layerName string) {
 	if layerName == "" {
 		// Clear entire cache
 		cat.layerMetadataCache = make(map[string]*Layer)
+		cat.layerBounds = nil
 		log.Info("Layer metadata cache cleared (all layers)")
 	} else {
 		// Clear specific layer
 		delete(cat.layerMetadataCache, layerName)
+		delete(cat.layerBounds, layerName)
 		log.Infof("Layer metadata cache cleared for: %s", layerName)
 	}
 }
@@ -181,26 +198,66 @@ func getLayerNames(cache map[string]*Layer) []string {
 	return names
 }
 
+// Tables returns the tables with geometry columns, with their extents.
+// The tables are read once; the extents are loaded on first use.
 func (cat *CatalogDB) Tables() ([]*Table, error) {
-	cat.refreshTables(true)
-	return cat.tables, nil
+	cat.loadTables()
+	cat.tablesMutex.RLock()
+	names := make([]string, 0, len(cat.tables))
+	for _, tbl := range cat.tables {
+		names = append(names, tbl.ID)
+	}
+	cat.tablesMutex.RUnlock()
+
+	for _, name := range names {
+		cat.TableReload(name)
+	}
+
+	cat.tablesMutex.RLock()
+	defer cat.tablesMutex.RUnlock()
+	return append([]*Table{}, cat.tables...), nil
 }
 
+// TableReload loads the extent of a table.
+// The extent is computed once per table (or after the table data changed),
+// since it requires an aggregation over the whole table.
+// Published tables are not modified: the table is replaced by a copy with the extent.
 func (cat *CatalogDB) TableReload(name string) {
-	tbl, ok := cat.tableMap[name]
-	if !ok {
+	cat.extentMutex.Lock()
+	defer cat.extentMutex.Unlock()
+
+	if cat.extentLoaded[name] {
 		return
 	}
-	// load extent (which may change over time
-	sqlExtentEst := sqlExtentEstimated(tbl)
-	isExtentLoaded := cat.loadExtent(sqlExtentEst, tbl)
+	loaded, err := cat.TableByName(name)
+	if err != nil || loaded == nil {
+		return
+	}
+	cat.extentLoaded[name] = true
+	tbl := *loaded
+	sqlExtentEst := sqlExtentEstimated(&tbl)
+	isExtentLoaded := cat.loadExtent(sqlExtentEst, &tbl)
 	if !isExtentLoaded {
 		log.Debugf("Can't get estimated extent for %s", name)
-		sqlExtentExact := sqlExtentExact(tbl)
-		cat.loadExtent(sqlExtentExact, tbl)
+		sqlExtentExact := sqlExtentExact(&tbl)
+		cat.loadExtent(sqlExtentExact, &tbl)
+	}
+
+	cat.tablesMutex.Lock()
+	defer cat.tablesMutex.Unlock()
+	if cat.tableMap[name] == loaded {
+		cat.tableMap[name] = &tbl
+		cat.tables = tablesSorted(cat.tableMap)
 	}
 }
 
+// resetTableExtent discards the extent of a table, so that it is computed again on next use
+func (cat *CatalogDB) resetTableExtent(name string) {
+	cat.extentMutex.Lock()
+	defer cat.extentMutex.Unlock()
+	delete(cat.extentLoaded, name)
+}
+
 func (cat *CatalogDB) loadExtent(sql string, tbl *Table) bool {
 	var (
 		xmin *float64
@@ -226,7 +283,9 @@ func (cat *CatalogDB) loadExtent(sql string, tbl *Table) bool {
 }
 
 func (cat *CatalogDB) TableByName(name string) (*Table, error) {
-	cat.refreshTables(false)
+	cat.loadTables()
+	cat.tablesMutex.RLock()
+	defer cat.tablesMutex.RUnlock()
 	tbl, ok := cat.tableMap[name]
 	if !ok {
 		return nil, nil
@@ -240,9 +299,9 @@ func (cat *CatalogDB) TableFeatures(ctx context.Context, name string, param *Que
 		return nil, err
 	}
 	cols := param.Columns
-	sql, argValues := sqlFeatures(tbl, param)
+	queryParam, idColIndex := tbl.withIDColumn(param)
+	sql, argValues := sqlFeatures(tbl, queryParam)
 	log.Debug("Features query: " + sql)
-	idColIndex := indexOfName(cols, tbl.IDColumn)
 
 	features, err := readFeaturesWithArgs(ctx, cat.dbconn, sql, argValues, idColIndex, cols)
 	return features, err
@@ -250,17 +309,14 @@ func (cat *CatalogDB) TableFeatures(ctx context.Context, name string, param *Que
 
 func (cat *CatalogDB) TableFeature(ctx context.Context, name string, id string, param *QueryParam) (string, error) {
 	tbl, err := cat.TableByName(name)
-	if err != nil {
+	if err != nil || tbl == nil || tbl.IDColumn == "" {
 		return "", err
 	}
 	cols := param.Columns
-	sql := sqlFeature(tbl, param)
+	queryParam, idColIndex := tbl.withIDColumn(param)
+	sql, argValues := sqlFeature(tbl, queryParam, id)
 	log.Debug("Feature query: " + sql)
-	idColIndex := indexOfName(cols, tbl.IDColumn)
 
-	//--- Add a SQL arg for the feature ID
-	argValues := make([]interface{}, 0)
-	argValues = append(argValues, id)
 	features, err := readFeaturesWithArgs(ctx, cat.dbconn, sql, argValues, idColIndex, cols)
 
 	if len(features) == 0 {
@@ -269,15 +325,35 @@ func (cat *CatalogDB) TableFeature(ctx context.Context, name string, id string,
 	return features[0], nil
 }
 
-func (cat *CatalogDB) refreshTables(force bool) {
-	// TODO: refresh on timed basis?
-	if force || isStartup {
-		cat.loadTables()
-		isStartup = false
+// withIDColumn returns the query parameters with the ID column added to the queried columns
+// (if not already present), and the index of the ID column (-1 if the table has none)
+func (tbl *Table) withIDColumn(param *QueryParam) (*QueryParam, int) {
+	if tbl.IDColumn == "" {
+		return param, -1
 	}
+	idColIndex := indexOfName(param.Columns, tbl.IDColumn)
+	if idColIndex >= 0 {
+		return param, idColIndex
+	}
+	queryParam := *param
+	queryParam.Columns = append(append([]string{}, param.Columns...), tbl.IDColumn)
+	return &queryParam, len(param.Columns)
 }
 
+// loadTables reads the tables with geometry columns, if not read yet
 func (cat *CatalogDB) loadTables() {
+	cat.tablesMutex.RLock()
+	loaded := cat.tableMap != nil
+	cat.tablesMutex.RUnlock()
+	if loaded {
+		return
+	}
+
+	cat.tablesMutex.Lock()
+	defer cat.tablesMutex.Unlock()
+	if cat.tableMap != nil {
+		return
+	}
 	cat.tableMap = cat.readTables(cat.dbconn)
 	cat.tables = tablesSorted(cat.tableMap)
 }
@@ -366,6 +442,17 @@ func scanTable(db *sql.DB, rows *sql.Rows) *Table {
 	// For DuckDB, we'll get column information through a separate query
 	columns, datatypes, jsontypes, colDesc := getTableColumns(db, table)
 
+	// SRIDs are not stored in the catalog, so use the configured SRID or detect it from the data
+	srid = configuredSrid(table)
+	if srid <= 0 {
+		srid = detectSourceSrid(db, fmt.Sprintf("\"%s\"", geometryCol), fmt.Sprintf("\"%s\"", table))
+	}
+
+	// use an "id" column as feature id if the table has no primary key
+	if idColumn == "" && indexOfName(columns, "id") >= 0 {
+		idColumn = "id"
+	}
+
 	// Synthesize a title for now
 	title := id
 	// synthesize a description if none provided
@@ -373,7 +460,7 @@ func scanTable(db *sql.DB, rows *sql.Rows) *Table {
 		description = fmt.Sprintf("Data for table %v", id)
 	}
 
-	return &Table{
+	tbl := &Table{
 		ID:             id,
 		Schema:         schema,
 		Table:          table,
@@ -388,13 +475,45 @@ func scanTable(db *sql.DB, rows *sql.Rows) *Table {
 		JSONTypes:      jsontypes,
 		ColDesc:        colDesc,
 	}
+	applyTableLayerConfig(tbl)
+	return tbl
+}
+
+// applyTableLayerConfig applies the [[Layers]] configuration section (if any) of a table:
+// the feature filter, and the allow-list of the properties.
+// The filter parameters (if any) are bound to their defaults.
+func applyTableLayerConfig(tbl *Table) {
+	layerConf := conf.Configuration.LayerConfig(tbl.ID)
+	if layerConf == nil {
+		return
+	}
+	if layerConf.Filter != "" {
+		tbl.Filter = layerConf.Filter
+		tbl.FilterArgs = (&Layer{Name: tbl.ID, Parameters: layerParameters(layerConf)}).defaultArgs()
+	}
+	if len(layerConf.Properties) == 0 {
+		return
+	}
+	allowed := make(map[string]bool)
+	for _, prop := range layerConf.Properties {
+		allowed[prop] = true
+	}
+	var columns, jsonTypes, colDesc []string
+	for i, col := range tbl.Columns {
+		if allowed[col] {
+			columns = append(columns, col)
+			jsonTypes = append(jsonTypes, tbl.JSONTypes[i])
+			colDesc = append(colDesc, tbl.ColDesc[i])
+		}
+	}
+	tbl.Columns, tbl.JSONTypes, tbl.ColDesc = columns, jsonTypes, colDesc
 }
 
 func getTableColumns(db *sql.DB, tableName string) ([]string, map[string]string, []string, []string) {
 	query := `SELECT column_name, data_type 
 	          FROM information_schema.columns 
 	          WHERE table_name = ? 
-	          AND column_name != 'geom'
+	          AND data_type != 'GEOMETRY'
 	          ORDER BY ordinal_position`
 
 	rows, err := db.Query(query, tableName)
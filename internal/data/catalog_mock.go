@@ -359,6 +359,9 @@ func doLimit(features []*featureMock, limit int, offset int) []*featureMock {
 		if end >= len(features) {
 			end = len(features)
 		}
+		if start > end {
+			start = end
+		}
 	}
 	return features[start:end]
 }
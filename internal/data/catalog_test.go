@@ -1,8 +1,12 @@
 package data
 
 import (
+	"database/sql"
 	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
 )
 
 // TestTableIncludeExcludeLogic tests the table filtering logic
@@ -596,3 +600,90 @@ func TestTableExcludesOnlyScenarios(t *testing.T) {
 		})
 	}
 }
+
+// TestTransformToOutCrs tests the geometry transformation between SRIDs
+func TestTransformToOutCrs(t *testing.T) {
+	testEquals(t, `"geom"`, transformToOutCrs(`"geom"`, 4326, 4326), "Same SRID")
+	testEquals(t, `"geom"`, transformToOutCrs(`"geom"`, SRID_UNKNOWN, 4326), "Unknown source SRID")
+	testEquals(t, `ST_Transform("geom", 'EPSG:3857', 'EPSG:4326', always_xy := true)`,
+		transformToOutCrs(`"geom"`, 3857, 4326), "Web Mercator to WGS84")
+}
+
+// TestSqlBBoxFilter tests that the bbox is transformed to the table SRID
+func TestSqlBBoxFilter(t *testing.T) {
+	bbox := &Extent{Minx: 1, Miny: 2, Maxx: 3, Maxy: 4}
+	polygon := `ST_GeomFromText('POLYGON((1 2, 3 2, 3 4, 1 4, 1 2))')`
+
+	testEquals(t, "", sqlBBoxFilter("geom", 4326, nil, 4326), "No bbox")
+	testEquals(t, fmt.Sprintf(` ST_Intersects("geom", %s) `, polygon),
+		sqlBBoxFilter("geom", 4326, bbox, 4326), "Same SRID")
+	testEquals(t, fmt.Sprintf(` ST_Intersects("geom", ST_Transform(%s, 'EPSG:4326', 'EPSG:3857', always_xy := true)) `, polygon),
+		sqlBBoxFilter("geom", 3857, bbox, 4326), "Transformed bbox")
+}
+
+// TestWithIDColumn tests that the ID column is queried with the requested columns
+func TestWithIDColumn(t *testing.T) {
+	tbl := &Table{IDColumn: "id"}
+
+	param := &QueryParam{Columns: []string{"name", "id"}}
+	queryParam, idx := tbl.withIDColumn(param)
+	testEquals(t, []string{"name", "id"}, queryParam.Columns, "ID column requested - columns")
+	testEquals(t, 1, idx, "ID column requested - index")
+
+	param = &QueryParam{Columns: []string{"name"}}
+	queryParam, idx = tbl.withIDColumn(param)
+	testEquals(t, []string{"name", "id"}, queryParam.Columns, "ID column added - columns")
+	testEquals(t, 1, idx, "ID column added - index")
+	testEquals(t, []string{"name"}, param.Columns, "ID column added - original columns")
+
+	_, idx = (&Table{}).withIDColumn(param)
+	testEquals(t, -1, idx, "No ID column")
+}
+
+// TestApplyTableLayerConfig tests that the layer configuration restricts the collection properties and features
+func TestApplyTableLayerConfig(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+	conf.Configuration.Layers = []conf.Layer{{
+		Name:       "roads",
+		Properties: []string{"name", "class"},
+		Filter:     "class <> $excluded",
+		Parameters: []conf.LayerParameter{{Name: "excluded", Default: "path"}},
+	}}
+
+	tbl := &Table{
+		ID:        "roads",
+		Columns:   []string{"id", "class", "secret", "name"},
+		JSONTypes: []string{JSONTypeNumber, JSONTypeString, JSONTypeString, JSONTypeString},
+		ColDesc:   []string{"id", "class", "secret", "name"},
+	}
+	applyTableLayerConfig(tbl)
+	testEquals(t, []string{"class", "name"}, tbl.Columns, "Columns")
+	testEquals(t, []string{JSONTypeString, JSONTypeString}, tbl.JSONTypes, "JSON types")
+	testEquals(t, []string{"class", "name"}, tbl.ColDesc, "Column descriptions")
+	testEquals(t, "class <> $excluded", tbl.Filter, "Filter")
+	testEquals(t, sql.Named("excluded", "path"), tbl.FilterArgs[len(tbl.FilterArgs)-1], "Filter parameter")
+
+	other := &Table{ID: "water", Columns: []string{"id", "name"}}
+	applyTableLayerConfig(other)
+	testEquals(t, []string{"id", "name"}, other.Columns, "Unconfigured table columns")
+	testEquals(t, "", other.Filter, "Unconfigured table filter")
+}
+
+// TestSqlFeaturesFilter tests that the property filters and the table filter are combined
+func TestSqlFeaturesFilter(t *testing.T) {
+	filterArg := sql.Named("excluded", "path")
+	tbl := &Table{Table: "roads", GeometryColumn: "geom", Srid: 4326, IDColumn: "id",
+		Filter: "class <> $excluded", FilterArgs: []interface{}{filterArg}}
+	param := &QueryParam{Crs: 4326, Precision: -1, Filter: []*PropertyFilter{{Name: "name", Value: "Main"}}}
+
+	query, args := sqlFeatures(tbl, param)
+	testEquals(t, true, strings.Contains(query, ` WHERE "name" = $item_attr_1 AND (class <> $excluded)`), "Features query: "+query)
+	testEquals(t, []interface{}{sql.Named("item_attr_1", "Main"), filterArg}, args, "Features arguments")
+
+	query, args = sqlFeature(tbl, param, "42")
+	testEquals(t, true, strings.Contains(query, ` WHERE "id" = $item_id AND (class <> $excluded) LIMIT 1`), "Feature query: "+query)
+	testEquals(t, []interface{}{sql.Named("item_id", "42"), filterArg}, args, "Feature arguments")
+}
@@ -0,0 +1,252 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+)
+
+// databaseMarker is the change marker key of the layers read from the database
+// (tables, views and query layers without a version query)
+const databaseMarker = ""
+
+// ChangeMarkersFile is the name of the file of the disk cache directory
+// storing the change markers of the layers of the cached tiles
+const ChangeMarkersFile = "change-markers.json"
+
+// WatchChanges starts polling the change markers of the layer data every interval until ctx is done.
+// The change marker of a layer is the result of its version query if configured,
+// the modification time of its archive or local source files,
+// and else the modification time of the database file.
+// Remote sources (URLs and table functions) are only tracked through a version query.
+// Changed layers are removed from the layer metadata cache, and passed to onChange.
+//
+// If markersPath is set, the markers are stored in that file, and on start the layers
+// which changed since the stored markers were read are passed to onChange,
+// before WatchChanges returns.
+func (cat *CatalogDB) WatchChanges(ctx context.Context, interval time.Duration, markersPath string, onChange func(layerNames []string)) {
+	markers := cat.changeMarkers(ctx)
+	if markersPath != "" {
+		// the data may have changed while the server was stopped
+		stored, err := readChangeMarkers(markersPath)
+		switch {
+		case err == nil:
+			cat.applyChanges(stored, markers, onChange)
+		case !errors.Is(err, fs.ErrNotExist):
+			log.Warnf("Error reading change markers %s: %v", markersPath, err)
+		}
+		writeChangeMarkers(markersPath, markers)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current := cat.changeMarkers(ctx)
+			cat.applyChanges(markers, current, onChange)
+			if markersPath != "" && !maps.Equal(markers, current) {
+				writeChangeMarkers(markersPath, current)
+			}
+			markers = current
+		}
+	}()
+}
+
+// applyChanges reloads the layers whose change markers differ, and passes them to onChange
+func (cat *CatalogDB) applyChanges(previous, current map[string]string, onChange func(layerNames []string)) {
+	changed := cat.changedLayers(previous, current)
+	if len(changed) == 0 {
+		return
+	}
+	log.Infof("Data changed for layers: %s", strings.Join(changed, ", "))
+	for _, name := range changed {
+		cat.reloadLayer(name)
+	}
+	onChange(changed)
+}
+
+// readChangeMarkers reads change markers stored in a file
+func readChangeMarkers(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	markers := make(map[string]string)
+	if err := json.Unmarshal(content, &markers); err != nil {
+		return nil, err
+	}
+	return markers, nil
+}
+
+// writeChangeMarkers stores change markers in a file.
+// The file is replaced atomically, so that it is complete if the server is stopped.
+func writeChangeMarkers(path string, markers map[string]string) {
+	content, err := json.Marshal(markers)
+	if err == nil {
+		tmpPath := path + ".tmp"
+		if err = os.WriteFile(tmpPath, content, 0o644); err == nil {
+			err = os.Rename(tmpPath, path)
+		}
+	}
+	if err != nil {
+		log.Warnf("Error writing change markers %s: %v", path, err)
+	}
+}
+
+// changeMarkers returns the current change markers, by layer name.
+// Markers which can't be read are omitted.
+func (cat *CatalogDB) changeMarkers(ctx context.Context) map[string]string {
+	markers := make(map[string]string)
+	if marker, err := filesMarker(cat.dbPath); err == nil {
+		markers[databaseMarker] = marker
+	}
+	for i := range conf.Configuration.Layers {
+		layerConf := &conf.Configuration.Layers[i]
+		marker, err := cat.layerChangeMarker(ctx, layerConf)
+		if err != nil {
+			log.Warnf("Error reading change marker of layer %s: %v", layerConf.Name, err)
+			continue
+		}
+		if marker != "" {
+			markers[layerConf.Name] = marker
+		}
+	}
+	return markers
+}
+
+// layerChangeMarker returns the change marker of a configured layer,
+// or "" if the layer data is read from the database
+func (cat *CatalogDB) layerChangeMarker(ctx context.Context, layerConf *conf.Layer) (string, error) {
+	switch {
+	case layerConf.VersionQuery != "":
+		var version interface{}
+		if err := cat.dbconn.QueryRowContext(ctx, layerConf.VersionQuery).Scan(&version); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("version:%v", version), nil
+	case layerConf.Archive != "":
+		return filesMarker(layerConf.Archive)
+	case layerConf.Source != "" && isLocalSource(layerConf.Source):
+		return filesMarker(strings.TrimSpace(layerConf.Source))
+	}
+	return "", nil
+}
+
+// changedLayers returns the names of the layers whose change markers differ
+func (cat *CatalogDB) changedLayers(previous, current map[string]string) []string {
+	changed := make(map[string]bool)
+	for name, marker := range current {
+		if name != databaseMarker && previous[name] != marker {
+			changed[name] = true
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok && name != databaseMarker {
+			// e.g. the files of the layer were removed
+			changed[name] = true
+		}
+	}
+	if current[databaseMarker] != previous[databaseMarker] {
+		for _, name := range cat.databaseLayerNames() {
+			if _, ownMarker := current[name]; !ownMarker {
+				changed[name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// databaseLayerNames returns the names of the layers which may be read from the database:
+// the tables with geometry columns, the query layers and the layers requested so far
+func (cat *CatalogDB) databaseLayerNames() []string {
+	cat.layerCacheMutex.RLock()
+	names := getLayerNames(cat.layerMetadataCache)
+	cat.layerCacheMutex.RUnlock()
+
+	for _, layerConf := range conf.Configuration.Layers {
+		if layerConf.Sql != "" {
+			names = append(names, layerConf.Name)
+		}
+	}
+
+	rows, err := cat.dbconn.Query("SELECT DISTINCT table_name FROM duckdb_columns WHERE data_type = 'GEOMETRY'")
+	if err != nil {
+		log.Warnf("Error querying layers: %v", err)
+		return names
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err == nil && cat.isTableIncluded(tableName) {
+			names = append(names, tableName)
+		}
+	}
+	return names
+}
+
+// reloadLayer discards the cached metadata, collection extent, parent tiles (and archive)
+// of a changed layer, so that they are read again on the next request
+func (cat *CatalogDB) reloadLayer(name string) {
+	cat.InvalidateLayerMetadataCache(name)
+	cat.resetTableExtent(name)
+	cat.ClearParentTiles(name)
+	if layerConf := conf.Configuration.LayerConfig(name); layerConf != nil && layerConf.Archive != "" {
+		cat.closeArchive(layerConf.Archive)
+	}
+}
+
+// isLocalSource tests whether a layer source is a local file path or glob
+// (and not a table function or a remote URL)
+func isLocalSource(source string) bool {
+	return !strings.Contains(source, "(") && !strings.Contains(source, "://")
+}
+
+// filesMarker returns a change marker of the files matching a path or glob
+// (their names, sizes and modification times).
+// For a DuckDB database, the write-ahead log is included.
+func filesMarker(pattern string) (string, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no files found: %s", pattern)
+	}
+	if len(files) == 1 && !strings.ContainsAny(pattern, "*?[") {
+		if _, err := os.Stat(pattern + ".wal"); err == nil {
+			files = append(files, pattern+".wal")
+		}
+	}
+	sort.Strings(files)
+
+	var marker strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&marker, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return marker.String(), nil
+}
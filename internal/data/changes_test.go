@@ -0,0 +1,204 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tobilg/duckdb-tileserver/internal/archive"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+)
+
+func TestFilesMarker(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.parquet")
+	if err := os.WriteFile(file, []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	marker, err := filesMarker(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// touching the file changes the marker
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	touched, _ := filesMarker(file)
+	if touched == marker {
+		t.Error("Expected marker to change with the modification time")
+	}
+
+	// a new file matching a glob changes the marker
+	glob := filepath.Join(dir, "*.parquet")
+	globMarker, _ := filesMarker(glob)
+	if err := os.WriteFile(filepath.Join(dir, "b.parquet"), []byte("b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if newMarker, _ := filesMarker(glob); newMarker == globMarker {
+		t.Error("Expected marker to change with a new file")
+	}
+
+	if _, err := filesMarker(filepath.Join(dir, "missing.parquet")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+	testEquals(t, true, isLocalSource("data/*.parquet"), "Local glob")
+	testEquals(t, false, isLocalSource("s3://bucket/*.parquet"), "Remote files")
+	testEquals(t, false, isLocalSource("read_parquet('data/*.parquet')"), "Table function")
+}
+
+func TestChangedLayers(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE versions (layer VARCHAR, version INTEGER); INSERT INTO versions VALUES ('stats', 1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "tiles.db")
+	source := filepath.Join(dir, "points.parquet")
+	for _, file := range []string{dbFile, source} {
+		if err := os.WriteFile(file, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conf.Configuration.Layers = []conf.Layer{
+		{Name: "points", Source: source},
+		{Name: "stats", Sql: "SELECT * FROM stats", VersionQuery: "SELECT version FROM versions WHERE layer = 'stats'"},
+		{Name: "districts", Sql: "SELECT * FROM districts"},
+	}
+	cat := &CatalogDB{
+		dbconn:             db,
+		dbPath:             dbFile,
+		layerMetadataCache: map[string]*Layer{"roads": {Name: "roads"}, "points": {Name: "points"}},
+		archives:           make(map[string]archive.Reader),
+	}
+	ctx := context.Background()
+	touch := func(file string, offset time.Duration) {
+		mtime := time.Now().Add(offset)
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	markers := cat.changeMarkers(ctx)
+	testEquals(t, 3, len(markers), "Markers (database, points, stats)")
+	testEquals(t, []string{}, cat.changedLayers(markers, cat.changeMarkers(ctx)), "Unchanged")
+
+	if _, err := db.Exec("UPDATE versions SET version = 2"); err != nil {
+		t.Fatal(err)
+	}
+	current := cat.changeMarkers(ctx)
+	testEquals(t, []string{"stats"}, cat.changedLayers(markers, current), "Version query changed")
+	markers = current
+
+	touch(source, time.Minute)
+	current = cat.changeMarkers(ctx)
+	testEquals(t, []string{"points"}, cat.changedLayers(markers, current), "Source file changed")
+	markers = current
+
+	// tables, query layers and requested layers read from the database
+	touch(dbFile, 2*time.Minute)
+	current = cat.changeMarkers(ctx)
+	testEquals(t, []string{"districts", "roads"}, cat.changedLayers(markers, current), "Database changed")
+	markers = current
+
+	os.Remove(source)
+	testEquals(t, []string{"points"}, cat.changedLayers(markers, cat.changeMarkers(ctx)), "Source file removed")
+}
+
+func TestWatchChanges(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+
+	source := filepath.Join(t.TempDir(), "points.parquet")
+	if err := os.WriteFile(source, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	conf.Configuration.Layers = []conf.Layer{{Name: "points", Source: source}}
+	cat := &CatalogDB{
+		layerMetadataCache: map[string]*Layer{"points": {Name: "points"}},
+		archives:           make(map[string]archive.Reader),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan []string, 1)
+	cat.WatchChanges(ctx, 10*time.Millisecond, "", func(layerNames []string) {
+		changes <- layerNames
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	mtime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(source, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case layerNames := <-changes:
+		testEquals(t, []string{"points"}, layerNames, "Changed layers")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Change not detected")
+	}
+	cat.layerCacheMutex.RLock()
+	_, cached := cat.layerMetadataCache["points"]
+	cat.layerCacheMutex.RUnlock()
+	testEquals(t, false, cached, "Layer metadata invalidated")
+}
+
+func TestWatchChangesStoredMarkers(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "points.parquet")
+	if err := os.WriteFile(source, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	conf.Configuration.Layers = []conf.Layer{{Name: "points", Source: source}}
+	cat := &CatalogDB{
+		layerMetadataCache: map[string]*Layer{"points": {Name: "points"}},
+		extentLoaded:       map[string]bool{"points": true},
+		archives:           make(map[string]archive.Reader),
+	}
+	markersPath := filepath.Join(dir, ChangeMarkersFile)
+	var changed []string
+	onChange := func(layerNames []string) {
+		changed = append(changed, layerNames...)
+	}
+
+	// the markers are stored on the first start
+	ctx, cancel := context.WithCancel(context.Background())
+	cat.WatchChanges(ctx, time.Hour, markersPath, onChange)
+	cancel()
+	if _, err := os.Stat(markersPath); err != nil {
+		t.Fatalf("Expected stored change markers: %v", err)
+	}
+	testEquals(t, 0, len(changed), "Changed layers on first start")
+
+	// the source changes while the server is stopped
+	mtime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(source, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	cat.WatchChanges(ctx, time.Hour, markersPath, onChange)
+	testEquals(t, []string{"points"}, changed, "Layers changed while stopped")
+	testEquals(t, false, cat.extentLoaded["points"], "Collection extent reset")
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"database/sql"
 	"fmt"
 	"strconv"
 	"strings"
@@ -12,18 +13,20 @@ const forceTextTSVECTOR = "tsvector"
 
 const sqlTables = `
 SELECT 
-    table_name AS id,
+    c.table_name AS id,
     'main' AS schema,
-    table_name AS table,
+    c.table_name AS table,
     '' AS description,
-    column_name AS geometry_column,
+    c.column_name AS geometry_column,
     4326 AS srid,
     'GEOMETRY' AS geometry_type,
-    '' AS id_column,
+    COALESCE((SELECT k.constraint_column_names[1] FROM duckdb_constraints() k
+        WHERE k.table_name = c.table_name AND k.constraint_type = 'PRIMARY KEY'
+        AND len(k.constraint_column_names) = 1 LIMIT 1), '') AS id_column,
     '[]' AS props
-FROM information_schema.columns 
-WHERE data_type = 'GEOMETRY'
-ORDER BY table_name
+FROM information_schema.columns c
+WHERE c.data_type = 'GEOMETRY'
+ORDER BY c.table_name
 `
 
 const sqlFunctionsTemplate = `
@@ -59,18 +62,21 @@ func quotedList(names []string) string {
 	return "'" + itemsJoin + "'"
 }
 
+// extents are always reported in EPSG:4326
 const sqlFmtExtentEst = `SELECT ST_XMin(ext.geom) AS xmin, ST_YMin(ext.geom) AS ymin, ST_XMax(ext.geom) AS xmax, ST_YMax(ext.geom) AS ymax
-FROM ( SELECT ST_Envelope_Agg("%s") AS geom FROM "%s" ) AS ext;`
+FROM ( SELECT %s AS geom FROM "%s" ) AS ext;`
 
 func sqlExtentEstimated(tbl *Table) string {
-	return fmt.Sprintf(sqlFmtExtentEst, tbl.GeometryColumn, tbl.Table)
+	envelope := fmt.Sprintf(`ST_Envelope_Agg("%s")`, tbl.GeometryColumn)
+	return fmt.Sprintf(sqlFmtExtentEst, transformToOutCrs(envelope, tbl.Srid, SRID_4326), tbl.Table)
 }
 
 const sqlFmtExtentExact = `SELECT ST_XMin(ext.geom) AS xmin, ST_YMin(ext.geom) AS ymin, ST_XMax(ext.geom) AS xmax, ST_YMax(ext.geom) AS ymax
-FROM (SELECT COALESCE(ST_Envelope_Agg("%s"), ST_GeomFromText('POLYGON((-180 -90, 180 -90, 180 90, -180 90, -180 -90))', 4326)) AS geom FROM "%s" ) AS ext;`
+FROM (SELECT COALESCE(%s, ST_GeomFromText('POLYGON((-180 -90, 180 -90, 180 90, -180 90, -180 -90))', 4326)) AS geom FROM "%s" ) AS ext;`
 
 func sqlExtentExact(tbl *Table) string {
-	return fmt.Sprintf(sqlFmtExtentExact, tbl.GeometryColumn, tbl.Table)
+	envelope := fmt.Sprintf(`ST_Envelope_Agg("%s")`, tbl.GeometryColumn)
+	return fmt.Sprintf(sqlFmtExtentExact, transformToOutCrs(envelope, tbl.Srid, SRID_4326), tbl.Table)
 }
 
 const sqlFmtFeatures = "SELECT %v %v FROM \"%s\" %v %v %v %s;"
@@ -78,15 +84,15 @@ const sqlFmtFeatures = "SELECT %v %v FROM \"%s\" %v %v %v %s;"
 func sqlFeatures(tbl *Table, param *QueryParam) (string, []interface{}) {
 	geomCol := sqlGeomCol(tbl.GeometryColumn, tbl.Srid, param)
 	propCols := sqlColList(param.Columns, tbl.DbTypes, true)
-	bboxFilter := sqlBBoxFilter(tbl.GeometryColumn, param.Bbox, param.BboxCrs)
+	bboxFilter := sqlBBoxFilter(tbl.GeometryColumn, tbl.Srid, param.Bbox, param.BboxCrs)
 	attrFilter, attrVals := sqlAttrFilter(param.Filter)
 	cqlFilter := sqlCqlFilter(param.FilterSql)
-	sqlWhere := sqlWhere(bboxFilter, attrFilter, cqlFilter)
+	sqlWhere := sqlWhere(bboxFilter, attrFilter, cqlFilter, sqlCqlFilter(tbl.Filter))
 	sqlGroupBy := sqlGroupBy(param.GroupBy)
 	sqlOrderBy := sqlOrderBy(param.SortBy)
 	sqlLimitOffset := sqlLimitOffset(param.Limit, param.Offset)
 	sql := fmt.Sprintf(sqlFmtFeatures, geomCol, propCols, tbl.Table, sqlWhere, sqlGroupBy, sqlOrderBy, sqlLimitOffset)
-	return sql, attrVals
+	return sql, append(attrVals, tbl.FilterArgs...)
 }
 
 // sqlColList creates a comma-separated column list, or blank if no columns
@@ -129,13 +135,17 @@ func sqlColExpr(name string, dbtype string) string {
 	return name
 }
 
-const sqlFmtFeature = "SELECT %v %v FROM \"%s\" WHERE \"%v\" = $1 LIMIT 1"
+const sqlFmtFeature = "SELECT %v %v FROM \"%s\" %v LIMIT 1"
 
-func sqlFeature(tbl *Table, param *QueryParam) string {
+// sqlFeature returns the query of the feature with given id,
+// and the query arguments
+func sqlFeature(tbl *Table, param *QueryParam, id string) (string, []interface{}) {
 	geomCol := sqlGeomCol(tbl.GeometryColumn, tbl.Srid, param)
 	propCols := sqlColList(param.Columns, tbl.DbTypes, true)
-	sql := fmt.Sprintf(sqlFmtFeature, geomCol, propCols, tbl.Table, tbl.IDColumn)
-	return sql
+	idFilter := fmt.Sprintf("\"%v\" = $item_id", tbl.IDColumn)
+	sqlWhere := sqlWhere(idFilter, sqlCqlFilter(tbl.Filter))
+	query := fmt.Sprintf(sqlFmtFeature, geomCol, propCols, tbl.Table, sqlWhere)
+	return query, append([]interface{}{sql.Named("item_id", id)}, tbl.FilterArgs...)
 }
 
 func sqlCqlFilter(sql string) string {
@@ -146,16 +156,13 @@ func sqlCqlFilter(sql string) string {
 	return "(" + sql + ")"
 }
 
-func sqlWhere(cond1 string, cond2 string, cond3 string) string {
+// sqlWhere creates a WHERE clause combining the non-empty conditions
+func sqlWhere(conds ...string) string {
 	var condList []string
-	if len(cond1) > 0 {
-		condList = append(condList, cond1)
-	}
-	if len(cond2) > 0 {
-		condList = append(condList, cond2)
-	}
-	if len(cond3) > 0 {
-		condList = append(condList, cond3)
+	for _, cond := range conds {
+		if len(cond) > 0 {
+			condList = append(condList, cond)
+		}
 	}
 	where := strings.Join(condList, " AND ")
 	if len(where) > 0 {
@@ -164,28 +171,36 @@ func sqlWhere(cond1 string, cond2 string, cond3 string) string {
 	return where
 }
 
+// sqlAttrFilter creates a condition on property values.
+// The values are bound by name ($item_attr_1, $item_attr_2, ...), so that they can be combined
+// with the named parameters of the layer filter.
 func sqlAttrFilter(filterConds []*PropertyFilter) (string, []interface{}) {
 	var vals []interface{}
 	var exprItems []string
 	for i, cond := range filterConds {
-		sqlCond := fmt.Sprintf("\"%v\" = $%v", cond.Name, i+1)
+		name := fmt.Sprintf("item_attr_%d", i+1)
+		sqlCond := fmt.Sprintf("\"%v\" = $%v", cond.Name, name)
 		exprItems = append(exprItems, sqlCond)
-		vals = append(vals, cond.Value)
+		vals = append(vals, sql.Named(name, cond.Value))
 	}
-	sql := strings.Join(exprItems, " AND ")
-	return sql, vals
+	return strings.Join(exprItems, " AND "), vals
 }
 
 // DuckDB spatial doesn't support SRID parameter in ST_GeomFromText
-const sqlFmtBBoxGeoFilter = ` ST_Intersects("%v", ST_GeomFromText('POLYGON((%v %v, %v %v, %v %v, %v %v, %v %v))')) `
+const sqlFmtBBoxPolygon = `ST_GeomFromText('POLYGON((%v %v, %v %v, %v %v, %v %v, %v %v))')`
+
+const sqlFmtBBoxGeoFilter = ` ST_Intersects("%v", %v) `
 
-func sqlBBoxFilter(geomCol string, bbox *Extent, bboxSRID int) string {
+// sqlBBoxFilter creates a filter on the bbox, transformed from the bbox SRID to the source SRID
+func sqlBBoxFilter(geomCol string, sourceSRID int, bbox *Extent, bboxSRID int) string {
 	if bbox == nil {
 		return ""
 	}
 	// For DuckDB, use ST_GeomFromText without SRID parameter
-	return fmt.Sprintf(sqlFmtBBoxGeoFilter, geomCol,
+	bboxExpr := fmt.Sprintf(sqlFmtBBoxPolygon,
 		bbox.Minx, bbox.Miny, bbox.Maxx, bbox.Miny, bbox.Maxx, bbox.Maxy, bbox.Minx, bbox.Maxy, bbox.Minx, bbox.Miny)
+	bboxExpr = transformToOutCrs(bboxExpr, bboxSRID, sourceSRID)
+	return fmt.Sprintf(sqlFmtBBoxGeoFilter, geomCol, bboxExpr)
 }
 
 const sqlFmtGeomCol = `ST_AsGeoJSON( %v %v ) AS _geojson`
@@ -198,12 +213,15 @@ func sqlGeomCol(geomCol string, sourceSRID int, param *QueryParam) string {
 	return sql
 }
 
+const sqlFmtTransform = `ST_Transform(%v, 'EPSG:%d', 'EPSG:%d', always_xy := true)`
+
+// transformToOutCrs transforms a geometry expression between SRIDs.
+// The expression is unchanged if either SRID is unknown
 func transformToOutCrs(geomExpr string, sourceSRID, outSRID int) string {
-	if sourceSRID == outSRID {
+	if sourceSRID == outSRID || sourceSRID <= 0 || outSRID <= 0 {
 		return geomExpr
 	}
-	// For DuckDB spatial, we'll return the original for now since transform support is limited
-	return geomExpr
+	return fmt.Sprintf(sqlFmtTransform, geomExpr, sourceSRID, outSRID)
 }
 
 func sqlPrecisionArg(precision int) string {
@@ -272,7 +290,7 @@ func sqlGeomFunction(fn *Function, args map[string]string, propCols []string, pa
 	sqlGeomCol := sqlGeomCol(fn.GeometryColumn, SRID_UNKNOWN, param)
 	sqlPropCols := sqlColList(propCols, fn.Types, true)
 	//-- SRS of function output is unknown, so have to assume 4326
-	bboxFilter := sqlBBoxFilter(fn.GeometryColumn, param.Bbox, param.BboxCrs)
+	bboxFilter := sqlBBoxFilter(fn.GeometryColumn, SRID_UNKNOWN, param.Bbox, param.BboxCrs)
 	cqlFilter := sqlCqlFilter(param.FilterSql)
 	sqlWhere := sqlWhere(bboxFilter, cqlFilter, "")
 	sqlOrderBy := sqlOrderBy(param.SortBy)
@@ -0,0 +1,123 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/archive"
+)
+
+// ExportTiles generates the tiles of a layer with the same logic as the tile endpoints,
+// and writes them to a tile archive. The archive metadata is taken from the TileJSON of the layer.
+// Archives only support the WebMercatorQuad tile matrix set.
+// Returns the number of (non-empty) tiles written.
+func (cat *CatalogDB) ExportTiles(ctx context.Context, opts GenerateOptions, w archive.Writer) (int, error) {
+	plan, err := cat.PlanTiles(opts)
+	if err != nil {
+		return 0, err
+	}
+	if plan.TileMatrixSet.ID != TileMatrixSetWebMercatorQuad {
+		return 0, fmt.Errorf("tile archives require the %s tile matrix set, layer %s uses %s",
+			TileMatrixSetWebMercatorQuad, opts.Layer, plan.TileMatrixSet.ID)
+	}
+	log.Infof("Exporting %d tiles of layer %s (zoom %d-%d)", plan.Total, opts.Layer, plan.MinZoom, plan.MaxZoom)
+
+	// the archive is written by a single goroutine
+	done, written := 0, 0
+	err = cat.GenerateTiles(ctx, plan, func(z, x, y int, tile []byte) error {
+		if len(tile) > 0 {
+			if err := w.WriteTile(z, x, y, tile); err != nil {
+				return fmt.Errorf("error writing tile %d/%d/%d: %w", z, x, y, err)
+			}
+			written++
+		}
+		done++
+		if done%1000 == 0 {
+			log.Infof("Exported %d/%d tiles (%.1f%%)", done, plan.Total, float64(done)/float64(plan.Total)*100.0)
+		}
+		return nil
+	})
+	if err != nil {
+		return written, err
+	}
+
+	tj := plan.tileJSON
+	meta := &archive.Metadata{
+		Name:        tj.Name,
+		Description: tj.Description,
+		Version:     tj.Version,
+		MinZoom:     plan.MinZoom,
+		MaxZoom:     plan.MaxZoom,
+	}
+	lonLatBounds := webMercatorToLonLatExtent(plan.Bounds)
+	meta.Bounds = []float64{lonLatBounds.Minx, lonLatBounds.Miny, lonLatBounds.Maxx, lonLatBounds.Maxy}
+	meta.Center = []float64{(lonLatBounds.Minx + lonLatBounds.Maxx) / 2, (lonLatBounds.Miny + lonLatBounds.Maxy) / 2, float64(plan.MinZoom)}
+	if meta.VectorLayers, err = json.Marshal(tj.VectorLayers); err != nil {
+		return written, err
+	}
+	if err := w.Close(meta); err != nil {
+		return written, fmt.Errorf("error writing archive: %w", err)
+	}
+
+	log.Infof("Exported %d tiles of layer %s (%d empty tiles omitted)", written, opts.Layer, done-written)
+	return written, nil
+}
+
+// LayersBounds returns the union of the bounds of layers (in Web Mercator),
+// or nil if they can't be computed.
+// The bounds of a layer are computed once, until its metadata is invalidated.
+func (cat *CatalogDB) LayersBounds(layerNames []string) *Extent {
+	var bounds *Extent
+	for _, name := range layerNames {
+		layerBounds := cat.layerBoundsByName(name)
+		if layerBounds == nil {
+			return nil
+		}
+		if bounds == nil {
+			b := *layerBounds
+			bounds = &b
+			continue
+		}
+		bounds.Minx = math.Min(bounds.Minx, layerBounds.Minx)
+		bounds.Miny = math.Min(bounds.Miny, layerBounds.Miny)
+		bounds.Maxx = math.Max(bounds.Maxx, layerBounds.Maxx)
+		bounds.Maxy = math.Max(bounds.Maxy, layerBounds.Maxy)
+	}
+	return bounds
+}
+
+// layerBoundsByName returns the bounds of a layer (in Web Mercator), or nil if they can't be computed
+func (cat *CatalogDB) layerBoundsByName(name string) *Extent {
+	cat.layerCacheMutex.RLock()
+	cached, ok := cat.layerBounds[name]
+	cat.layerCacheMutex.RUnlock()
+	if ok {
+		return cached
+	}
+
+	layer, err := cat.GetLayerByName(name)
+	if err != nil {
+		return nil
+	}
+	// the bounds of table layers are not part of the cached tile metadata
+	layerCopy := *layer
+	if layerCopy.Bounds == nil {
+		if err := cat.enrichLayerMetadata(&layerCopy); err != nil || layerCopy.Bounds == nil {
+			return nil
+		}
+	}
+
+	cat.layerCacheMutex.Lock()
+	defer cat.layerCacheMutex.Unlock()
+	// the layer metadata may have been invalidated meanwhile
+	if cat.layerMetadataCache[name] == layer {
+		if cat.layerBounds == nil {
+			cat.layerBounds = make(map[string]*Extent)
+		}
+		cat.layerBounds[name] = layerCopy.Bounds
+	}
+	return layerCopy.Bounds
+}
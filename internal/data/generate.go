@@ -0,0 +1,193 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GenerateOptions define a set of tiles generated in bulk
+// (written to a tile archive, or seeded into the tile cache)
+type GenerateOptions struct {
+	Layer   string            // Layer name, or composite layer specification (e.g. "roads,water")
+	MinZoom int               // Minimum zoom level (-1 = minimum zoom of the layers)
+	MaxZoom int               // Maximum zoom level (-1 = maximum zoom of the layers)
+	Bounds  *Extent           // Area in EPSG:4326 (nil = bounds of the layers)
+	Params  map[string]string // Values of the layer parameters
+	Workers int               // Number of tiles generated concurrently (0 = number of CPUs)
+}
+
+// TilePlan is the set of tiles resolved from GenerateOptions
+type TilePlan struct {
+	LayerNames    []string
+	TileMatrixSet *TileMatrixSet
+	MinZoom       int
+	MaxZoom       int
+	Bounds        *Extent // Area in the CRS of the tile matrix set
+	Total         int     // Number of tiles
+
+	opts     GenerateOptions
+	tileJSON *TileJSON
+}
+
+// generatedTile is a tile generated by a worker of GenerateTiles
+type generatedTile struct {
+	z, x, y int
+	data    []byte
+	err     error
+}
+
+// PlanTiles resolves the layers, zoom range and area of a bulk tile generation.
+// The tiles are generated in the tile matrix set of the layers.
+func (cat *CatalogDB) PlanTiles(opts GenerateOptions) (*TilePlan, error) {
+	layerNames := SplitLayerNames(opts.Layer)
+	if len(layerNames) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrLayerNotFound, opts.Layer)
+	}
+	tms, err := LayerTileMatrixSet(layerNames)
+	if err != nil {
+		return nil, err
+	}
+	tj, err := cat.GetTileJSON(opts.Layer, "")
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &TilePlan{
+		LayerNames:    layerNames,
+		TileMatrixSet: tms,
+		MinZoom:       opts.MinZoom,
+		MaxZoom:       opts.MaxZoom,
+		opts:          opts,
+		tileJSON:      tj,
+	}
+	if plan.MinZoom < 0 {
+		plan.MinZoom = tj.MinZoom
+	}
+	if plan.MaxZoom < 0 {
+		plan.MaxZoom = tj.MaxZoom
+	}
+	if plan.MinZoom > plan.MaxZoom {
+		return nil, fmt.Errorf("invalid zoom range: %d-%d", plan.MinZoom, plan.MaxZoom)
+	}
+	if tms.TileMatrix(plan.MaxZoom) == nil {
+		return nil, fmt.Errorf("zoom level %d is not part of tile matrix set %s", plan.MaxZoom, tms.ID)
+	}
+
+	// area in longitude/latitude, projected to the tile matrix set
+	lonLatBounds := opts.Bounds
+	if lonLatBounds == nil {
+		if bounds := cat.LayersBounds(layerNames); bounds != nil {
+			lonLatBounds = webMercatorToLonLatExtent(bounds)
+		}
+	}
+	if lonLatBounds != nil {
+		if plan.Bounds, err = cat.tileMatrixSetBounds(tms, lonLatBounds); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Warnf("Bounds of layer %s are unknown, using the whole tile matrix set", opts.Layer)
+		bbox := tms.BoundingBox
+		plan.Bounds = &Extent{Minx: bbox.LowerLeft[0], Miny: bbox.LowerLeft[1], Maxx: bbox.UpperRight[0], Maxy: bbox.UpperRight[1]}
+	}
+
+	for z := plan.MinZoom; z <= plan.MaxZoom; z++ {
+		limits := tms.TileLimits(z, plan.Bounds)
+		plan.Total += (limits.MaxTileCol - limits.MinTileCol + 1) * (limits.MaxTileRow - limits.MinTileRow + 1)
+	}
+	return plan, nil
+}
+
+// tileMatrixSetBounds projects longitude/latitude bounds to the CRS of a tile matrix set
+func (cat *CatalogDB) tileMatrixSetBounds(tms *TileMatrixSet, bounds *Extent) (*Extent, error) {
+	switch tms.Srid {
+	case SRID_3857:
+		return lonLatToWebMercatorExtent(bounds), nil
+	case SRID_4326:
+		b := *bounds
+		return &b, nil
+	}
+	envelope := transformToOutCrs("ST_MakeEnvelope(?, ?, ?, ?)", SRID_4326, tms.Srid)
+	query := fmt.Sprintf("SELECT ST_XMin(g), ST_YMin(g), ST_XMax(g), ST_YMax(g) FROM (SELECT %s AS g)", envelope)
+	var projected Extent
+	err := cat.dbconn.QueryRow(query, bounds.Minx, bounds.Miny, bounds.Maxx, bounds.Maxy).
+		Scan(&projected.Minx, &projected.Miny, &projected.Maxx, &projected.Maxy)
+	if err != nil {
+		return nil, fmt.Errorf("error projecting bounds to tile matrix set %s: %w", tms.ID, err)
+	}
+	return &projected, nil
+}
+
+// GenerateTiles generates the tiles of a plan with the same logic as the tile endpoints.
+// Tiles are generated concurrently, handle is called for each tile (including empty tiles)
+// from a single goroutine. Generation stops at the first error, or when ctx is cancelled.
+func (cat *CatalogDB) GenerateTiles(ctx context.Context, plan *TilePlan, handle func(z, x, y int, tile []byte) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tms := plan.TileMatrixSet
+
+	// enumerate the tiles of the pyramid
+	coords := make(chan generatedTile)
+	go func() {
+		defer close(coords)
+		for z := plan.MinZoom; z <= plan.MaxZoom; z++ {
+			limits := tms.TileLimits(z, plan.Bounds)
+			for x := limits.MinTileCol; x <= limits.MaxTileCol; x++ {
+				for y := limits.MinTileRow; y <= limits.MaxTileRow; y++ {
+					select {
+					case coords <- generatedTile{z: z, x: x, y: y}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	// generate the tiles concurrently
+	workers := plan.opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	results := make(chan generatedTile)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tile := range coords {
+				if len(plan.LayerNames) == 1 {
+					tile.data, tile.err = cat.GenerateTile(ctx, plan.LayerNames[0], tms, tile.z, tile.x, tile.y, plan.opts.Params)
+				} else {
+					tile.data, tile.err = cat.GenerateCompositeTile(ctx, plan.LayerNames, tms, tile.z, tile.x, tile.y, plan.opts.Params)
+				}
+				select {
+				case results <- tile:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for tile := range results {
+		if ctx.Err() != nil {
+			break
+		}
+		if tile.err != nil {
+			return fmt.Errorf("error generating tile %d/%d/%d: %w", tile.z, tile.x, tile.y, tile.err)
+		}
+		if err := handle(tile.z, tile.x, tile.y, tile.data); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
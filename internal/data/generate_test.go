@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/tobilg/duckdb-tileserver/internal/archive"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+)
+
+func TestGenerateTiles(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+
+	conf.Configuration.Layers = []conf.Layer{{Name: "basemap", Archive: writeTestArchive(t)}}
+	cat := &CatalogDB{
+		layerMetadataCache: make(map[string]*Layer),
+		archives:           make(map[string]archive.Reader),
+	}
+	defer cat.closeArchives()
+
+	// zoom range and bounds of the layer
+	plan, err := cat.PlanTiles(GenerateOptions{Layer: "basemap", MinZoom: -1, MaxZoom: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, TileMatrixSetWebMercatorQuad, plan.TileMatrixSet.ID, "Tile matrix set")
+	testEquals(t, 2, plan.MinZoom, "MinZoom")
+	testEquals(t, 4, plan.MaxZoom, "MaxZoom")
+
+	tiles := make(map[string]int)
+	err = cat.GenerateTiles(context.Background(), plan, func(z, x, y int, tile []byte) error {
+		tiles[fmt.Sprintf("%d/%d/%d", z, x, y)] = len(tile)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, plan.Total, len(tiles), "Generated tiles")
+	testEquals(t, 4, tiles["3/4/3"], "Tile size")
+
+	// explicit area covering a single tile
+	plan, err = cat.PlanTiles(GenerateOptions{
+		Layer:   "basemap",
+		MinZoom: 3,
+		MaxZoom: 3,
+		Bounds:  &Extent{Minx: 1, Miny: 5, Maxx: 2, Maxy: 6},
+		Workers: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, 1, plan.Total, "Tiles in bounds")
+
+	_, err = cat.PlanTiles(GenerateOptions{Layer: "basemap", MinZoom: 5, MaxZoom: 3})
+	if err == nil {
+		t.Error("Expected error for invalid zoom range")
+	}
+
+	// cancellation stops the generation
+	ctx, cancel := context.WithCancel(context.Background())
+	plan, _ = cat.PlanTiles(GenerateOptions{Layer: "basemap", MinZoom: -1, MaxZoom: -1})
+	count := 0
+	err = cat.GenerateTiles(ctx, plan, func(z, x, y int, tile []byte) error {
+		count++
+		cancel()
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected cancellation, got %v", err)
+	}
+	testEquals(t, 1, count, "Tiles handled after cancellation")
+}
+
+func TestExportArchiveLayer(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+
+	conf.Configuration.Layers = []conf.Layer{{Name: "basemap", Archive: writeTestArchive(t)}}
+	cat := &CatalogDB{
+		layerMetadataCache: make(map[string]*Layer),
+		archives:           make(map[string]archive.Reader),
+	}
+	defer cat.closeArchives()
+
+	path := filepath.Join(t.TempDir(), "export.pmtiles")
+	w, err := archive.NewPMTilesWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := cat.ExportTiles(context.Background(), GenerateOptions{Layer: "basemap", MinZoom: 3, MaxZoom: 3}, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, 1, written, "Written tiles")
+
+	reader, err := archive.OpenPMTiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	tile, err := reader.Tile(context.Background(), 3, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, "tile", string(tile), "Exported tile")
+	testEquals(t, 3, reader.Metadata().MinZoom, "Exported MinZoom")
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/archive"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+)
+
+// newArchiveLayer creates a layer serving the pre-built tiles of an MBTiles or PMTiles archive.
+// The layer metadata is taken from the archive metadata.
+func (cat *CatalogDB) newArchiveLayer(layerConf *conf.Layer) (*Layer, error) {
+	reader, err := cat.openArchive(layerConf.Archive)
+	if err != nil {
+		return nil, err
+	}
+	meta := reader.Metadata()
+	layer := &Layer{
+		Name:        layerConf.Name,
+		Description: meta.Description,
+		Srid:        SRID_3857,
+		SourceSrid:  SRID_3857,
+		archive:     reader,
+	}
+	// bounds are given in Web Mercator by the API
+	if len(meta.Bounds) == 4 {
+		layer.Bounds = lonLatToWebMercatorExtent(&Extent{
+			Minx: meta.Bounds[0], Miny: meta.Bounds[1], Maxx: meta.Bounds[2], Maxy: meta.Bounds[3],
+		})
+	}
+	return layer, nil
+}
+
+// openArchive returns the reader of a tile archive, opening the archive on first use
+func (cat *CatalogDB) openArchive(path string) (archive.Reader, error) {
+	cat.archiveMutex.Lock()
+	defer cat.archiveMutex.Unlock()
+
+	if reader, ok := cat.archives[path]; ok {
+		return reader, nil
+	}
+	reader, err := archive.Open(context.Background(), cat.dbconn, path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening archive %s: %w", path, err)
+	}
+	cat.archives[path] = reader
+	log.Infof("Opened tile archive %s", path)
+	return reader, nil
+}
+
+// closeArchive closes the archive at path, which is opened again on next use.
+// Tile requests may still be reading the archive, so it is closed after the request timeout.
+func (cat *CatalogDB) closeArchive(path string) {
+	cat.archiveMutex.Lock()
+	defer cat.archiveMutex.Unlock()
+
+	reader, ok := cat.archives[path]
+	if !ok {
+		return
+	}
+	delete(cat.archives, path)
+	timeout := time.Duration(conf.Configuration.Server.WriteTimeoutSec+1) * time.Second
+	time.AfterFunc(timeout, func() {
+		if err := reader.Close(); err != nil {
+			log.Warnf("Error closing archive %s: %v", path, err)
+		}
+	})
+}
+
+// closeArchives closes the tile archives of the archive layers
+func (cat *CatalogDB) closeArchives() {
+	cat.archiveMutex.Lock()
+	defer cat.archiveMutex.Unlock()
+
+	for path, reader := range cat.archives {
+		if err := reader.Close(); err != nil {
+			log.Warnf("Error closing archive %s: %v", path, err)
+		}
+	}
+	cat.archives = make(map[string]archive.Reader)
+}
+
+// ServesTileMatrixSet tests whether the tiles of a layer can be served in a tile matrix set.
+// Archive layers only contain tiles of the WebMercatorQuad tile matrix set,
+// the other layers are generated in any tile matrix set.
+func (layer *Layer) ServesTileMatrixSet(tms *TileMatrixSet) bool {
+	return layer.archive == nil || tms.ID == TileMatrixSetWebMercatorQuad
+}
+
+// archiveTile returns a tile of an archive layer, compressed with gzip if gzipped is set.
+// Archives are tiled in Web Mercator, so the tiles only exist in the WebMercatorQuad tile matrix set.
+func (layer *Layer) archiveTile(ctx context.Context, tms *TileMatrixSet, z, x, y int, gzipped bool) ([]byte, error) {
+	if !layer.ServesTileMatrixSet(tms) {
+		return nil, fmt.Errorf("%w: archive layer %s only supports the %s tile matrix set",
+			ErrTileMatrixSetMismatch, layer.Name, TileMatrixSetWebMercatorQuad)
+	}
+	if !layer.hasZoom(z) {
+		return []byte{}, nil
+	}
+	var tile []byte
+	var err error
+	if gzipped {
+		tile, err = layer.archive.GzipTile(ctx, z, x, y)
+	} else {
+		tile, err = layer.archive.Tile(ctx, z, x, y)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading tile from archive: %w", err)
+	}
+	if tile == nil {
+		return []byte{}, nil
+	}
+	return tile, nil
+}
+
+// archiveVectorLayers returns the vector layers contained in the tiles of an archive layer,
+// as described by the archive metadata
+func (layer *Layer) archiveVectorLayers() []VectorLayer {
+	meta := layer.archive.Metadata()
+	var vectorLayers []VectorLayer
+	if len(meta.VectorLayers) > 0 {
+		if err := json.Unmarshal(meta.VectorLayers, &vectorLayers); err != nil {
+			log.Warnf("Layer %s: invalid vector_layers metadata: %v", layer.Name, err)
+			vectorLayers = nil
+		}
+	}
+	if len(vectorLayers) == 0 {
+		// the archive doesn't describe its contents
+		vectorLayers = []VectorLayer{{ID: layer.Name, Description: layer.Description}}
+	}
+	return vectorLayers
+}
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/tobilg/duckdb-tileserver/internal/archive"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+)
+
+func TestArchiveLayer(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+
+	path := writeTestArchive(t)
+
+	conf.Configuration.Layers = []conf.Layer{{Name: "basemap", Archive: path, MaxZoom: 5}}
+	cat := &CatalogDB{
+		layerMetadataCache: make(map[string]*Layer),
+		archives:           make(map[string]archive.Reader),
+	}
+	defer cat.closeArchives()
+
+	layer, err := cat.GetLayerByName("basemap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, "Base map", layer.Description, "Description")
+	testEquals(t, 2, layer.MinZoom, "MinZoom from archive")
+	testEquals(t, 5, layer.MaxZoom, "Configured MaxZoom")
+	testEquals(t, 0.0, layer.Bounds.Minx, "Bounds minx")
+	testEquals(t, 1113194.91, math.Round(layer.Bounds.Maxx*100)/100, "Bounds maxx")
+
+	webMercator := TileMatrixSetByID(TileMatrixSetWebMercatorQuad)
+	tile, err := cat.GenerateTile(context.Background(), "basemap", webMercator, 3, 4, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, "tile", string(tile), "Archive tile")
+
+	// tiles are read compressed from the archive
+	gzipTile, err := cat.GenerateGzipTile(context.Background(), []string{"basemap"}, webMercator, 3, 4, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, true, IsGzipTile(gzipTile), "Compressed archive tile")
+	tile, err = GunzipTile(gzipTile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, "tile", string(tile), "Decompressed archive tile")
+
+	tile, err = cat.GenerateTile(context.Background(), "basemap", webMercator, 3, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, 0, len(tile), "Missing tile")
+
+	_, err = cat.GenerateTile(context.Background(), "basemap", TileMatrixSetByID(TileMatrixSetWorldCRS84Quad), 1, 0, 0, nil)
+	if !errors.Is(err, ErrTileMatrixSetMismatch) {
+		t.Errorf("Expected tile matrix set mismatch, got %v", err)
+	}
+	testEquals(t, true, layer.ServesTileMatrixSet(webMercator), "Serves WebMercatorQuad")
+	testEquals(t, false, layer.ServesTileMatrixSet(TileMatrixSetByID(TileMatrixSetWorldCRS84Quad)), "Serves WorldCRS84Quad")
+
+	// the bounds are kept until the layer metadata is invalidated
+	bounds := cat.LayersBounds([]string{"basemap"})
+	testEquals(t, *layer.Bounds, *bounds, "Layers bounds")
+	testEquals(t, layer.Bounds, cat.layerBounds["basemap"], "Cached layer bounds")
+	cat.InvalidateLayerMetadataCache("basemap")
+	testEquals(t, 0, len(cat.layerBounds), "Invalidated layer bounds")
+
+	tj, err := cat.GetTileJSON("basemap", "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundBounds := func(values []float64) []float64 {
+		rounded := make([]float64, len(values))
+		for i, v := range values {
+			rounded[i] = math.Round(v*1e6) / 1e6
+		}
+		return rounded
+	}
+	testEquals(t, []float64{0, 0, 10, 10}, roundBounds(tj.Bounds), "TileJSON bounds in longitude/latitude")
+	testEquals(t, []float64{5, 5, 10}, roundBounds(tj.Center), "TileJSON center")
+	testEquals(t, 2, len(tj.VectorLayers), "Vector layers")
+	testEquals(t, "roads", tj.VectorLayers[1].ID, "Vector layer id")
+	testEquals(t, map[string]string{"class": "String"}, tj.VectorLayers[1].Fields, "Vector layer fields")
+}
+
+// writeTestArchive writes a PMTiles archive with a single tile (3/4/3),
+// and returns its path
+func writeTestArchive(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "basemap.pmtiles")
+	pw, err := archive.NewPMTilesWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.WriteTile(3, 4, 3, []byte("tile")); err != nil {
+		t.Fatal(err)
+	}
+	err = pw.Close(&archive.Metadata{
+		Name:         "basemap",
+		Description:  "Base map",
+		MinZoom:      2,
+		MaxZoom:      6,
+		Bounds:       []float64{0, 0, 10, 10},
+		VectorLayers: json.RawMessage(`[{"id":"water"},{"id":"roads","fields":{"class":"String"}}]`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
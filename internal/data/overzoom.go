@@ -0,0 +1,150 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+	"github.com/tobilg/duckdb-tileserver/internal/mvt"
+)
+
+// parentTileCacheSize is the number of parent tiles kept for overzooming
+const parentTileCacheSize = 256
+
+// overzoomTile returns a tile of a layer above its data max zoom,
+// cut from the parent tile at the data max zoom and rescaled.
+// Geometries are clipped to the tile extended by the buffer of the layer.
+// ok is false if the tile matrix set doesn't split each tile into four tiles at the next zoom level.
+func (cat *CatalogDB) overzoomTile(ctx context.Context, layer *Layer, tms *TileMatrixSet, z, x, y int, params map[string]string) (tile []byte, ok bool, err error) {
+	parentZ := layer.DataMaxZoom
+	dz := z - parentZ
+	parentTm, tm := tms.TileMatrix(parentZ), tms.TileMatrix(z)
+	if parentTm == nil || tm == nil || tm.MatrixWidth != parentTm.MatrixWidth<<dz || tm.MatrixHeight != parentTm.MatrixHeight<<dz {
+		return nil, false, nil
+	}
+	parentX, parentY := x>>dz, y>>dz
+
+	key := parentTileKey(layer, tms, parentZ, parentX, parentY, params)
+	parent, err := cat.parentTiles.get(ctx, key, func(ctx context.Context) ([]byte, error) {
+		return cat.GenerateTile(ctx, layer.Name, tms, parentZ, parentX, parentY, params)
+	})
+	if err != nil {
+		return nil, true, err
+	}
+	if len(parent) == 0 {
+		return []byte{}, true, nil
+	}
+
+	log.Debugf("Overzooming tile for layer=%s z=%d x=%d y=%d from parent tile %d/%d/%d", layer.Name, z, x, y, parentZ, parentX, parentY)
+	tile, err = mvt.Overzoom(parent, dz, x-parentX<<dz, y-parentY<<dz, layer.Buffer)
+	if err != nil {
+		return nil, true, fmt.Errorf("error overzooming tile: %w", err)
+	}
+	if tile == nil {
+		return []byte{}, true, nil
+	}
+	return tile, true, nil
+}
+
+// parentTileKey returns the key of a parent tile ("layer:tms:z:x:y?params")
+func parentTileKey(layer *Layer, tms *TileMatrixSet, z, x, y int, params map[string]string) string {
+	key := fmt.Sprintf("%s:%s:%d:%d:%d", layer.Name, tms.ID, z, x, y)
+	values := url.Values{}
+	for _, param := range layer.Parameters {
+		if value, ok := params[param.Name]; ok {
+			values.Set(param.Name, value)
+		}
+	}
+	if len(values) > 0 {
+		key += "?" + values.Encode()
+	}
+	return key
+}
+
+// ClearParentTiles discards the parent tiles kept for overzooming the tiles of a layer
+// (of all layers if layerName is empty)
+func (cat *CatalogDB) ClearParentTiles(layerName string) {
+	cat.parentTiles.clear(layerName)
+}
+
+// parentTileCache keeps the most recently used parent tiles of overzoomed tiles,
+// since each parent tile is cut into many tiles.
+// Concurrent requests for the same parent tile share a single generation.
+type parentTileCache struct {
+	mutex   sync.Mutex
+	tiles   *lru.Cache[string, []byte]
+	flights map[string]*parentTileFlight
+}
+
+// parentTileFlight is a parent tile generation in progress
+type parentTileFlight struct {
+	done chan struct{}
+	tile []byte
+	err  error
+}
+
+// get returns the parent tile with the given key, generating it if needed.
+// The generation is not cancelled with ctx, since other requests may wait for the tile.
+func (ptc *parentTileCache) get(ctx context.Context, key string, generate func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	ptc.mutex.Lock()
+	if ptc.tiles == nil {
+		ptc.tiles, _ = lru.New[string, []byte](parentTileCacheSize)
+		ptc.flights = make(map[string]*parentTileFlight)
+	}
+	if tile, ok := ptc.tiles.Get(key); ok {
+		ptc.mutex.Unlock()
+		return tile, nil
+	}
+	flight, inProgress := ptc.flights[key]
+	if !inProgress {
+		flight = &parentTileFlight{done: make(chan struct{})}
+		ptc.flights[key] = flight
+	}
+	ptc.mutex.Unlock()
+
+	if !inProgress {
+		// the generation is bounded by the write timeout of the server (if set)
+		genCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		if timeout := conf.Configuration.Server.WriteTimeoutSec; timeout > 0 {
+			genCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), time.Duration(timeout)*time.Second)
+		}
+		flight.tile, flight.err = generate(genCtx)
+		cancel()
+
+		ptc.mutex.Lock()
+		if flight.err == nil {
+			ptc.tiles.Add(key, flight.tile)
+		}
+		delete(ptc.flights, key)
+		ptc.mutex.Unlock()
+		close(flight.done)
+	}
+
+	select {
+	case <-flight.done:
+		return flight.tile, flight.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// clear removes the parent tiles of a layer (all tiles if layerName is empty)
+func (ptc *parentTileCache) clear(layerName string) {
+	ptc.mutex.Lock()
+	defer ptc.mutex.Unlock()
+
+	if ptc.tiles == nil {
+		return
+	}
+	for _, key := range ptc.tiles.Keys() {
+		if layerName == "" || strings.HasPrefix(key, layerName+":") {
+			ptc.tiles.Remove(key)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+)
+
+func TestParentTileCache(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+	conf.Configuration.Server.WriteTimeoutSec = 0
+
+	var ptc parentTileCache
+	var generated atomic.Int32
+	release := make(chan struct{})
+	generate := func(ctx context.Context) ([]byte, error) {
+		generated.Add(1)
+		<-release
+		// without a configured write timeout the generation has no deadline
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return []byte("parent"), nil
+	}
+
+	// concurrent requests for the same parent tile share a single generation
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tile, err := ptc.get(context.Background(), "roads:WebMercatorQuad:14:1:2", generate)
+			if err != nil || string(tile) != "parent" {
+				t.Errorf("Unexpected parent tile %q (%v)", tile, err)
+			}
+		}()
+	}
+	for {
+		ptc.mutex.Lock()
+		started := len(ptc.flights) > 0
+		ptc.mutex.Unlock()
+		if started {
+			break
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	ptc.get(context.Background(), "roads:WebMercatorQuad:14:1:2", generate)
+	ptc.get(context.Background(), "roadside:WebMercatorQuad:14:1:2", generate)
+	testEquals(t, int32(2), generated.Load(), "Generations")
+
+	// clearing a layer keeps the tiles of other layers
+	ptc.clear("roads")
+	testEquals(t, []string{"roadside:WebMercatorQuad:14:1:2"}, ptc.tiles.Keys(), "Remaining parent tiles")
+	ptc.clear("")
+	testEquals(t, 0, ptc.tiles.Len(), "Parent tiles after clearing all")
+}
+
+func TestParentTileKey(t *testing.T) {
+	layer := &Layer{Name: "roads", Parameters: []LayerParameter{{Name: "class"}}}
+	tms := TileMatrixSetByID(TileMatrixSetWebMercatorQuad)
+	testEquals(t, "roads:WebMercatorQuad:14:1:2", parentTileKey(layer, tms, 14, 1, 2, nil), "Key without parameters")
+	testEquals(t, "roads:WebMercatorQuad:14:1:2?class=primary",
+		parentTileKey(layer, tms, 14, 1, 2, map[string]string{"class": "primary", "other": "x"}), "Key with parameters")
+}
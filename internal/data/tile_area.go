@@ -0,0 +1,302 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// point is a position in longitude/latitude
+type point [2]float64
+
+// TileArea selects the tiles intersecting an area (a GeoJSON geometry or a bbox in EPSG:4326)
+// in a zoom range. It is used to invalidate the cached tiles of a changed area.
+type TileArea struct {
+	MinZoom int     // Minimum zoom level
+	MaxZoom int     // Maximum zoom level (-1 = no maximum)
+	Buffer  float64 // Buffer around the tiles as a fraction of the tile size (features in the buffer are part of a tile)
+
+	bounds   Extent      // Envelope of the area in longitude/latitude
+	points   []point     // Point geometries
+	lines    [][]point   // Line geometries
+	polygons [][][]point // Polygon geometries (rings)
+
+	// Envelope of the area projected to the tile matrix sets
+	// which are neither in Web Mercator nor in longitude/latitude
+	projected map[string]*Extent
+}
+
+// geoJSONGeometry is a GeoJSON geometry object
+type geoJSONGeometry struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates"`
+	Geometries  []geoJSONGeometry `json:"geometries"`
+}
+
+// NewTileArea creates the tile area of a GeoJSON geometry, or of a bbox if geometry is empty
+func NewTileArea(geometry json.RawMessage, bbox *Extent, minZoom, maxZoom int) (*TileArea, error) {
+	if minZoom < 0 || (maxZoom >= 0 && minZoom > maxZoom) {
+		return nil, fmt.Errorf("invalid zoom range: %d-%d", minZoom, maxZoom)
+	}
+	area := &TileArea{
+		MinZoom:   minZoom,
+		MaxZoom:   maxZoom,
+		projected: make(map[string]*Extent),
+	}
+	switch {
+	case len(geometry) > 0:
+		var geom geoJSONGeometry
+		if err := json.Unmarshal(geometry, &geom); err != nil {
+			return nil, fmt.Errorf("invalid GeoJSON geometry: %w", err)
+		}
+		if err := area.addGeometry(&geom); err != nil {
+			return nil, err
+		}
+	case bbox != nil:
+		if bbox.Minx > bbox.Maxx || bbox.Miny > bbox.Maxy {
+			return nil, fmt.Errorf("invalid bbox: minimum greater than maximum")
+		}
+		area.polygons = append(area.polygons, [][]point{{
+			{bbox.Minx, bbox.Miny}, {bbox.Maxx, bbox.Miny}, {bbox.Maxx, bbox.Maxy}, {bbox.Minx, bbox.Maxy}, {bbox.Minx, bbox.Miny},
+		}})
+	default:
+		return nil, fmt.Errorf("a geometry or a bbox is required")
+	}
+	area.computeBounds()
+	if area.bounds.Minx > area.bounds.Maxx {
+		return nil, fmt.Errorf("empty geometry")
+	}
+	return area, nil
+}
+
+// ProjectTileArea projects the envelope of an area to the tile matrix sets
+// which are neither in Web Mercator nor in longitude/latitude.
+// Without projection, all tiles of these tile matrix sets intersect the area.
+func (cat *CatalogDB) ProjectTileArea(area *TileArea) error {
+	for _, tms := range TileMatrixSets() {
+		if tms.Srid == SRID_3857 || tms.Srid == SRID_4326 {
+			continue
+		}
+		projected, err := cat.tileMatrixSetBounds(tms, &area.bounds)
+		if err != nil {
+			return err
+		}
+		area.projected[tms.ID] = projected
+	}
+	return nil
+}
+
+// TileAreaBuffer returns the largest tile buffer of the (possibly composite) layer,
+// as a fraction of the tile size. Unknown layers have the default buffer.
+func (cat *CatalogDB) TileAreaBuffer(layer string) float64 {
+	buffer := float64(DefaultTileBuffer) / float64(DefaultTileExtent)
+	for i, name := range SplitLayerNames(layer) {
+		lyr, err := cat.GetLayerByName(name)
+		if err != nil {
+			continue
+		}
+		if i == 0 || lyr.bufferRatio() > buffer {
+			buffer = lyr.bufferRatio()
+		}
+	}
+	return buffer
+}
+
+// addGeometry adds the parts of a GeoJSON geometry to the area
+func (area *TileArea) addGeometry(geom *geoJSONGeometry) error {
+	var err error
+	switch geom.Type {
+	case "Point":
+		var p point
+		if err = json.Unmarshal(geom.Coordinates, &p); err == nil {
+			area.points = append(area.points, p)
+		}
+	case "MultiPoint":
+		var points []point
+		if err = json.Unmarshal(geom.Coordinates, &points); err == nil {
+			area.points = append(area.points, points...)
+		}
+	case "LineString":
+		var line []point
+		if err = json.Unmarshal(geom.Coordinates, &line); err == nil {
+			area.lines = append(area.lines, line)
+		}
+	case "MultiLineString":
+		var lines [][]point
+		if err = json.Unmarshal(geom.Coordinates, &lines); err == nil {
+			area.lines = append(area.lines, lines...)
+		}
+	case "Polygon":
+		var polygon [][]point
+		if err = json.Unmarshal(geom.Coordinates, &polygon); err == nil {
+			area.polygons = append(area.polygons, polygon)
+		}
+	case "MultiPolygon":
+		var polygons [][][]point
+		if err = json.Unmarshal(geom.Coordinates, &polygons); err == nil {
+			area.polygons = append(area.polygons, polygons...)
+		}
+	case "GeometryCollection":
+		for i := range geom.Geometries {
+			if err := area.addGeometry(&geom.Geometries[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported geometry type: %q", geom.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s coordinates: %w", geom.Type, err)
+	}
+	return nil
+}
+
+// computeBounds computes the envelope of the area
+func (area *TileArea) computeBounds() {
+	area.bounds = Extent{Minx: math.Inf(1), Miny: math.Inf(1), Maxx: math.Inf(-1), Maxy: math.Inf(-1)}
+	add := func(p point) {
+		area.bounds.Minx = math.Min(area.bounds.Minx, p[0])
+		area.bounds.Miny = math.Min(area.bounds.Miny, p[1])
+		area.bounds.Maxx = math.Max(area.bounds.Maxx, p[0])
+		area.bounds.Maxy = math.Max(area.bounds.Maxy, p[1])
+	}
+	for _, p := range area.points {
+		add(p)
+	}
+	for _, line := range area.lines {
+		for _, p := range line {
+			add(p)
+		}
+	}
+	for _, polygon := range area.polygons {
+		for _, ring := range polygon {
+			for _, p := range ring {
+				add(p)
+			}
+		}
+	}
+}
+
+// Intersects tests whether a tile of a tile matrix set intersects the area
+func (area *TileArea) Intersects(tms *TileMatrixSet, z, x, y int) bool {
+	if z < area.MinZoom || (area.MaxZoom >= 0 && z > area.MaxZoom) {
+		return false
+	}
+	tileBounds := tms.TileBounds(z, x, y)
+	if tileBounds == nil {
+		return false
+	}
+	// features in the buffer of the tile are part of the tile
+	if area.Buffer > 0 {
+		margin := area.Buffer * (tileBounds.Maxx - tileBounds.Minx)
+		tileBounds = &Extent{
+			Minx: tileBounds.Minx - margin,
+			Miny: tileBounds.Miny - margin,
+			Maxx: tileBounds.Maxx + margin,
+			Maxy: tileBounds.Maxy + margin,
+		}
+	}
+
+	var rect *Extent
+	switch tms.Srid {
+	case SRID_3857:
+		rect = webMercatorToLonLatExtent(tileBounds)
+	case SRID_4326:
+		rect = tileBounds
+	default:
+		// only the envelope of the area is known in other CRSs
+		projected, ok := area.projected[tms.ID]
+		return !ok || extentsIntersect(projected, tileBounds)
+	}
+
+	if !extentsIntersect(&area.bounds, rect) {
+		return false
+	}
+	for _, p := range area.points {
+		if pointInExtent(p, rect) {
+			return true
+		}
+	}
+	for _, line := range area.lines {
+		if lineIntersectsExtent(line, rect) {
+			return true
+		}
+	}
+	for _, polygon := range area.polygons {
+		for _, ring := range polygon {
+			if lineIntersectsExtent(ring, rect) {
+				return true
+			}
+		}
+		// the tile can be inside the polygon
+		center := point{(rect.Minx + rect.Maxx) / 2, (rect.Miny + rect.Maxy) / 2}
+		if pointInPolygon(center, polygon) {
+			return true
+		}
+	}
+	return false
+}
+
+// extentsIntersect tests whether two extents intersect
+func extentsIntersect(a, b *Extent) bool {
+	return a.Minx <= b.Maxx && a.Maxx >= b.Minx && a.Miny <= b.Maxy && a.Maxy >= b.Miny
+}
+
+// pointInExtent tests whether a point is inside an extent (including its boundary)
+func pointInExtent(p point, rect *Extent) bool {
+	return p[0] >= rect.Minx && p[0] <= rect.Maxx && p[1] >= rect.Miny && p[1] <= rect.Maxy
+}
+
+// lineIntersectsExtent tests whether a line intersects an extent
+func lineIntersectsExtent(line []point, rect *Extent) bool {
+	if len(line) == 1 {
+		return pointInExtent(line[0], rect)
+	}
+	for i := 1; i < len(line); i++ {
+		if segmentIntersectsExtent(line[i-1], line[i], rect) {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentIntersectsExtent tests whether a segment intersects an extent
+// (Liang-Barsky clipping)
+func segmentIntersectsExtent(a, b point, rect *Extent) bool {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t0, t1 := 0.0, 1.0
+	clip := func(p, q float64) bool {
+		if p == 0 {
+			return q >= 0
+		}
+		t := q / p
+		if p < 0 {
+			if t > t1 {
+				return false
+			}
+			t0 = math.Max(t0, t)
+		} else {
+			if t < t0 {
+				return false
+			}
+			t1 = math.Min(t1, t)
+		}
+		return true
+	}
+	return clip(-dx, a[0]-rect.Minx) && clip(dx, rect.Maxx-a[0]) &&
+		clip(-dy, a[1]-rect.Miny) && clip(dy, rect.Maxy-a[1])
+}
+
+// pointInPolygon tests whether a point is inside a polygon (even-odd rule, holes excluded)
+func pointInPolygon(p point, polygon [][]point) bool {
+	inside := false
+	for _, ring := range polygon {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a[1] > p[1]) != (b[1] > p[1]) && p[0] < (b[0]-a[0])*(p[1]-a[1])/(b[1]-a[1])+a[0] {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
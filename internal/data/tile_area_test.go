@@ -0,0 +1,91 @@
+package data
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTileArea(t *testing.T) {
+	webMercator := TileMatrixSetByID(TileMatrixSetWebMercatorQuad)
+	crs84 := TileMatrixSetByID(TileMatrixSetWorldCRS84Quad)
+
+	// Zurich is in tile 10/536/358 (WebMercatorQuad) and 10/1072/242 (WorldCRS84Quad)
+	bbox, err := NewTileArea(nil, &Extent{Minx: 8.5, Miny: 47.3, Maxx: 8.6, Maxy: 47.4}, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, true, bbox.Intersects(webMercator, 10, 536, 358), "Tile in bbox")
+	testEquals(t, true, bbox.Intersects(webMercator, 0, 0, 0), "World tile")
+	testEquals(t, false, bbox.Intersects(webMercator, 10, 537, 358), "Neighbour tile")
+	testEquals(t, false, bbox.Intersects(webMercator, 10, 0, 0), "Distant tile")
+	testEquals(t, true, bbox.Intersects(crs84, 10, 1072, 242), "WorldCRS84Quad tile")
+	testEquals(t, false, bbox.Intersects(crs84, 10, 536, 358), "WorldCRS84Quad distant tile")
+
+	// east of the prime meridian, the bbox is in the buffer of the tile 2/1/1 west of it
+	buffered, err := NewTileArea(nil, &Extent{Minx: 0.5, Miny: 10, Maxx: 1, Maxy: 11}, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, false, buffered.Intersects(webMercator, 2, 1, 1), "Tile without buffer")
+	buffered.Buffer = float64(DefaultTileBuffer) / float64(DefaultTileExtent)
+	testEquals(t, true, buffered.Intersects(webMercator, 2, 1, 1), "Tile with bbox in buffer")
+	testEquals(t, false, buffered.Intersects(webMercator, 2, 0, 1), "Tile beyond buffer")
+
+	zoomed, err := NewTileArea(nil, &Extent{Minx: 8.5, Miny: 47.3, Maxx: 8.6, Maxy: 47.4}, 12, 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, false, zoomed.Intersects(webMercator, 10, 536, 358), "Below zoom range")
+	testEquals(t, true, zoomed.Intersects(webMercator, 14, 8581, 5737), "In zoom range")
+	testEquals(t, false, zoomed.Intersects(webMercator, 15, 17162, 11474), "Above zoom range")
+
+	tests := []struct {
+		name     string
+		geometry string
+		x        int
+		expected bool
+	}{
+		{"Point", `{"type": "Point", "coordinates": [8.55, 47.37]}`, 536, true},
+		{"Point outside", `{"type": "Point", "coordinates": [8.55, 47.37]}`, 537, false},
+		{"Line crossing tile", `{"type": "LineString", "coordinates": [[8.0, 47.35], [9.2, 47.35]]}`, 536, true},
+		{"Line beside tile", `{"type": "LineString", "coordinates": [[8.0, 47.35], [8.4, 47.35]]}`, 536, false},
+		{"Tile in polygon hole", `{"type": "Polygon", "coordinates": [[[0, 40], [20, 40], [20, 55], [0, 55], [0, 40]], [[8, 47], [9, 47], [9, 48], [8, 48], [8, 47]]]}`, 536, false},
+		{"Tile in polygon", `{"type": "Polygon", "coordinates": [[[0, 40], [20, 40], [20, 55], [0, 55], [0, 40]], [[8, 47], [9, 47], [9, 48], [8, 48], [8, 47]]]}`, 530, true},
+		{"Collection", `{"type": "GeometryCollection", "geometries": [{"type": "MultiPoint", "coordinates": [[0, 0], [8.55, 47.37]]}]}`, 536, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			area, err := NewTileArea(json.RawMessage(tt.geometry), nil, 0, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testEquals(t, tt.expected, area.Intersects(webMercator, 10, tt.x, 358), "Intersects")
+		})
+	}
+
+	invalid := []struct {
+		name     string
+		geometry string
+		bbox     *Extent
+		minZoom  int
+		maxZoom  int
+	}{
+		{"No area", "", nil, 0, -1},
+		{"Unsupported type", `{"type": "Circle", "coordinates": [0, 0]}`, nil, 0, -1},
+		{"Invalid coordinates", `{"type": "LineString", "coordinates": [0, 0]}`, nil, 0, -1},
+		{"Empty geometry", `{"type": "MultiPoint", "coordinates": []}`, nil, 0, -1},
+		{"Invalid bbox", "", &Extent{Minx: 10, Miny: 0, Maxx: 0, Maxy: 10}, 0, -1},
+		{"Invalid zoom range", "", &Extent{Maxx: 1, Maxy: 1}, 8, 4},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			var geometry json.RawMessage
+			if tt.geometry != "" {
+				geometry = json.RawMessage(tt.geometry)
+			}
+			if _, err := NewTileArea(geometry, tt.bbox, tt.minZoom, tt.maxZoom); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
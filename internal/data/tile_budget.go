@@ -0,0 +1,118 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Priorities of the features kept when a tile exceeds the budget of its layer
+// (any other value is the name of a column, features with higher values are kept first)
+const (
+	PriorityArea   = "area"   // largest geometries first
+	PriorityRandom = "random" // stable pseudo-random sample (default)
+)
+
+// maxBudgetAttempts is the number of times a tile exceeding the size budget is generated
+// again with fewer features
+const maxBudgetAttempts = 3
+
+// hasTileBudget tests whether the tiles of a layer are limited in feature count or size
+func (layer *Layer) hasTileBudget() bool {
+	return layer.MaxFeatures > 0 || layer.MaxTileSizeKB > 0
+}
+
+// priorityExpr returns the expression ranking the features of a layer when tiles are thinned
+// (features with higher values are kept first)
+func (layer *Layer) priorityExpr(geomExpr string) string {
+	switch strings.ToLower(layer.Priority) {
+	case PriorityArea:
+		return fmt.Sprintf("ST_Area(%s)", geomExpr)
+	case PriorityRandom, "":
+		// a hash of the geometry keeps the same features in neighbouring tiles and at each generation
+		return fmt.Sprintf("hash(ST_AsWKB(%s))", geomExpr)
+	default:
+		return fmt.Sprintf(`"%s"`, layer.Priority)
+	}
+}
+
+// budgetTileQuery returns a function building the query of a tile with at most limit features
+// (no limit if limit <= 0). The query returns the tile and the number of features before thinning,
+// counted by a window function in the same pass over the features.
+func (layer *Layer) budgetTileQuery(propertyColumns, simplifiedExpr, geomExpr, filterCond string) func(limit int) string {
+	return func(limit int) string {
+		limitClause := ""
+		if limit > 0 {
+			limitClause = fmt.Sprintf("LIMIT %d", limit)
+		}
+		return fmt.Sprintf(`
+			WITH tile_bounds AS (
+				SELECT ST_MakeEnvelope($tile_xmin - $tile_margin, $tile_ymin - $tile_margin,
+				                       $tile_xmax + $tile_margin, $tile_ymax + $tile_margin) as envelope,
+				       ST_Extent(ST_MakeEnvelope($tile_xmin, $tile_ymin, $tile_xmax, $tile_ymax)) as extent
+			),
+			features AS (
+				SELECT
+					%s%s as geom,
+					%s as tile_priority
+				FROM %s, tile_bounds
+				WHERE ST_Intersects(%s, tile_bounds.envelope)%s
+			),
+			ranked AS MATERIALIZED (
+				SELECT *, count(*) OVER () as tile_feature_count
+				FROM features
+				WHERE geom IS NOT NULL
+				ORDER BY tile_priority DESC NULLS LAST
+				%s
+			),
+			kept AS (
+				SELECT * EXCLUDE (tile_priority, tile_feature_count)
+				FROM ranked
+			)
+			SELECT (SELECT %s FROM kept),
+			       (SELECT coalesce(max(tile_feature_count), 0) FROM ranked)
+		`, propertyColumns, layer.mvtGeomExpr(simplifiedExpr), layer.priorityExpr(geomExpr), layer.source(), geomExpr, filterCond,
+			limitClause, layer.mvtExpr("kept", layer.IDColumn != ""))
+	}
+}
+
+// generateBudgetTile generates a tile of a layer within the feature count and size budget of the layer.
+// Features are dropped in the order of the layer priority; a tile exceeding the size budget
+// is generated again with the number of features reduced in proportion to the excess size.
+// The dropped features are logged.
+func (layer *Layer) generateBudgetTile(ctx context.Context, db *sql.DB, query func(limit int) string, args []interface{}, z, x, y int) ([]byte, error) {
+	limit := layer.MaxFeatures
+	maxBytes := layer.MaxTileSizeKB * 1024
+
+	var tileData []byte
+	var total int
+	if err := db.QueryRowContext(ctx, query(limit), args...).Scan(&tileData, &total); err != nil {
+		return nil, err
+	}
+	kept := total
+	if limit > 0 && limit < total {
+		kept = limit
+	}
+
+	for attempt := 0; maxBytes > 0 && len(tileData) > maxBytes && kept > 1 && attempt < maxBudgetAttempts; attempt++ {
+		// aim below the budget, since features differ in size
+		limit = max(1, int(float64(kept)*float64(maxBytes)/float64(len(tileData))*0.9))
+		if err := db.QueryRowContext(ctx, query(limit), args...).Scan(&tileData, &total); err != nil {
+			return nil, err
+		}
+		kept = min(limit, total)
+	}
+
+	if kept < total {
+		log.Infof("Layer %s tile %d/%d/%d: dropped %d of %d features to stay within the tile budget (%d bytes)",
+			layer.Name, z, x, y, total-kept, total, len(tileData))
+	}
+	if maxBytes > 0 && len(tileData) > maxBytes {
+		log.Warnf("Layer %s tile %d/%d/%d: %d bytes exceed the tile size budget of %d KB",
+			layer.Name, z, x, y, len(tileData), layer.MaxTileSizeKB)
+	}
+	return tileData, nil
+}
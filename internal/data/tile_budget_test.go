@@ -0,0 +1,67 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+	"github.com/tobilg/duckdb-tileserver/internal/mvt"
+)
+
+func TestPriorityExpr(t *testing.T) {
+	layer := &Layer{Name: "parcels"}
+	testEquals(t, "hash(ST_AsWKB(geom))", layer.priorityExpr("geom"), "Default priority")
+	layer.Priority = "Area"
+	testEquals(t, "ST_Area(geom)", layer.priorityExpr("geom"), "Area priority")
+	layer.Priority = "population"
+	testEquals(t, `"population"`, layer.priorityExpr("geom"), "Column priority")
+}
+
+func TestBudgetTileQuery(t *testing.T) {
+	layer := &Layer{Name: "parcels", Table: "parcels", MaxTileSizeKB: 500}
+	testEquals(t, true, layer.hasTileBudget(), "Size budget")
+	testEquals(t, false, (&Layer{Name: "roads"}).hasTileBudget(), "No budget")
+
+	query := layer.budgetTileQuery("", "geom", "geom", "")
+	if strings.Contains(query(0), "LIMIT") {
+		t.Errorf("Expected no limit in query %s", query(0))
+	}
+	if !strings.Contains(query(100), "LIMIT 100") {
+		t.Errorf("Expected limit in query %s", query(100))
+	}
+	// the features are read once, and counted before the limit applies
+	testEquals(t, 1, strings.Count(query(100), "FROM features"), "Feature scans")
+	if !strings.Contains(query(100), "count(*) OVER ()") {
+		t.Errorf("Expected window count in query %s", query(100))
+	}
+}
+
+func TestBudgetTile(t *testing.T) {
+	cat := newSpatialTestCatalog(t)
+	// 2000 points in Zurich, in tile 10/536/358
+	_, err := cat.dbconn.Exec(`CREATE TABLE sensors AS
+		SELECT i AS id, 'sensor number ' || i AS name, ST_Point(8.5 + i * 0.000005, 47.3 + i * 0.000005) AS geom
+		FROM range(2000) t(i)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Configuration.Layers = []conf.Layer{{Name: "sensors", MaxTileSizeKB: 4}}
+	webMercator := TileMatrixSetByID(TileMatrixSetWebMercatorQuad)
+	data, err := cat.GenerateTile(context.Background(), "sensors", webMercator, 10, 536, 358, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > 4*1024 {
+		t.Errorf("Expected the tile to stay within 4 KB, got %d bytes", len(data))
+	}
+	tile, err := mvt.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, 1, len(tile.Layers), "Tile layers")
+	if n := len(tile.Layers[0].Features); n == 0 || n >= 2000 {
+		t.Errorf("Expected features to be dropped, got %d of 2000 features", n)
+	}
+}
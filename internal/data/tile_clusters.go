@@ -0,0 +1,71 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DefaultClusterSize is the default size of the cluster grid cells in pixels
+const DefaultClusterSize = 64
+
+// clusterCountProperty is the property holding the number of points of a cluster
+const clusterCountProperty = "point_count"
+
+// clusters tests whether the points of a layer are aggregated into clusters at zoom level z
+func (layer *Layer) clusters(z int) bool {
+	return layer.ClusterMaxZoom > 0 && z <= layer.ClusterMaxZoom &&
+		strings.Contains(strings.ToUpper(layer.GeometryType), "POINT")
+}
+
+// clusterTileQuery returns the query generating a tile of clusters, and its arguments.
+// The points of the tile are snapped to a grid of cells of ClusterSize pixels,
+// aligned with the origin of the tile matrix so that a cell is the same in all tiles.
+// Each cell with points is encoded as a single point at the mean position of its points,
+// with the number of points and the configured aggregates as properties.
+// Clusters have no feature id, since the IDColumn of the points does not identify a cluster.
+// Points are selected in the tile only (not in its buffer), so that each cluster appears in a single tile.
+func (layer *Layer) clusterTileQuery(geomExpr string, filterCond string, tm *TileMatrix) (string, []interface{}) {
+	aggregates := ""
+	for _, field := range layer.ClusterFields {
+		aggregates += ", " + field
+	}
+
+	gridSize := layer.ClusterSize
+	if gridSize <= 0 {
+		gridSize = DefaultClusterSize
+	}
+
+	// multi-points are clustered by their centroid
+	pointExpr := fmt.Sprintf("ST_Centroid(%s)", geomExpr)
+	query := fmt.Sprintf(`
+		WITH tile_bounds AS (
+			SELECT ST_MakeEnvelope($tile_xmin, $tile_ymin, $tile_xmax, $tile_ymax) as envelope,
+			       ST_Extent(ST_MakeEnvelope($tile_xmin, $tile_ymin, $tile_xmax, $tile_ymax)) as extent
+		),
+		clusters AS (
+			SELECT count(*) AS %s%s,
+				ST_Point(avg(ST_X(%s)), avg(ST_Y(%s))) as cluster_geom
+			FROM %s, tile_bounds
+			WHERE ST_Intersects(%s, tile_bounds.envelope)%s
+			GROUP BY floor((ST_X(%s) - $cluster_origin_x) / $cluster_cell_size),
+				floor(($cluster_origin_y - ST_Y(%s)) / $cluster_cell_size)
+		),
+		features AS (
+			SELECT * EXCLUDE (cluster_geom),
+				%s as geom
+			FROM clusters
+		)
+		SELECT %s
+		FROM features
+		WHERE geom IS NOT NULL
+	`, clusterCountProperty, aggregates, pointExpr, pointExpr, layer.source(), geomExpr, filterCond,
+		pointExpr, pointExpr, layer.mvtGeomExpr("cluster_geom"), layer.mvtExpr("features", false))
+
+	args := []interface{}{
+		sql.Named("cluster_origin_x", tm.PointOfOrigin[0]),
+		sql.Named("cluster_origin_y", tm.PointOfOrigin[1]),
+		sql.Named("cluster_cell_size", float64(gridSize)*tm.CellSize),
+	}
+	return query, args
+}
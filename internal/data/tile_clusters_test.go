@@ -0,0 +1,89 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+	"github.com/tobilg/duckdb-tileserver/internal/mvt"
+)
+
+func TestClusterTileQuery(t *testing.T) {
+	layer := &Layer{
+		Name:           "sensors",
+		Table:          "sensors",
+		GeometryType:   "POINT",
+		ClusterMaxZoom: 9,
+		ClusterFields:  []string{"max(value) AS max_value"},
+	}
+	if !layer.clusters(0) || !layer.clusters(9) || layer.clusters(10) {
+		t.Errorf("Unexpected clustering zoom range [0-%d]", layer.ClusterMaxZoom)
+	}
+	polygons := &Layer{Name: "parcels", GeometryType: "POLYGON", ClusterMaxZoom: 9}
+	testEquals(t, false, polygons.clusters(5), "Polygons are not clustered")
+
+	tm := TileMatrixSetByID(TileMatrixSetWebMercatorQuad).TileMatrix(4)
+	query, args := layer.clusterTileQuery("geom", "", tm)
+	if !strings.Contains(query, "count(*) AS point_count, max(value) AS max_value,") {
+		t.Errorf("Expected cluster properties in query %s", query)
+	}
+	testEquals(t, []interface{}{
+		sql.Named("cluster_origin_x", tm.PointOfOrigin[0]),
+		sql.Named("cluster_origin_y", tm.PointOfOrigin[1]),
+		sql.Named("cluster_cell_size", DefaultClusterSize*tm.CellSize),
+	}, args, "Cluster grid arguments")
+
+	// the grid cells are a quarter of a tile
+	layer.ClusterSize = tm.TileWidth / 4
+	_, args = layer.clusterTileQuery("geom", "", tm)
+	testEquals(t, sql.Named("cluster_cell_size", float64(tm.TileWidth)*tm.CellSize/4), args[2], "Cluster cell size")
+}
+
+func TestClusterTile(t *testing.T) {
+	cat := newSpatialTestCatalog(t)
+	// 100 points in Zurich, in tile 8/134/89 (and 10/536/358)
+	_, err := cat.dbconn.Exec(`CREATE TABLE sensors AS
+		SELECT i AS id, i::DOUBLE AS value, ST_Point(8.5 + i * 0.0001, 47.3 + i * 0.0001) AS geom
+		FROM range(100) t(i)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Configuration.Layers = []conf.Layer{{Name: "sensors", ClusterMaxZoom: 9}}
+	layer, err := cat.GetLayerByName("sensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, true, layer.clusters(8), "Point layer clustered")
+
+	webMercator := TileMatrixSetByID(TileMatrixSetWebMercatorQuad)
+	decodeLayer := func(z, x, y int) *mvt.Layer {
+		t.Helper()
+		data, err := cat.GenerateTile(context.Background(), "sensors", webMercator, z, x, y, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tile, err := mvt.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testEquals(t, 1, len(tile.Layers), "Tile layers")
+		return tile.Layers[0]
+	}
+
+	clustered := decodeLayer(8, 134, 89)
+	if len(clustered.Features) == 0 || len(clustered.Features) >= 100 {
+		t.Errorf("Expected the points to be aggregated into clusters, got %d features", len(clustered.Features))
+	}
+	hasCount := false
+	for _, key := range clustered.Keys {
+		hasCount = hasCount || key == clusterCountProperty
+	}
+	testEquals(t, true, hasCount, "Cluster point count property")
+
+	// above the cluster max zoom the points are served as they are
+	points := decodeLayer(10, 536, 358)
+	testEquals(t, 100, len(points.Features), "Points above cluster max zoom")
+}
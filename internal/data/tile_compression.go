@@ -0,0 +1,73 @@
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+)
+
+// Tiles are kept compressed with gzip in the tile cache, and served as is to clients accepting gzip.
+// An MVT tile (a protobuf message of layers) never starts with the gzip magic number,
+// so compressed tiles are recognized by their content.
+
+// GzipTile compresses a tile with gzip.
+// Empty and already compressed tiles are returned unchanged.
+func GzipTile(tile []byte) ([]byte, error) {
+	if len(tile) == 0 || IsGzipTile(tile) {
+		return tile, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(tile); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GunzipTile decompresses a tile compressed with gzip.
+// Uncompressed tiles are returned unchanged.
+func GunzipTile(tile []byte) ([]byte, error) {
+	if !IsGzipTile(tile) {
+		return tile, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(tile))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// IsGzipTile tests whether a tile is compressed with gzip
+func IsGzipTile(tile []byte) bool {
+	return len(tile) >= 2 && tile[0] == 0x1f && tile[1] == 0x8b
+}
+
+// GenerateGzipTile generates the tile of a layer, or the composite tile of several layers,
+// compressed with gzip. The tiles of an archive layer are read compressed from the archive.
+func (cat *CatalogDB) GenerateGzipTile(ctx context.Context, layerNames []string, tms *TileMatrixSet, z, x, y int, params map[string]string) ([]byte, error) {
+	if len(layerNames) > 1 {
+		tile, err := cat.GenerateCompositeTile(ctx, layerNames, tms, z, x, y, params)
+		if err != nil {
+			return nil, err
+		}
+		return GzipTile(tile)
+	}
+
+	layer, err := cat.GetLayerByName(layerNames[0])
+	if err != nil {
+		return nil, err
+	}
+	if layer.archive != nil {
+		return layer.archiveTile(ctx, tms, z, x, y, true)
+	}
+	tile, err := cat.GenerateTile(ctx, layerNames[0], tms, z, x, y, params)
+	if err != nil {
+		return nil, err
+	}
+	return GzipTile(tile)
+}
@@ -0,0 +1,70 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// featureIDColumn is the column of the rows encoded by ST_AsMVT holding the feature ids
+const featureIDColumn = "tile_feature_id"
+
+// integerTypes are the DuckDB integer types encoded as MVT integer values
+var integerTypes = map[string]bool{
+	"TINYINT": true, "SMALLINT": true, "INTEGER": true, "BIGINT": true,
+	"UTINYINT": true, "USMALLINT": true, "UINTEGER": true, "UBIGINT": true,
+}
+
+// featureIDTypes are the DuckDB types of columns which can be used as MVT feature ids.
+// The ids are cast to BIGINT, so UBIGINT and HUGEINT columns are not supported (their values may overflow).
+var featureIDTypes = map[string]bool{
+	"TINYINT": true, "SMALLINT": true, "INTEGER": true, "BIGINT": true,
+	"UTINYINT": true, "USMALLINT": true, "UINTEGER": true,
+}
+
+// queryPrimaryKey returns the single-column integer primary key of the table of a layer
+// ("" if the layer is not a table or has no such key)
+func (cat *CatalogDB) queryPrimaryKey(layer *Layer) string {
+	if layer.Table == "" {
+		return ""
+	}
+	query := `
+		SELECT constraint_column_names[1]
+		FROM duckdb_constraints()
+		WHERE table_name = $1 AND constraint_type = 'PRIMARY KEY' AND len(constraint_column_names) = 1
+		LIMIT 1
+	`
+	var column string
+	if err := cat.dbconn.QueryRow(query, layer.Table).Scan(&column); err != nil {
+		return ""
+	}
+	if !featureIDTypes[strings.ToUpper(layer.PropertyTypes[column])] {
+		return ""
+	}
+	return column
+}
+
+// setIDColumn sets the configured feature id column of a layer.
+// The column must be a column of the layer with an integer type fitting into BIGINT.
+func (layer *Layer) setIDColumn(column string) {
+	dataType, ok := layer.PropertyTypes[column]
+	if !ok {
+		log.Warnf("Layer %s: configured id column %s does not exist", layer.Name, column)
+		return
+	}
+	if !featureIDTypes[strings.ToUpper(dataType)] {
+		log.Warnf("Layer %s: id column %s has an unsupported type (%s)", layer.Name, column, dataType)
+		return
+	}
+	layer.IDColumn = column
+}
+
+// featureIDSelect returns the select list item providing the feature ids of the tile features
+// ("" if the layer has no id column)
+func (layer *Layer) featureIDSelect() string {
+	if layer.IDColumn == "" {
+		return ""
+	}
+	return fmt.Sprintf(`CAST("%s" AS BIGINT) as %s, `, layer.IDColumn, featureIDColumn)
+}
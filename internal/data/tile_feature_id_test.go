@@ -0,0 +1,53 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+)
+
+func TestFeatureIDColumn(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+
+	conf.Configuration.Layers = []conf.Layer{
+		{Name: "parcels", IdColumn: "parcel_id", Properties: []string{"name"}},
+		{Name: "roads", IdColumn: "name"},
+		{Name: "sensors", IdColumn: "sensor_id"},
+		{Name: "stations", IdColumn: "station_id"},
+	}
+	propertyTypes := map[string]string{"parcel_id": "BIGINT", "name": "VARCHAR"}
+
+	// the configured id column replaces the primary key, and needs not be a property
+	layer := &Layer{
+		Name:          "parcels",
+		IDColumn:      "fid",
+		Properties:    []string{"name", "parcel_id"},
+		PropertyTypes: map[string]string{"parcel_id": "BIGINT", "name": "VARCHAR"},
+	}
+	applyLayerConfig(layer)
+	testEquals(t, "parcel_id", layer.IDColumn, "Configured id column")
+	testEquals(t, []string{"name"}, layer.Properties, "Properties")
+	testEquals(t, `CAST("parcel_id" AS BIGINT) as tile_feature_id, `, layer.featureIDSelect(), "Feature id select")
+	testEquals(t, "ST_AsMVT(features, 'parcels', 4096, 'geom', 'tile_feature_id')",
+		layer.mvtExpr("features", true), "MVT layer with feature ids")
+
+	// non-integer columns are ignored
+	roads := &Layer{Name: "roads", Properties: []string{"name", "parcel_id"}, PropertyTypes: propertyTypes}
+	applyLayerConfig(roads)
+	testEquals(t, "", roads.IDColumn, "Non-integer id column")
+	testEquals(t, "", roads.featureIDSelect(), "No feature id select")
+
+	// integer columns which may overflow BIGINT are ignored
+	sensors := &Layer{Name: "sensors", PropertyTypes: map[string]string{"sensor_id": "UBIGINT"}}
+	applyLayerConfig(sensors)
+	testEquals(t, "", sensors.IDColumn, "UBIGINT id column")
+	stations := &Layer{Name: "stations", PropertyTypes: map[string]string{"station_id": "HUGEINT"}}
+	applyLayerConfig(stations)
+	testEquals(t, "", stations.IDColumn, "HUGEINT id column")
+	uint32Layer := &Layer{Name: "sensors", PropertyTypes: map[string]string{"sensor_id": "UINTEGER"}}
+	applyLayerConfig(uint32Layer)
+	testEquals(t, "sensor_id", uint32Layer.IDColumn, "UINTEGER id column")
+}
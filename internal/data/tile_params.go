@@ -0,0 +1,150 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+)
+
+// ErrInvalidParameter is returned when a tile request parameter value
+// cannot be converted to the declared parameter type
+var ErrInvalidParameter = errors.New("invalid parameter")
+
+// reservedParamNames are the parameter names bound by tile queries
+// (tile coordinates, bounds and buffer margin, and cluster grid)
+var reservedParamNames = map[string]bool{
+	"z": true, "x": true, "y": true,
+	"tile_xmin": true, "tile_ymin": true, "tile_xmax": true, "tile_ymax": true,
+	"tile_margin":      true,
+	"cluster_origin_x": true, "cluster_origin_y": true, "cluster_cell_size": true,
+}
+
+// LayerParameter is a named parameter of a layer.
+// Parameters are referenced as $name in the layer Sql and Filter,
+// and their values are bound from the tile request query string.
+type LayerParameter struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default string `json:"default,omitempty"`
+}
+
+// layerParameters returns the parameters declared in a layer configuration
+func layerParameters(layerConf *conf.Layer) []LayerParameter {
+	var params []LayerParameter
+	for _, p := range layerConf.Parameters {
+		name := p.Name
+		if name == "" || reservedParamNames[strings.ToLower(name)] {
+			log.Warnf("Layer %s: ignoring parameter with invalid name '%s'", layerConf.Name, p.Name)
+			continue
+		}
+		typ := strings.ToUpper(p.Type)
+		if typ == "" {
+			typ = DuckDBTypeText
+		}
+		params = append(params, LayerParameter{
+			Name:    name,
+			Type:    typ,
+			Default: p.Default,
+		})
+	}
+	return params
+}
+
+// paramRefPattern matches the $name parameter references of a SQL statement
+var paramRefPattern = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// stringLiteralPattern matches SQL string literals (which may contain a $ sign)
+var stringLiteralPattern = regexp.MustCompile(`'(?:[^']|'')*'`)
+
+// checkParameterRefs checks that every $name referenced by the Sql and Filter of a layer configuration
+// is a declared parameter of the layer or a reserved tile parameter.
+// Undeclared references would otherwise be bound to an unrelated positional argument.
+func checkParameterRefs(layerConf *conf.Layer) error {
+	if layerConf == nil {
+		return nil
+	}
+	declared := make(map[string]bool)
+	for _, param := range layerConf.Parameters {
+		if reservedParamNames[strings.ToLower(param.Name)] {
+			return fmt.Errorf("%w: layer %s declares reserved parameter %s", ErrInvalidParameter, layerConf.Name, param.Name)
+		}
+		declared[param.Name] = true
+	}
+	for _, statement := range []string{layerConf.Sql, layerConf.Filter} {
+		statement = stringLiteralPattern.ReplaceAllString(statement, "''")
+		for _, match := range paramRefPattern.FindAllStringSubmatch(statement, -1) {
+			name := match[1]
+			if !declared[name] && !reservedParamNames[strings.ToLower(name)] {
+				return fmt.Errorf("%w: layer %s references undeclared parameter $%s", ErrInvalidParameter, layerConf.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// bindParameters returns the named SQL arguments for the layer parameters.
+// Values missing from the request use the parameter default;
+// an empty value is bound as NULL.
+func (layer *Layer) bindParameters(values map[string]string) ([]interface{}, error) {
+	args := make([]interface{}, 0, len(layer.Parameters))
+	for _, param := range layer.Parameters {
+		value, ok := values[param.Name]
+		if !ok {
+			value = param.Default
+		}
+		arg, err := param.convert(value)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, sql.Named(param.Name, arg))
+	}
+	return args, nil
+}
+
+// defaultArgs returns the named SQL arguments binding the parameter defaults
+// (used for metadata queries on the layer source).
+// The tile coordinates and bounds which the layer query may reference
+// are bound to the world tile 0/0/0 of WebMercatorQuad.
+func (layer *Layer) defaultArgs() []interface{} {
+	args, err := layer.bindParameters(nil)
+	if err != nil {
+		log.Warnf("Layer %s: invalid parameter default: %v", layer.Name, err)
+		return nil
+	}
+	bounds := TileMatrixSetByID(TileMatrixSetWebMercatorQuad).TileBounds(0, 0, 0)
+	return append([]interface{}{
+		sql.Named("z", 0), sql.Named("x", 0), sql.Named("y", 0),
+		sql.Named("tile_xmin", bounds.Minx), sql.Named("tile_ymin", bounds.Miny),
+		sql.Named("tile_xmax", bounds.Maxx), sql.Named("tile_ymax", bounds.Maxy),
+		sql.Named("tile_margin", 0.0),
+	}, args...)
+}
+
+// convert converts a parameter value to the Go type matching the parameter type
+func (param *LayerParameter) convert(value string) (interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var arg interface{}
+	var err error
+	switch param.Type {
+	case "INTEGER", "BIGINT", "SMALLINT", "TINYINT":
+		arg, err = strconv.ParseInt(value, 10, 64)
+	case "DOUBLE", "REAL", "FLOAT", "DECIMAL", "NUMERIC":
+		arg, err = strconv.ParseFloat(value, 64)
+	case "BOOLEAN":
+		arg, err = strconv.ParseBool(value)
+	default:
+		arg = value
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be of type %s, got '%s'", ErrInvalidParameter, param.Name, param.Type, value)
+	}
+	return arg, nil
+}
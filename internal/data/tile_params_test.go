@@ -0,0 +1,97 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+)
+
+func TestLayerParameters(t *testing.T) {
+	layerConf := &conf.Layer{
+		Name: "roads",
+		Parameters: []conf.LayerParameter{
+			{Name: "year", Type: "integer", Default: "2020"},
+			{Name: "category"},
+			{Name: "z"},
+			{Name: ""},
+		},
+	}
+
+	params := layerParameters(layerConf)
+	expected := []LayerParameter{
+		{Name: "year", Type: "INTEGER", Default: "2020"},
+		{Name: "category", Type: "VARCHAR"},
+	}
+	testEquals(t, expected, params, "Layer parameters")
+}
+
+func TestCheckParameterRefs(t *testing.T) {
+	layerConf := &conf.Layer{
+		Name:       "roads",
+		Sql:        "SELECT * FROM roads WHERE year = $year AND z_min <= $z AND note <> 'costs $5'",
+		Filter:     "class = $category",
+		Parameters: []conf.LayerParameter{{Name: "year", Type: "INTEGER"}, {Name: "category"}},
+	}
+	if err := checkParameterRefs(layerConf); err != nil {
+		t.Errorf("Expected declared and reserved parameters to be accepted, got %v", err)
+	}
+
+	layerConf.Filter = "class = $kind"
+	if err := checkParameterRefs(layerConf); !errors.Is(err, ErrInvalidParameter) {
+		t.Errorf("Expected undeclared parameter to be rejected, got %v", err)
+	}
+	testEquals(t, nil, checkParameterRefs(nil), "Layer without configuration")
+
+	// parameters must not shadow the arguments bound by tile queries
+	for _, name := range []string{"z", "tile_margin", "cluster_origin_x", "cluster_origin_y", "cluster_cell_size"} {
+		reserved := &conf.Layer{Name: "roads", Parameters: []conf.LayerParameter{{Name: name}}}
+		if err := checkParameterRefs(reserved); !errors.Is(err, ErrInvalidParameter) {
+			t.Errorf("Expected reserved parameter %s to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestBindParameters(t *testing.T) {
+	layer := &Layer{
+		Name: "roads",
+		Parameters: []LayerParameter{
+			{Name: "year", Type: "INTEGER", Default: "2020"},
+			{Name: "category", Type: "VARCHAR"},
+			{Name: "width", Type: "DOUBLE"},
+			{Name: "paved", Type: "BOOLEAN", Default: "true"},
+		},
+	}
+
+	args, err := layer.bindParameters(map[string]string{"year": "2015", "category": "road", "other": "ignored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{
+		sql.Named("year", int64(2015)),
+		sql.Named("category", "road"),
+		sql.Named("width", nil),
+		sql.Named("paved", true),
+	}
+	testEquals(t, expected, args, "Bound parameters")
+
+	// metadata queries bind the tile arguments to the world tile
+	defaultArgs := layer.defaultArgs()
+	testEquals(t, sql.Named("z", 0), defaultArgs[0], "Metadata tile zoom")
+	testEquals(t, []interface{}{
+		sql.Named("year", int64(2020)),
+		sql.Named("category", nil),
+		sql.Named("width", nil),
+		sql.Named("paved", true),
+	}, defaultArgs[len(defaultArgs)-4:], "Default parameters")
+
+	_, err = layer.bindParameters(map[string]string{"year": "last"})
+	if !errors.Is(err, ErrInvalidParameter) {
+		t.Errorf("Expected ErrInvalidParameter for invalid integer, got %v", err)
+	}
+	_, err = layer.bindParameters(map[string]string{"width": "wide"})
+	if !errors.Is(err, ErrInvalidParameter) {
+		t.Errorf("Expected ErrInvalidParameter for invalid double, got %v", err)
+	}
+}
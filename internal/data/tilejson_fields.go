@@ -0,0 +1,104 @@
+package data
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TileJSON field types of the MVT property values
+const (
+	FieldTypeNumber  = "Number"
+	FieldTypeString  = "String"
+	FieldTypeBoolean = "Boolean"
+)
+
+// tileJSONFieldType returns the TileJSON field type of a property with the given DuckDB type,
+// as encoded in the tiles (types which are not supported by MVT are cast to VARCHAR or DOUBLE)
+func tileJSONFieldType(dataType string) string {
+	dataType = strings.ToUpper(dataType)
+	switch {
+	case dataType == "BOOLEAN":
+		return FieldTypeBoolean
+	case integerTypes[dataType], dataType == "FLOAT", dataType == "DOUBLE", dataType == "REAL",
+		strings.HasPrefix(dataType, "DECIMAL"), strings.HasPrefix(dataType, "NUMERIC"):
+		return FieldTypeNumber
+	default:
+		return FieldTypeString
+	}
+}
+
+// vectorLayerFields returns the TileJSON fields of a layer:
+// the type of each property, followed by the column comment if there is one.
+// Cluster tiles also contain the point count of the clusters.
+func (layer *Layer) vectorLayerFields(comments map[string]string) map[string]string {
+	fields := make(map[string]string)
+	for _, prop := range layer.Properties {
+		fields[prop] = tileJSONFieldType(layer.PropertyTypes[prop])
+		if comment := comments[prop]; comment != "" {
+			fields[prop] += ": " + comment
+		}
+	}
+	if layer.clusters(layer.MinZoom) {
+		fields[clusterCountProperty] = FieldTypeNumber + ": Number of points of a cluster"
+	}
+	return fields
+}
+
+// queryColumnComments returns the comments of the columns of the table of a layer, by column name
+func (cat *CatalogDB) queryColumnComments(layer *Layer) map[string]string {
+	if layer.Table == "" {
+		return nil
+	}
+	query := `
+		SELECT column_name, comment
+		FROM duckdb_columns
+		WHERE table_name = $1 AND comment IS NOT NULL AND comment <> ''
+	`
+	rows, err := cat.dbconn.Query(query, layer.Table)
+	if err != nil {
+		log.Warnf("Error getting column comments of layer %s: %v", layer.Name, err)
+		return nil
+	}
+	defer rows.Close()
+
+	comments := make(map[string]string)
+	for rows.Next() {
+		var column, comment string
+		if err := rows.Scan(&column, &comment); err != nil {
+			continue
+		}
+		comments[column] = comment
+	}
+	return comments
+}
+
+// mvtGeometryType returns the MVT geometry type (Point, LineString or Polygon)
+// of the features of a layer with the given geometry type ("" if it is mixed or unknown)
+func mvtGeometryType(geometryType string) string {
+	geometryType = strings.ToUpper(geometryType)
+	switch {
+	case strings.Contains(geometryType, "POINT"):
+		return "Point"
+	case strings.Contains(geometryType, "LINESTRING"):
+		return "LineString"
+	case strings.Contains(geometryType, "POLYGON"):
+		return "Polygon"
+	default:
+		return ""
+	}
+}
+
+// layersAttribution returns the attribution of a tile source combining layers:
+// the distinct attributions of the layers
+func layersAttribution(layers []*Layer) string {
+	var attributions []string
+	seen := make(map[string]bool)
+	for _, layer := range layers {
+		if layer.Attribution != "" && !seen[layer.Attribution] {
+			seen[layer.Attribution] = true
+			attributions = append(attributions, layer.Attribution)
+		}
+	}
+	return strings.Join(attributions, " | ")
+}
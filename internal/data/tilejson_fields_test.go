@@ -0,0 +1,53 @@
+package data
+
+import "testing"
+
+func TestTileJSONFieldType(t *testing.T) {
+	tests := map[string]string{
+		"VARCHAR":       FieldTypeString,
+		"BIGINT":        FieldTypeNumber,
+		"DOUBLE":        FieldTypeNumber,
+		"DECIMAL(18,3)": FieldTypeNumber,
+		"BOOLEAN":       FieldTypeBoolean,
+		"TIMESTAMP":     FieldTypeString,
+		"HUGEINT":       FieldTypeString,
+		"VARCHAR[]":     FieldTypeString,
+	}
+	for dataType, expected := range tests {
+		testEquals(t, expected, tileJSONFieldType(dataType), dataType)
+	}
+}
+
+func TestLayerVectorLayerFields(t *testing.T) {
+	layer := &Layer{
+		Name:          "sensors",
+		GeometryType:  "POINT",
+		Properties:    []string{"active", "height", "name"},
+		PropertyTypes: map[string]string{"active": "BOOLEAN", "height": "FLOAT", "name": "VARCHAR"},
+	}
+	testEquals(t, map[string]string{
+		"active": "Boolean",
+		"height": "Number: Height in meters",
+		"name":   "String",
+	}, layer.vectorLayerFields(map[string]string{"height": "Height in meters"}), "Fields")
+
+	layer.ClusterMaxZoom = 9
+	testEquals(t, "Number: Number of points of a cluster", layer.vectorLayerFields(nil)[clusterCountProperty], "Cluster point count")
+}
+
+func TestMVTGeometryType(t *testing.T) {
+	testEquals(t, "Point", mvtGeometryType("MULTIPOINT"), "Multipoint")
+	testEquals(t, "LineString", mvtGeometryType("LINESTRING"), "Linestring")
+	testEquals(t, "Polygon", mvtGeometryType("MULTIPOLYGON"), "Multipolygon")
+	testEquals(t, "", mvtGeometryType("GEOMETRY"), "Mixed geometries")
+}
+
+func TestLayersAttribution(t *testing.T) {
+	layers := []*Layer{
+		{Name: "roads", Attribution: "© OpenStreetMap contributors"},
+		{Name: "water", Attribution: "© OpenStreetMap contributors"},
+		{Name: "parcels", Attribution: "© Land Registry"},
+		{Name: "sensors"},
+	}
+	testEquals(t, "© OpenStreetMap contributors | © Land Registry", layersAttribution(layers), "Attribution")
+}
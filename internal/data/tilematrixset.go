@@ -0,0 +1,335 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+)
+
+// Tile matrix set constants
+const (
+	TileMatrixSetWebMercatorQuad = "WebMercatorQuad"
+	TileMatrixSetWorldCRS84Quad  = "WorldCRS84Quad"
+
+	// webMercatorExtent is half the width of the Web Mercator projected world
+	webMercatorExtent = 20037508.3427892
+	// standardPixelSize is the OGC standardized rendering pixel size (0.28 mm)
+	standardPixelSize = 0.00028
+	// metersPerDegree is the size of a degree at the equator of the WGS84 ellipsoid
+	metersPerDegree = 2 * math.Pi * 6378137 / 360
+	// defaultTileSize is the width and height of a tile in pixels
+	defaultTileSize = 256
+	// quadMaxZoom is the deepest tile matrix of the built-in tile matrix sets
+	quadMaxZoom = 24
+)
+
+// ErrTileMatrixSetMismatch is returned when the layers of a composite tile
+// are configured with different tile matrix sets
+var ErrTileMatrixSetMismatch = errors.New("layers use different tile matrix sets")
+
+// TileMatrixSet describes a tiling scheme following the
+// OGC Two Dimensional Tile Matrix Set standard (JSON encoding).
+// Coordinates are always given in x, y (easting, northing / longitude, latitude) order.
+type TileMatrixSet struct {
+	ID           string        `json:"id"`
+	Title        string        `json:"title,omitempty"`
+	URI          string        `json:"uri,omitempty"`
+	Crs          string        `json:"crs"`
+	OrderedAxes  []string      `json:"orderedAxes,omitempty"`
+	BoundingBox  *BoundingBox  `json:"boundingBox,omitempty"`
+	TileMatrices []*TileMatrix `json:"tileMatrices"`
+	Srid         int           `json:"-"` // EPSG code of the CRS (used to transform the data)
+}
+
+// BoundingBox is the extent covered by a tile matrix set
+type BoundingBox struct {
+	LowerLeft  [2]float64 `json:"lowerLeft"`
+	UpperRight [2]float64 `json:"upperRight"`
+}
+
+// TileMatrix describes the tiles of one zoom level of a tile matrix set
+type TileMatrix struct {
+	ID               string     `json:"id"`
+	ScaleDenominator float64    `json:"scaleDenominator"`
+	CellSize         float64    `json:"cellSize"`
+	CornerOfOrigin   string     `json:"cornerOfOrigin,omitempty"`
+	PointOfOrigin    [2]float64 `json:"pointOfOrigin"`
+	TileWidth        int        `json:"tileWidth"`
+	TileHeight       int        `json:"tileHeight"`
+	MatrixWidth      int        `json:"matrixWidth"`
+	MatrixHeight     int        `json:"matrixHeight"`
+}
+
+// TileMatrixLimits is the range of tiles of a tile matrix containing data
+type TileMatrixLimits struct {
+	TileMatrix string `json:"tileMatrix"`
+	MinTileRow int    `json:"minTileRow"`
+	MaxTileRow int    `json:"maxTileRow"`
+	MinTileCol int    `json:"minTileCol"`
+	MaxTileCol int    `json:"maxTileCol"`
+}
+
+var (
+	tileMatrixSets     []*TileMatrixSet
+	tileMatrixSetsOnce sync.Once
+)
+
+// TileMatrixSets returns the supported tile matrix sets:
+// the built-in WebMercatorQuad and WorldCRS84Quad, followed by the configured ones
+func TileMatrixSets() []*TileMatrixSet {
+	tileMatrixSetsOnce.Do(func() {
+		tileMatrixSets = loadTileMatrixSets(conf.Configuration.TileMatrixSets)
+	})
+	return tileMatrixSets
+}
+
+// TileMatrixSetByID returns the tile matrix set with the given id,
+// or nil if it is not supported
+func TileMatrixSetByID(id string) *TileMatrixSet {
+	for _, tms := range TileMatrixSets() {
+		if tms.ID == id {
+			return tms
+		}
+	}
+	return nil
+}
+
+// LayerTileMatrixSet returns the tile matrix set used by the tiles of the given layers.
+// All the layers of a composite tile must use the same tile matrix set.
+func LayerTileMatrixSet(layerNames []string) (*TileMatrixSet, error) {
+	var tms *TileMatrixSet
+	for _, name := range layerNames {
+		layerTms := TileMatrixSetByID(layerTileMatrixSetID(conf.Configuration.LayerConfig(name)))
+		if tms != nil && layerTms != tms {
+			return nil, fmt.Errorf("%w: %s uses %s, not %s", ErrTileMatrixSetMismatch, name, layerTms.ID, tms.ID)
+		}
+		tms = layerTms
+	}
+	if tms == nil {
+		tms = TileMatrixSetByID(TileMatrixSetWebMercatorQuad)
+	}
+	return tms, nil
+}
+
+// layerTileMatrixSetID returns the id of the tile matrix set configured for a layer
+func layerTileMatrixSetID(layerConf *conf.Layer) string {
+	if layerConf == nil || layerConf.TileMatrixSet == "" {
+		return TileMatrixSetWebMercatorQuad
+	}
+	// tile archives are always tiled in Web Mercator
+	if layerConf.Archive != "" && layerConf.TileMatrixSet != TileMatrixSetWebMercatorQuad {
+		log.Warnf("Layer %s: archive layers only support the %s tile matrix set", layerConf.Name, TileMatrixSetWebMercatorQuad)
+		return TileMatrixSetWebMercatorQuad
+	}
+	if TileMatrixSetByID(layerConf.TileMatrixSet) == nil {
+		log.Warnf("Layer %s: unknown tile matrix set %s, using %s", layerConf.Name, layerConf.TileMatrixSet, TileMatrixSetWebMercatorQuad)
+		return TileMatrixSetWebMercatorQuad
+	}
+	return layerConf.TileMatrixSet
+}
+
+// loadTileMatrixSets creates the built-in tile matrix sets and the configured ones
+func loadTileMatrixSets(configs []conf.TileMatrixSet) []*TileMatrixSet {
+	sets := []*TileMatrixSet{newWebMercatorQuad(), newWorldCRS84Quad()}
+	for _, tmsConf := range configs {
+		tms, err := newConfiguredTileMatrixSet(tmsConf)
+		if err != nil {
+			log.Warnf("Ignoring tile matrix set %s: %v", tmsConf.Id, err)
+			continue
+		}
+		isDuplicate := false
+		for _, other := range sets {
+			isDuplicate = isDuplicate || other.ID == tms.ID
+		}
+		if isDuplicate {
+			log.Warnf("Ignoring tile matrix set %s: duplicate id", tms.ID)
+			continue
+		}
+		log.Infof("Added tile matrix set: %s (EPSG:%d, %d tile matrices)", tms.ID, tms.Srid, len(tms.TileMatrices))
+		sets = append(sets, tms)
+	}
+	return sets
+}
+
+// newWebMercatorQuad creates the Google Maps compatible tiling scheme
+func newWebMercatorQuad() *TileMatrixSet {
+	tms := newTileMatrixSet(TileMatrixSetWebMercatorQuad, "Google Maps Compatible for the World", SRID_3857,
+		Extent{Minx: -webMercatorExtent, Miny: -webMercatorExtent, Maxx: webMercatorExtent, Maxy: webMercatorExtent},
+		[2]float64{-webMercatorExtent, webMercatorExtent},
+		quadResolutions(2*webMercatorExtent/defaultTileSize), defaultTileSize)
+	tms.URI = "http://www.opengis.net/def/tilematrixset/OGC/1.0/WebMercatorQuad"
+	tms.OrderedAxes = []string{"E", "N"}
+	return tms
+}
+
+// newWorldCRS84Quad creates the tiling scheme for the world in longitude/latitude
+// (two tiles at zoom level 0)
+func newWorldCRS84Quad() *TileMatrixSet {
+	tms := newTileMatrixSet(TileMatrixSetWorldCRS84Quad, "CRS84 for the World", SRID_4326,
+		Extent{Minx: -180, Miny: -90, Maxx: 180, Maxy: 90},
+		[2]float64{-180, 90},
+		quadResolutions(180.0/defaultTileSize), defaultTileSize)
+	tms.URI = "http://www.opengis.net/def/tilematrixset/OGC/1.0/WorldCRS84Quad"
+	tms.Crs = "http://www.opengis.net/def/crs/OGC/1.3/CRS84"
+	tms.OrderedAxes = []string{"Lon", "Lat"}
+	return tms
+}
+
+// newConfiguredTileMatrixSet creates a tile matrix set from its configuration
+func newConfiguredTileMatrixSet(tmsConf conf.TileMatrixSet) (*TileMatrixSet, error) {
+	if tmsConf.Id == "" {
+		return nil, errors.New("missing Id")
+	}
+	if tmsConf.Srid <= 0 {
+		return nil, errors.New("missing Srid")
+	}
+	if len(tmsConf.Extent) != 4 || tmsConf.Extent[0] >= tmsConf.Extent[2] || tmsConf.Extent[1] >= tmsConf.Extent[3] {
+		return nil, errors.New("Extent must be [minx, miny, maxx, maxy]")
+	}
+	if len(tmsConf.Resolutions) == 0 {
+		return nil, errors.New("missing Resolutions")
+	}
+	extent := Extent{Minx: tmsConf.Extent[0], Miny: tmsConf.Extent[1], Maxx: tmsConf.Extent[2], Maxy: tmsConf.Extent[3]}
+	origin := [2]float64{extent.Minx, extent.Maxy}
+	if len(tmsConf.Origin) == 2 {
+		origin = [2]float64{tmsConf.Origin[0], tmsConf.Origin[1]}
+	} else if len(tmsConf.Origin) != 0 {
+		return nil, errors.New("Origin must be [x, y]")
+	}
+	tileSize := tmsConf.TileSize
+	if tileSize <= 0 {
+		tileSize = defaultTileSize
+	}
+	return newTileMatrixSet(tmsConf.Id, tmsConf.Title, tmsConf.Srid, extent, origin, tmsConf.Resolutions, tileSize), nil
+}
+
+// newTileMatrixSet creates a tile matrix set with one tile matrix per resolution,
+// covering the extent from the top-left origin
+func newTileMatrixSet(id string, title string, srid int, extent Extent, origin [2]float64, resolutions []float64, tileSize int) *TileMatrixSet {
+	tms := &TileMatrixSet{
+		ID:    id,
+		Title: title,
+		Crs:   fmt.Sprintf("http://www.opengis.net/def/crs/EPSG/0/%d", srid),
+		BoundingBox: &BoundingBox{
+			LowerLeft:  [2]float64{extent.Minx, extent.Miny},
+			UpperRight: [2]float64{extent.Maxx, extent.Maxy},
+		},
+		Srid: srid,
+	}
+	metersPerUnit := 1.0
+	if srid == SRID_4326 {
+		metersPerUnit = metersPerDegree
+	}
+	for z, cellSize := range resolutions {
+		tileSpan := cellSize * float64(tileSize)
+		tms.TileMatrices = append(tms.TileMatrices, &TileMatrix{
+			ID:               strconv.Itoa(z),
+			ScaleDenominator: cellSize * metersPerUnit / standardPixelSize,
+			CellSize:         cellSize,
+			CornerOfOrigin:   "topLeft",
+			PointOfOrigin:    origin,
+			TileWidth:        tileSize,
+			TileHeight:       tileSize,
+			// tolerate rounding errors of resolutions given with limited precision
+			MatrixWidth:  int(math.Max(1, math.Ceil((extent.Maxx-origin[0])/tileSpan-1e-6))),
+			MatrixHeight: int(math.Max(1, math.Ceil((origin[1]-extent.Miny)/tileSpan-1e-6))),
+		})
+	}
+	return tms
+}
+
+// quadResolutions returns the resolutions of a quad tree tiling scheme,
+// halving the resolution at each zoom level
+func quadResolutions(cellSize float64) []float64 {
+	resolutions := make([]float64, 0, quadMaxZoom+1)
+	for z := 0; z <= quadMaxZoom; z++ {
+		resolutions = append(resolutions, cellSize)
+		cellSize /= 2
+	}
+	return resolutions
+}
+
+// TileMatrix returns the tile matrix for a zoom level, or nil if out of range
+func (tms *TileMatrixSet) TileMatrix(z int) *TileMatrix {
+	if z < 0 || z >= len(tms.TileMatrices) {
+		return nil
+	}
+	return tms.TileMatrices[z]
+}
+
+// HasTile reports whether a tile is part of the tile matrix set
+func (tms *TileMatrixSet) HasTile(z, x, y int) bool {
+	tm := tms.TileMatrix(z)
+	return tm != nil && x >= 0 && x < tm.MatrixWidth && y >= 0 && y < tm.MatrixHeight
+}
+
+// TileBounds returns the bounds of a tile in the CRS of the tile matrix set
+func (tms *TileMatrixSet) TileBounds(z, x, y int) *Extent {
+	tm := tms.TileMatrix(z)
+	if tm == nil {
+		return nil
+	}
+	tileSpanX := tm.CellSize * float64(tm.TileWidth)
+	tileSpanY := tm.CellSize * float64(tm.TileHeight)
+	return &Extent{
+		Minx: tm.PointOfOrigin[0] + float64(x)*tileSpanX,
+		Miny: tm.PointOfOrigin[1] - float64(y+1)*tileSpanY,
+		Maxx: tm.PointOfOrigin[0] + float64(x+1)*tileSpanX,
+		Maxy: tm.PointOfOrigin[1] - float64(y)*tileSpanY,
+	}
+}
+
+// TileLimits returns the range of tiles of a tile matrix covering the given bounds
+// (in the CRS of the tile matrix set)
+func (tms *TileMatrixSet) TileLimits(z int, bounds *Extent) *TileMatrixLimits {
+	tm := tms.TileMatrix(z)
+	if tm == nil {
+		return nil
+	}
+	tileSpanX := tm.CellSize * float64(tm.TileWidth)
+	tileSpanY := tm.CellSize * float64(tm.TileHeight)
+	clamp := func(v float64, size int) int {
+		return int(math.Max(0, math.Min(float64(size-1), math.Floor(v))))
+	}
+	return &TileMatrixLimits{
+		TileMatrix: tm.ID,
+		MinTileCol: clamp((bounds.Minx-tm.PointOfOrigin[0])/tileSpanX, tm.MatrixWidth),
+		MaxTileCol: clamp((bounds.Maxx-tm.PointOfOrigin[0])/tileSpanX, tm.MatrixWidth),
+		MinTileRow: clamp((tm.PointOfOrigin[1]-bounds.Maxy)/tileSpanY, tm.MatrixHeight),
+		MaxTileRow: clamp((tm.PointOfOrigin[1]-bounds.Miny)/tileSpanY, tm.MatrixHeight),
+	}
+}
+
+// webMercatorMaxLat is the latitude of the northern edge of the Web Mercator world
+const webMercatorMaxLat = 85.0511287798066
+
+// lonLatToWebMercatorExtent projects longitude/latitude bounds to Web Mercator
+// (latitudes are clamped to the Web Mercator world)
+func lonLatToWebMercatorExtent(bounds *Extent) *Extent {
+	project := func(lon, lat float64) (float64, float64) {
+		lat = math.Max(-webMercatorMaxLat, math.Min(webMercatorMaxLat, lat))
+		x := lon * metersPerDegree
+		y := math.Log(math.Tan((90+lat)*math.Pi/360)) * webMercatorExtent / math.Pi
+		return x, y
+	}
+	minx, miny := project(bounds.Minx, bounds.Miny)
+	maxx, maxy := project(bounds.Maxx, bounds.Maxy)
+	return &Extent{Minx: minx, Miny: miny, Maxx: maxx, Maxy: maxy}
+}
+
+// webMercatorToLonLatExtent unprojects Web Mercator bounds to longitude/latitude
+func webMercatorToLonLatExtent(bounds *Extent) *Extent {
+	unproject := func(x, y float64) (float64, float64) {
+		lon := x / metersPerDegree
+		lat := math.Atan(math.Exp(y*math.Pi/webMercatorExtent))*360/math.Pi - 90
+		return lon, lat
+	}
+	minx, miny := unproject(bounds.Minx, bounds.Miny)
+	maxx, maxy := unproject(bounds.Maxx, bounds.Maxy)
+	return &Extent{Minx: minx, Miny: miny, Maxx: maxx, Maxy: maxy}
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+)
+
+func TestWebMercatorQuad(t *testing.T) {
+	tms := TileMatrixSetByID(TileMatrixSetWebMercatorQuad)
+	if tms == nil {
+		t.Fatal("WebMercatorQuad not found")
+	}
+	testEquals(t, quadMaxZoom+1, len(tms.TileMatrices), "Number of tile matrices")
+	testEquals(t, 559082264.0287, math.Round(tms.TileMatrix(0).ScaleDenominator*1e4)/1e4, "Scale denominator at zoom 0")
+	testEquals(t, 4, tms.TileMatrix(2).MatrixWidth, "Matrix width at zoom 2")
+	if tms.TileMatrix(quadMaxZoom+1) != nil {
+		t.Error("Expected no tile matrix beyond the maximum zoom")
+	}
+}
+
+func TestWorldCRS84Quad(t *testing.T) {
+	tms := TileMatrixSetByID(TileMatrixSetWorldCRS84Quad)
+	if tms == nil {
+		t.Fatal("WorldCRS84Quad not found")
+	}
+	testEquals(t, SRID_4326, tms.Srid, "SRID")
+	testEquals(t, 279541132.0144, math.Round(tms.TileMatrix(0).ScaleDenominator*1e4)/1e4, "Scale denominator at zoom 0")
+	testEquals(t, 2, tms.TileMatrix(0).MatrixWidth, "Matrix width at zoom 0")
+	testEquals(t, 1, tms.TileMatrix(0).MatrixHeight, "Matrix height at zoom 0")
+	testEquals(t, Extent{Minx: 0, Miny: -90, Maxx: 180, Maxy: 90}, *tms.TileBounds(0, 1, 0), "Tile bounds")
+	testEquals(t, true, tms.HasTile(1, 3, 1), "Tile in matrix")
+	testEquals(t, false, tms.HasTile(1, 1, 2), "Tile outside matrix")
+}
+
+func TestConfiguredTileMatrixSet(t *testing.T) {
+	// Swiss LV95 grid (EPSG:2056) with a subset of the swisstopo resolutions
+	tms, err := newConfiguredTileMatrixSet(conf.TileMatrixSet{
+		Id:          "LV95",
+		Srid:        2056,
+		Extent:      []float64{2420000, 1030000, 2900000, 1350000},
+		Resolutions: []float64{4000, 250, 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, "http://www.opengis.net/def/crs/EPSG/0/2056", tms.Crs, "CRS")
+	testEquals(t, [2]float64{2420000, 1350000}, tms.TileMatrix(0).PointOfOrigin, "Default origin")
+	testEquals(t, 1, tms.TileMatrix(0).MatrixWidth, "Matrix width at zoom 0")
+	testEquals(t, 8, tms.TileMatrix(1).MatrixWidth, "Matrix width at zoom 1")
+	testEquals(t, 5, tms.TileMatrix(1).MatrixHeight, "Matrix height at zoom 1")
+	testEquals(t, Extent{Minx: 2420000, Miny: 1347440, Maxx: 2422560, Maxy: 1350000}, *tms.TileBounds(2, 0, 0), "Tile bounds")
+
+	invalid := []conf.TileMatrixSet{
+		{Srid: 2056, Extent: []float64{0, 0, 1, 1}, Resolutions: []float64{1}},
+		{Id: "NoSrid", Extent: []float64{0, 0, 1, 1}, Resolutions: []float64{1}},
+		{Id: "BadExtent", Srid: 2056, Extent: []float64{1, 1, 0, 0}, Resolutions: []float64{1}},
+		{Id: "NoResolutions", Srid: 2056, Extent: []float64{0, 0, 1, 1}},
+		{Id: "BadOrigin", Srid: 2056, Extent: []float64{0, 0, 1, 1}, Origin: []float64{0}, Resolutions: []float64{1}},
+	}
+	for _, tmsConf := range invalid {
+		if _, err := newConfiguredTileMatrixSet(tmsConf); err == nil {
+			t.Errorf("Expected error for tile matrix set %+v", tmsConf)
+		}
+	}
+
+	sets := loadTileMatrixSets([]conf.TileMatrixSet{
+		{Id: TileMatrixSetWebMercatorQuad, Srid: 3857, Extent: []float64{0, 0, 1, 1}, Resolutions: []float64{1}},
+		{Id: "LV95", Srid: 2056, Extent: []float64{2420000, 1030000, 2900000, 1350000}, Resolutions: []float64{4000}},
+	})
+	testEquals(t, 3, len(sets), "Duplicate ids are ignored")
+	testEquals(t, "LV95", sets[2].ID, "Configured tile matrix set")
+}
+
+func TestLayerTileMatrixSet(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+	conf.Configuration.Layers = []conf.Layer{
+		{Name: "roads", TileMatrixSet: TileMatrixSetWorldCRS84Quad},
+		{Name: "water", TileMatrixSet: TileMatrixSetWorldCRS84Quad},
+		{Name: "rivers", TileMatrixSet: "Unknown"},
+	}
+
+	tms, err := LayerTileMatrixSet([]string{"buildings"})
+	testEquals(t, nil, err, "Default error")
+	testEquals(t, TileMatrixSetWebMercatorQuad, tms.ID, "Default tile matrix set")
+
+	tms, err = LayerTileMatrixSet([]string{"roads", "water"})
+	testEquals(t, nil, err, "Configured error")
+	testEquals(t, TileMatrixSetWorldCRS84Quad, tms.ID, "Configured tile matrix set")
+
+	tms, _ = LayerTileMatrixSet([]string{"rivers"})
+	testEquals(t, TileMatrixSetWebMercatorQuad, tms.ID, "Unknown tile matrix set")
+
+	_, err = LayerTileMatrixSet([]string{"roads", "buildings"})
+	if !errors.Is(err, ErrTileMatrixSetMismatch) {
+		t.Errorf("Expected tile matrix set mismatch, got %v", err)
+	}
+}
+
+func TestTileLimits(t *testing.T) {
+	tms := TileMatrixSetByID(TileMatrixSetWebMercatorQuad)
+
+	// the north-east quadrant of the world
+	limits := tms.TileLimits(1, &Extent{Minx: 1, Miny: 1, Maxx: webMercatorExtent, Maxy: webMercatorExtent})
+	testEquals(t, TileMatrixLimits{TileMatrix: "1", MinTileRow: 0, MaxTileRow: 0, MinTileCol: 1, MaxTileCol: 1}, *limits, "Zoom 1 limits")
+
+	// bounds outside of the tile matrix are clamped
+	limits = tms.TileLimits(2, &Extent{Minx: -3e7, Miny: -3e7, Maxx: 3e7, Maxy: 3e7})
+	testEquals(t, TileMatrixLimits{TileMatrix: "2", MinTileRow: 0, MaxTileRow: 3, MinTileCol: 0, MaxTileCol: 3}, *limits, "Clamped limits")
+}
+
+func TestWebMercatorExtent(t *testing.T) {
+	round := func(e *Extent) Extent {
+		r := func(v float64) float64 { return math.Round(v*1e4) / 1e4 }
+		return Extent{Minx: r(e.Minx), Miny: r(e.Miny), Maxx: r(e.Maxx), Maxy: r(e.Maxy)}
+	}
+
+	world := lonLatToWebMercatorExtent(&Extent{Minx: -180, Miny: -90, Maxx: 180, Maxy: 90})
+	testEquals(t, round(&Extent{Minx: -webMercatorExtent, Miny: -webMercatorExtent, Maxx: webMercatorExtent, Maxy: webMercatorExtent}),
+		round(world), "World extent (latitudes clamped)")
+
+	bounds := &Extent{Minx: 5.9, Miny: 45.8, Maxx: 10.5, Maxy: 47.8}
+	testEquals(t, *bounds, round(webMercatorToLonLatExtent(lonLatToWebMercatorExtent(bounds))), "Round trip")
+}
@@ -3,54 +3,140 @@ package data
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"math"
+	"path"
+	"sort"
+	"strings"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/archive"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
 )
 
 const (
 	SRID_3857 = 3857 // Web Mercator
+
+	DefaultMinZoom = 0
+	DefaultMaxZoom = 22
+
+	DefaultTileExtent = 4096 // Default extent of the tile geometries (ST_AsMVTGeom default)
+	DefaultTileBuffer = 256  // Default buffer around the tile in MVT units (ST_AsMVTGeom default)
 )
 
+// LayerNameSeparator separates the layer names of a composite tile request
+// e.g. /tiles/roads,water,buildings/{z}/{x}/{y}.mvt
+const LayerNameSeparator = ","
+
+// ErrLayerNotFound is returned when a requested layer does not exist
+var ErrLayerNotFound = errors.New("layer not found")
+
 // Layer represents a spatial layer that can serve MVT tiles
 type Layer struct {
 	Name           string            `json:"name"`
-	Table          string            `json:"table"`
+	Title          string            `json:"title,omitempty"`
+	Description    string            `json:"description,omitempty"`
+	Attribution    string            `json:"attribution,omitempty"`
+	Table          string            `json:"table,omitempty"`
 	GeometryColumn string            `json:"geometry_column"`
+	IDColumn       string            `json:"id_column,omitempty"`
 	GeometryType   string            `json:"geometry_type"`
 	Srid           int               `json:"srid"` // SRID of bounds (always 3857 for API responses)
 	SourceSrid     int               `json:"-"`    // SRID of source data (not exposed in API)
 	Bounds         *Extent           `json:"bounds,omitempty"`
 	Properties     []string          `json:"properties,omitempty"`
 	PropertyTypes  map[string]string `json:"-"` // Column name -> data type mapping (not exposed in API)
+	MinZoom        int               `json:"minzoom"`
+	MaxZoom        int               `json:"maxzoom"`
+	DataMaxZoom    int               `json:"-"` // Zoom level above which tiles are cut from parent tiles (not exposed in API)
+	SimplifyFactor float64           `json:"-"` // Simplification tolerance in pixels (not exposed in API)
+	ClusterMaxZoom int               `json:"-"` // Zoom level up to which points are clustered (not exposed in API)
+	ClusterSize    int               `json:"-"` // Size of the cluster grid cells in pixels (not exposed in API)
+	ClusterFields  []string          `json:"-"` // Aggregates added as cluster properties (not exposed in API)
+	MaxFeatures    int               `json:"-"` // Maximum number of features in a tile (not exposed in API)
+	MaxTileSizeKB  int               `json:"-"` // Maximum size of a tile in KB (not exposed in API)
+	Priority       string            `json:"-"` // Order in which features are kept in thinned tiles (not exposed in API)
+	Extent         int               `json:"-"` // Extent of the tile geometries in MVT units (not exposed in API)
+	Buffer         int               `json:"-"` // Buffer around the tile in MVT units (not exposed in API)
+	Clip           bool              `json:"-"` // Clip geometries to the tile extent and buffer (not exposed in API)
+	TileMatrixSet  string            `json:"tile_matrix_set"`
+	Filter         string            `json:"-"` // SQL condition restricting the features (not exposed in API)
+	Sql            string            `json:"-"` // SELECT statement of a query-backed layer (not exposed in API)
+	Source         string            `json:"-"` // Table function reading a file-backed layer (not exposed in API)
+	Parameters     []LayerParameter  `json:"parameters,omitempty"`
+
+	archive archive.Reader // Tile archive serving the tiles of an archive layer
+}
+
+// layerSourceAlias is the alias of the subquery or table function providing the data of a layer
+const layerSourceAlias = "layer_source"
+
+// source returns the FROM item providing the layer data:
+// the table name, the layer query wrapped as a subquery,
+// or the table function reading the layer files
+func (layer *Layer) source() string {
+	if layer.Sql != "" {
+		return fmt.Sprintf("(%s) AS %s", layer.Sql, layerSourceAlias)
+	}
+	if layer.Source != "" {
+		return fmt.Sprintf("%s AS %s", layer.Source, layerSourceAlias)
+	}
+	return layer.Table
+}
+
+// fileSourceFunction returns the table function reading the files of a layer source.
+// A table function (e.g. "read_parquet('s3://bucket/*.parquet')") is used as is.
+// A plain file path or glob is read with read_parquet for (Geo)Parquet files,
+// and with ST_Read for all other formats (e.g. GeoPackage, Shapefile, GeoJSON).
+func fileSourceFunction(source string) string {
+	source = strings.TrimSpace(source)
+	if strings.Contains(source, "(") {
+		return source
+	}
+	quoted := strings.ReplaceAll(source, "'", "''")
+	switch strings.ToLower(path.Ext(source)) {
+	case ".parquet", ".geoparquet":
+		return fmt.Sprintf("read_parquet('%s')", quoted)
+	default:
+		return fmt.Sprintf("ST_Read('%s')", quoted)
+	}
 }
 
 // TileJSON represents the TileJSON specification metadata
 type TileJSON struct {
-	TileJSON     string        `json:"tilejson"`
-	Name         string        `json:"name,omitempty"`
-	Description  string        `json:"description,omitempty"`
-	Version      string        `json:"version,omitempty"`
-	Scheme       string        `json:"scheme,omitempty"`
-	Tiles        []string      `json:"tiles"`
-	MinZoom      int           `json:"minzoom,omitempty"`
-	MaxZoom      int           `json:"maxzoom,omitempty"`
-	Bounds       []float64     `json:"bounds,omitempty"`
-	Center       []float64     `json:"center,omitempty"`
-	VectorLayers []VectorLayer `json:"vector_layers,omitempty"`
+	TileJSON    string   `json:"tilejson"`
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Attribution string   `json:"attribution,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	Scheme      string   `json:"scheme,omitempty"`
+	Tiles       []string `json:"tiles"`
+	MinZoom     int      `json:"minzoom,omitempty"`
+	MaxZoom     int      `json:"maxzoom,omitempty"`
+	// TileMatrixSet is the id of the tiling scheme of the tiles (see /tileMatrixSets)
+	TileMatrixSet string        `json:"tile_matrix_set,omitempty"`
+	Bounds        []float64     `json:"bounds,omitempty"`
+	Center        []float64     `json:"center,omitempty"`
+	VectorLayers  []VectorLayer `json:"vector_layers,omitempty"`
 }
 
 // VectorLayer represents a layer in the TileJSON spec
+// Fields map the property names to their type (Number, String or Boolean),
+// followed by the column comment if there is one.
 type VectorLayer struct {
 	ID          string            `json:"id"`
 	Description string            `json:"description,omitempty"`
 	MinZoom     int               `json:"minzoom,omitempty"`
 	MaxZoom     int               `json:"maxzoom,omitempty"`
 	Fields      map[string]string `json:"fields,omitempty"`
+	// GeometryType is the MVT geometry type of the layer features (Point, LineString or Polygon)
+	GeometryType string `json:"geometry_type,omitempty"`
 }
 
-// GetLayers returns all tables with geometry columns
+// GetLayers returns all tables with geometry columns,
+// followed by the query-backed, file-backed and archive layers defined in the configuration
 func (cat *CatalogDB) GetLayers() ([]*Layer, error) {
 	query := `
 		SELECT
@@ -89,6 +175,15 @@ func (cat *CatalogDB) GetLayers() ([]*Layer, error) {
 			continue
 		}
 
+		// A query-backed or file-backed layer with the same name takes precedence
+		if isSourceLayer(tableName) {
+			continue
+		}
+		if err := checkParameterRefs(conf.Configuration.LayerConfig(tableName)); err != nil {
+			log.Warnf("Error loading layer %s: %v", tableName, err)
+			continue
+		}
+
 		layer := &Layer{
 			Name:           tableName,
 			Table:          tableName,
@@ -100,6 +195,8 @@ func (cat *CatalogDB) GetLayers() ([]*Layer, error) {
 			log.Warnf("Error enriching layer %s metadata: %v", tableName, err)
 			// Continue anyway with basic info
 		}
+		layer.IDColumn = cat.queryPrimaryKey(layer)
+		applyLayerConfig(layer)
 
 		layers = append(layers, layer)
 	}
@@ -108,6 +205,31 @@ func (cat *CatalogDB) GetLayers() ([]*Layer, error) {
 		return nil, fmt.Errorf("error iterating layers: %w", err)
 	}
 
+	// Add query-backed, file-backed and archive layers
+	for i := range conf.Configuration.Layers {
+		layerConf := &conf.Configuration.Layers[i]
+		if !hasLayerSource(layerConf) {
+			continue
+		}
+		if err := checkParameterRefs(layerConf); err != nil {
+			log.Warnf("Error loading layer %s: %v", layerConf.Name, err)
+			continue
+		}
+		layer, err := cat.newSourceLayer(layerConf)
+		if err != nil {
+			log.Warnf("Error loading layer %s: %v", layerConf.Name, err)
+			continue
+		}
+		if layer.archive == nil {
+			if err := cat.enrichLayerMetadata(layer); err != nil {
+				log.Warnf("Error enriching layer %s metadata: %v", layer.Name, err)
+			}
+		}
+		applyLayerConfig(layer)
+
+		layers = append(layers, layer)
+	}
+
 	log.Infof("Found %d layers with geometry columns", len(layers))
 	return layers, nil
 }
@@ -120,10 +242,10 @@ func (cat *CatalogDB) enrichLayerMetadataLightweight(layer *Layer) error {
 		FROM %s
 		WHERE %s IS NOT NULL
 		LIMIT 1
-	`, layer.GeometryColumn, layer.Table, layer.GeometryColumn)
+	`, layer.GeometryColumn, layer.source(), layer.GeometryColumn)
 
 	var geomType sql.NullString
-	err := cat.dbconn.QueryRow(query).Scan(&geomType)
+	err := cat.dbconn.QueryRow(query, layer.defaultArgs()...).Scan(&geomType)
 	if err != nil && err != sql.ErrNoRows {
 		return fmt.Errorf("error getting geometry metadata: %w", err)
 	}
@@ -133,28 +255,12 @@ func (cat *CatalogDB) enrichLayerMetadataLightweight(layer *Layer) error {
 	}
 
 	// Get property columns (non-geometry columns)
-	propsQuery := fmt.Sprintf(`
-		SELECT column_name
-		FROM duckdb_columns
-		WHERE table_name = '%s' AND data_type != 'GEOMETRY'
-		ORDER BY column_name
-	`, layer.Table)
-
-	rows, err := cat.dbconn.Query(propsQuery)
+	properties, propertyTypes, err := cat.queryLayerProperties(layer)
 	if err != nil {
-		return fmt.Errorf("error getting properties: %w", err)
-	}
-	defer rows.Close()
-
-	var properties []string
-	for rows.Next() {
-		var col string
-		if err := rows.Scan(&col); err != nil {
-			continue
-		}
-		properties = append(properties, col)
+		return err
 	}
 	layer.Properties = properties
+	layer.PropertyTypes = propertyTypes
 
 	return nil
 }
@@ -168,10 +274,10 @@ func (cat *CatalogDB) enrichLayerMetadata(layer *Layer) error {
 		FROM %s
 		WHERE %s IS NOT NULL
 		LIMIT 1
-	`, layer.GeometryColumn, layer.Table, layer.GeometryColumn)
+	`, layer.GeometryColumn, layer.source(), layer.GeometryColumn)
 
 	var geomType sql.NullString
-	err := cat.dbconn.QueryRow(query).Scan(&geomType)
+	err := cat.dbconn.QueryRow(query, layer.defaultArgs()...).Scan(&geomType)
 	if err != nil && err != sql.ErrNoRows {
 		return fmt.Errorf("error getting geometry metadata: %w", err)
 	}
@@ -181,8 +287,16 @@ func (cat *CatalogDB) enrichLayerMetadata(layer *Layer) error {
 	}
 
 	// Get native bounds - calculate extent ONCE to avoid expensive double table scan
-	// DuckDB Spatial doesn't store SRID per-geometry, so we detect it from coordinate ranges
+	// DuckDB Spatial doesn't store SRID per-geometry, so unless it is configured
+	// we detect it from coordinate ranges
 	// Use ST_Extent_Agg to aggregate all geometries into a single bounding box
+	sourceSrid := configuredSrid(layer.Name)
+	transformSrid := SRID_4326
+	if sourceSrid > 0 {
+		transformSrid = sourceSrid
+	}
+	// the transformed bounds use the x, y (easting, northing) axis order, like the tiles
+	extent3857 := transformToOutCrs("extent", transformSrid, SRID_3857)
 	nativeBoundsQuery := fmt.Sprintf(`
 		WITH extent_calc AS (
 			SELECT ST_Extent_Agg(%s) as extent
@@ -195,18 +309,16 @@ func (cat *CatalogDB) enrichLayerMetadata(layer *Layer) error {
 			ST_XMax(extent) as maxx,
 			ST_YMax(extent) as maxy,
 			-- Also get transformed bounds in one query to avoid double table scan
-			-- Note: ST_Transform swaps X and Y! So ST_XMin gives us the minimum Y value (latitude)
-			-- We swap them back so our API returns Minx=longitude, Miny=latitude
-			ST_YMin(ST_Transform(extent, 'EPSG:4326', 'EPSG:3857')) as minx_3857,
-			ST_XMin(ST_Transform(extent, 'EPSG:4326', 'EPSG:3857')) as miny_3857,
-			ST_YMax(ST_Transform(extent, 'EPSG:4326', 'EPSG:3857')) as maxx_3857,
-			ST_XMax(ST_Transform(extent, 'EPSG:4326', 'EPSG:3857')) as maxy_3857
+			ST_XMin(%[4]s) as minx_3857,
+			ST_YMin(%[4]s) as miny_3857,
+			ST_XMax(%[4]s) as maxx_3857,
+			ST_YMax(%[4]s) as maxy_3857
 		FROM extent_calc
-	`, layer.GeometryColumn, layer.Table, layer.GeometryColumn)
+	`, layer.GeometryColumn, layer.source(), layer.GeometryColumn, extent3857)
 
 	var nativeMinx, nativeMiny, nativeMaxx, nativeMaxy sql.NullFloat64
 	var minx3857, miny3857, maxx3857, maxy3857 sql.NullFloat64
-	err = cat.dbconn.QueryRow(nativeBoundsQuery).Scan(
+	err = cat.dbconn.QueryRow(nativeBoundsQuery, layer.defaultArgs()...).Scan(
 		&nativeMinx, &nativeMiny, &nativeMaxx, &nativeMaxy,
 		&minx3857, &miny3857, &maxx3857, &maxy3857)
 	if err != nil && err != sql.ErrNoRows {
@@ -217,26 +329,22 @@ func (cat *CatalogDB) enrichLayerMetadata(layer *Layer) error {
 	// Detect coordinate system from bounds
 	// EPSG:3857 (Web Mercator) has values roughly in range [-20037508, 20037508]
 	// EPSG:4326 (WGS84) has values in range [-180, 180] for lon, [-90, 90] for lat
-	sourceSrid := SRID_4326 // Default assumption
-	if nativeMinx.Valid && nativeMaxx.Valid {
-		maxAbsX := math.Max(math.Abs(nativeMinx.Float64), math.Abs(nativeMaxx.Float64))
-		if maxAbsX > 360 {
-			// Likely already in Web Mercator (EPSG:3857)
-			sourceSrid = SRID_3857
+	if sourceSrid <= 0 {
+		sourceSrid = SRID_4326 // Default assumption
+		if nativeMinx.Valid && nativeMaxx.Valid {
+			maxAbsX := math.Max(math.Abs(nativeMinx.Float64), math.Abs(nativeMaxx.Float64))
+			if maxAbsX > 360 {
+				// Likely already in Web Mercator (EPSG:3857)
+				sourceSrid = SRID_3857
+			}
 		}
 	}
 
 	// Use appropriate bounds for EPSG:3857 output
 	// If data is already in 3857, use native bounds; otherwise use transformed bounds
-	var minx, miny, maxx, maxy sql.NullFloat64
+	minx, miny, maxx, maxy := minx3857, miny3857, maxx3857, maxy3857
 	if sourceSrid == SRID_3857 {
-		// Already in Web Mercator - DuckDB ST_Extent returns swapped coordinates for EPSG:3857
-		// ST_XMin/ST_YMin are swapped, so we swap them to get correct longitude/latitude order
-		minx, miny, maxx, maxy = nativeMiny, nativeMinx, nativeMaxy, nativeMaxx
-	} else {
-		// Data is in EPSG:4326, use transformed bounds
-		// The SQL query swapped them (lines 199-202), so we need to swap back
-		minx, miny, maxx, maxy = miny3857, minx3857, maxy3857, maxx3857
+		minx, miny, maxx, maxy = nativeMinx, nativeMiny, nativeMaxx, nativeMaxy
 	}
 
 	if minx.Valid && miny.Valid && maxx.Valid && maxy.Valid {
@@ -252,8 +360,42 @@ func (cat *CatalogDB) enrichLayerMetadata(layer *Layer) error {
 	}
 
 	// Get property columns (non-geometry columns)
+	properties, propertyTypes, err := cat.queryLayerProperties(layer)
+	if err != nil {
+		return err
+	}
+	layer.Properties = properties
+	layer.PropertyTypes = propertyTypes
+
+	return nil
+}
+
+// queryLayerProperties returns the property (non-geometry) columns of a layer
+// and their data types, ordered by column name.
+// Table columns are read from duckdb_columns,
+// the columns of query-backed and file-backed layers are determined by describing their source.
+func (cat *CatalogDB) queryLayerProperties(layer *Layer) ([]string, map[string]string, error) {
+	var properties []string
+	propertyTypes := make(map[string]string)
+
+	if layer.Table == "" {
+		columns, columnTypes, err := cat.describeLayerSource(layer)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, col := range columns {
+			if col == layer.GeometryColumn || columnTypes[col] == DuckDBTypeGeometry {
+				continue
+			}
+			properties = append(properties, col)
+			propertyTypes[col] = columnTypes[col]
+		}
+		sort.Strings(properties)
+		return properties, propertyTypes, nil
+	}
+
 	propsQuery := fmt.Sprintf(`
-		SELECT column_name
+		SELECT column_name, data_type
 		FROM duckdb_columns
 		WHERE table_name = '%s' AND data_type != 'GEOMETRY'
 		ORDER BY column_name
@@ -261,21 +403,106 @@ func (cat *CatalogDB) enrichLayerMetadata(layer *Layer) error {
 
 	rows, err := cat.dbconn.Query(propsQuery)
 	if err != nil {
-		return fmt.Errorf("error getting properties: %w", err)
+		return nil, nil, fmt.Errorf("error getting properties: %w", err)
 	}
 	defer rows.Close()
 
-	var properties []string
 	for rows.Next() {
-		var col string
-		if err := rows.Scan(&col); err != nil {
+		var col, dataType string
+		if err := rows.Scan(&col, &dataType); err != nil {
 			continue
 		}
 		properties = append(properties, col)
+		propertyTypes[col] = dataType
 	}
-	layer.Properties = properties
+	return properties, propertyTypes, nil
+}
 
-	return nil
+// describeLayerSource returns the columns (in query order) and column types
+// of the data source of a layer
+func (cat *CatalogDB) describeLayerSource(layer *Layer) ([]string, map[string]string, error) {
+	query := fmt.Sprintf("DESCRIBE SELECT * FROM %s", layer.source())
+	rows, err := cat.dbconn.Query(query, layer.defaultArgs()...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error describing layer %s: %w", layer.Name, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	columnTypes := make(map[string]string)
+	for rows.Next() {
+		var col, dataType string
+		var null, key, dflt, extra sql.NullString
+		if err := rows.Scan(&col, &dataType, &null, &key, &dflt, &extra); err != nil {
+			return nil, nil, fmt.Errorf("error describing layer %s: %w", layer.Name, err)
+		}
+		columns = append(columns, col)
+		columnTypes[col] = dataType
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error describing layer %s: %w", layer.Name, err)
+	}
+	return columns, columnTypes, nil
+}
+
+// isSourceLayer tests whether a layer is defined by a query, files or an archive in the configuration
+func isSourceLayer(name string) bool {
+	layerConf := conf.Configuration.LayerConfig(name)
+	return layerConf != nil && hasLayerSource(layerConf)
+}
+
+// hasLayerSource tests whether a layer configuration defines the layer data source
+func hasLayerSource(layerConf *conf.Layer) bool {
+	return layerConf.Sql != "" || layerConf.Source != "" || layerConf.Archive != ""
+}
+
+// newSourceLayer creates a layer backed by the SELECT statement or the files of its configuration.
+// The geometry column is the configured one, or else the first geometry column of the source.
+// Geometries stored as WKB blobs (e.g. in plain Parquet files) are converted to GEOMETRY.
+func (cat *CatalogDB) newSourceLayer(layerConf *conf.Layer) (*Layer, error) {
+	if layerConf.Archive != "" {
+		if layerConf.Sql != "" || layerConf.Source != "" {
+			log.Warnf("Layer %s defines an Archive as well as Sql or Source, using Archive", layerConf.Name)
+		}
+		return cat.newArchiveLayer(layerConf)
+	}
+
+	layer := &Layer{
+		Name:           layerConf.Name,
+		GeometryColumn: layerConf.GeometryColumn,
+		Parameters:     layerParameters(layerConf),
+	}
+	if layerConf.Sql != "" {
+		if layerConf.Source != "" {
+			log.Warnf("Layer %s defines both Sql and Source, using Sql", layer.Name)
+		}
+		layer.Sql = strings.TrimRight(strings.TrimSpace(layerConf.Sql), ";")
+	} else {
+		layer.Source = fileSourceFunction(layerConf.Source)
+	}
+
+	columns, columnTypes, err := cat.describeLayerSource(layer)
+	if err != nil {
+		return nil, err
+	}
+	if layer.GeometryColumn == "" {
+		for _, col := range columns {
+			if columnTypes[col] == DuckDBTypeGeometry {
+				layer.GeometryColumn = col
+				break
+			}
+		}
+	}
+	geomType, ok := columnTypes[layer.GeometryColumn]
+	if !ok || layer.GeometryColumn == "" {
+		return nil, fmt.Errorf("no geometry column found for layer %s", layer.Name)
+	}
+	if geomType == "BLOB" && layer.Source != "" {
+		layer.Sql = fmt.Sprintf("SELECT * REPLACE (ST_GeomFromWKB(%s) AS %s) FROM %s",
+			layer.GeometryColumn, layer.GeometryColumn, layer.Source)
+		layer.Source = ""
+	}
+	return layer, nil
 }
 
 // isTableIncluded checks if a table should be included based on include/exclude lists
@@ -297,6 +524,35 @@ func (cat *CatalogDB) isTableIncluded(tableName string) bool {
 	return true
 }
 
+// configuredSrid returns the SRID configured for the geometries of a layer or table,
+// or 0 if it is not configured
+func configuredSrid(name string) int {
+	if layerConf := conf.Configuration.LayerConfig(name); layerConf != nil && layerConf.Srid > 0 {
+		return layerConf.Srid
+	}
+	return 0
+}
+
+// detectSourceSrid detects the SRID of the geometries in a data source.
+// DuckDB Spatial doesn't store SRID per-geometry, so one geometry is sampled
+// and the SRID is derived from its coordinate range (defaults to 4326).
+// It is only a guess, used if the SRID is not configured for the layer.
+func detectSourceSrid(db *sql.DB, geomColumn string, from string, args ...interface{}) int {
+	sridQuery := fmt.Sprintf(`
+		SELECT ST_X(ST_Centroid(%s)) as x
+		FROM %s
+		WHERE %s IS NOT NULL
+		LIMIT 1
+	`, geomColumn, from, geomColumn)
+
+	var sampleX sql.NullFloat64
+	err := db.QueryRow(sridQuery, args...).Scan(&sampleX)
+	if err == nil && sampleX.Valid && math.Abs(sampleX.Float64) > 360 {
+		return SRID_3857
+	}
+	return SRID_4326
+}
+
 // GetLayerByName returns a single layer by name with lightweight metadata for tile generation
 // Uses an in-memory cache to avoid repeated metadata queries
 func (cat *CatalogDB) GetLayerByName(name string) (*Layer, error) {
@@ -330,109 +586,247 @@ func (cat *CatalogDB) GetLayerByName(name string) (*Layer, error) {
 
 // queryLayerMetadata queries the database for layer metadata (not cached)
 func (cat *CatalogDB) queryLayerMetadata(name string) (*Layer, error) {
-	// Query for just this specific table's geometry column
-	query := `
-		SELECT column_name as geometry_column
-		FROM duckdb_columns
-		WHERE table_name = $1 AND data_type = 'GEOMETRY'
-		LIMIT 1
-	`
+	if err := checkParameterRefs(conf.Configuration.LayerConfig(name)); err != nil {
+		return nil, err
+	}
 
-	var geomColumn string
-	err := cat.dbconn.QueryRow(query, name).Scan(&geomColumn)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("layer not found: %s", name)
+	var layer *Layer
+	if layerConf := conf.Configuration.LayerConfig(name); layerConf != nil && hasLayerSource(layerConf) {
+		// Query-backed or file-backed layer defined in configuration
+		sourceLayer, err := cat.newSourceLayer(layerConf)
+		if err != nil {
+			return nil, fmt.Errorf("error querying layer %s: %w", name, err)
+		}
+		layer = sourceLayer
+	} else {
+		// Query for just this specific table's geometry column
+		query := `
+			SELECT column_name as geometry_column
+			FROM duckdb_columns
+			WHERE table_name = $1 AND data_type = 'GEOMETRY'
+			LIMIT 1
+		`
+
+		var geomColumn string
+		err := cat.dbconn.QueryRow(query, name).Scan(&geomColumn)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("%w: %s", ErrLayerNotFound, name)
+			}
+			return nil, fmt.Errorf("error querying layer %s: %w", name, err)
 		}
-		return nil, fmt.Errorf("error querying layer %s: %w", name, err)
-	}
 
-	// Check if table is included
-	if !cat.isTableIncluded(name) {
-		return nil, fmt.Errorf("layer not included: %s", name)
-	}
+		// Check if table is included
+		if !cat.isTableIncluded(name) {
+			return nil, fmt.Errorf("layer not included: %s", name)
+		}
 
-	layer := &Layer{
-		Name:           name,
-		Table:          name,
-		GeometryColumn: geomColumn,
+		layer = &Layer{
+			Name:           name,
+			Table:          name,
+			GeometryColumn: geomColumn,
+		}
 	}
 
-	// Detect source SRID without calculating full bounds (lightweight check)
-	// Sample one geometry to check coordinate range
-	sridQuery := fmt.Sprintf(`
-		SELECT ST_X(ST_Centroid(%s)) as x
-		FROM %s
-		WHERE %s IS NOT NULL
-		LIMIT 1
-	`, geomColumn, name, geomColumn)
+	// The tiles of archive layers are not generated
+	if layer.archive != nil {
+		applyLayerConfig(layer)
+		return layer, nil
+	}
 
-	var sampleX sql.NullFloat64
-	err = cat.dbconn.QueryRow(sridQuery).Scan(&sampleX)
-	if err == nil && sampleX.Valid {
-		// Detect based on coordinate range
-		if math.Abs(sampleX.Float64) > 360 {
-			layer.SourceSrid = SRID_3857
-		} else {
-			layer.SourceSrid = SRID_4326
-		}
-	} else {
-		// Default to 4326 if we can't detect
-		layer.SourceSrid = SRID_4326
+	// Use the configured source SRID, or detect it without calculating full bounds (lightweight check)
+	layer.SourceSrid = configuredSrid(layer.Name)
+	if layer.SourceSrid <= 0 {
+		layer.SourceSrid = detectSourceSrid(cat.dbconn, layer.GeometryColumn, layer.source(), layer.defaultArgs()...)
 	}
 
-	// Get property columns (non-geometry columns) for MVT generation
+	// Get the geometry type (points are clustered but not simplified)
+	// and the property columns (non-geometry columns) for MVT generation
 	// This is lightweight and necessary to include properties in tiles
 	// We also need data types to handle casting of unsupported types
-	propsQuery := fmt.Sprintf(`
-		SELECT column_name, data_type
-		FROM duckdb_columns
-		WHERE table_name = '%s' AND data_type != 'GEOMETRY'
-		ORDER BY column_name
-	`, name)
+	if err := cat.enrichLayerMetadataLightweight(layer); err != nil {
+		return nil, err
+	}
+	layer.IDColumn = cat.queryPrimaryKey(layer)
+	applyLayerConfig(layer)
 
-	rows, err := cat.dbconn.Query(propsQuery)
-	if err != nil {
-		return nil, fmt.Errorf("error getting properties: %w", err)
+	return layer, nil
+}
+
+// applyLayerConfig applies the [[Layers]] configuration section (if any) to a layer.
+// It sets the zoom range, display metadata and feature filter,
+// and restricts the layer properties to the configured allow-list.
+func applyLayerConfig(layer *Layer) {
+	layer.MinZoom = DefaultMinZoom
+	layer.MaxZoom = DefaultMaxZoom
+	layer.Extent = DefaultTileExtent
+	layer.Buffer = DefaultTileBuffer
+	layer.Clip = true
+	if layer.archive != nil {
+		// archive layers default to the zoom range of the archive
+		meta := layer.archive.Metadata()
+		layer.MinZoom, layer.MaxZoom = meta.MinZoom, meta.MaxZoom
 	}
-	defer rows.Close()
 
-	var properties []string
-	propertyTypes := make(map[string]string)
-	for rows.Next() {
-		var col, dataType string
-		if err := rows.Scan(&col, &dataType); err != nil {
-			continue
+	layerConf := conf.Configuration.LayerConfig(layer.Name)
+	layer.TileMatrixSet = layerTileMatrixSetID(layerConf)
+	if layerConf == nil {
+		return
+	}
+
+	layer.Title = layerConf.Title
+	if layerConf.Description != "" {
+		layer.Description = layerConf.Description
+	}
+	layer.Attribution = layerConf.Attribution
+	layer.Filter = layerConf.Filter
+	if layerConf.IdColumn != "" {
+		layer.setIDColumn(layerConf.IdColumn)
+	}
+	layer.Parameters = layerParameters(layerConf)
+	if layerConf.MinZoom > 0 {
+		layer.MinZoom = layerConf.MinZoom
+	}
+	if layerConf.MaxZoom > 0 {
+		layer.MaxZoom = layerConf.MaxZoom
+	}
+	layer.DataMaxZoom = layerConf.DataMaxZoom
+	layer.SimplifyFactor = layerConf.SimplifyFactor
+	layer.ClusterMaxZoom = layerConf.ClusterMaxZoom
+	layer.ClusterSize = layerConf.ClusterSize
+	layer.ClusterFields = layerConf.ClusterFields
+	layer.MaxFeatures = layerConf.MaxFeatures
+	layer.MaxTileSizeKB = layerConf.MaxTileSizeKB
+	layer.Priority = layerConf.Priority
+	if layerConf.Extent > 0 {
+		layer.Extent = layerConf.Extent
+	}
+	if layerConf.Buffer != nil {
+		layer.Buffer = *layerConf.Buffer
+	}
+	if layerConf.Clip != nil {
+		layer.Clip = *layerConf.Clip
+	}
+
+	if len(layerConf.Properties) > 0 {
+		allowed := make(map[string]bool)
+		for _, prop := range layerConf.Properties {
+			allowed[prop] = true
 		}
-		properties = append(properties, col)
-		propertyTypes[col] = dataType
+		var properties []string
+		for _, prop := range layer.Properties {
+			if allowed[prop] {
+				properties = append(properties, prop)
+				delete(allowed, prop)
+			} else {
+				delete(layer.PropertyTypes, prop)
+			}
+		}
+		for prop := range allowed {
+			log.Warnf("Layer %s: configured property %s does not exist", layer.Name, prop)
+		}
+		layer.Properties = properties
 	}
-	layer.Properties = properties
-	layer.PropertyTypes = propertyTypes
+}
 
-	return layer, nil
+// hasZoom tests whether tiles of a layer contain data at zoom level z
+func (layer *Layer) hasZoom(z int) bool {
+	return z >= layer.MinZoom && z <= layer.MaxZoom
+}
+
+// mvtGeomExpr returns the expression encoding a geometry in tile coordinates
+// with the extent, buffer and clipping of the layer
+func (layer *Layer) mvtGeomExpr(geomExpr string) string {
+	return fmt.Sprintf("ST_AsMVTGeom(%s, (SELECT extent FROM tile_bounds), %d, %d, %t)",
+		geomExpr, layer.Extent, layer.Buffer, layer.Clip)
+}
+
+// mvtExpr returns the expression aggregating rows into the MVT layer of the layer.
+// If featureIDs is set, the feature ids are read from the feature id column of the rows.
+func (layer *Layer) mvtExpr(rows string, featureIDs bool) string {
+	if featureIDs {
+		return fmt.Sprintf("ST_AsMVT(%s, '%s', %d, 'geom', '%s')", rows, layer.Name, layer.Extent, featureIDColumn)
+	}
+	return fmt.Sprintf("ST_AsMVT(%s, '%s', %d)", rows, layer.Name, layer.Extent)
+}
+
+// tileMargin returns the buffer of the layer in CRS units for a tile with the given bounds.
+// Features are selected in the tile extended by the margin, so that they appear in the buffer.
+func (layer *Layer) tileMargin(bounds *Extent) float64 {
+	return layer.bufferRatio() * (bounds.Maxx - bounds.Minx)
+}
+
+// bufferRatio returns the buffer of the layer as a fraction of the tile size
+func (layer *Layer) bufferRatio() float64 {
+	if layer.Extent <= 0 {
+		return 0
+	}
+	return float64(layer.Buffer) / float64(layer.Extent)
+}
+
+// simplifyGeomExpr returns the expression simplifying the geometries of a layer for a tile at zoom level z.
+// The tolerance is the configured number of pixels at the resolution of the zoom level,
+// so geometries are simplified less as the zoom level increases.
+// Points are never simplified.
+func (layer *Layer) simplifyGeomExpr(geomExpr string, tms *TileMatrixSet, z int) string {
+	tm := tms.TileMatrix(z)
+	if layer.SimplifyFactor <= 0 || tm == nil || strings.Contains(strings.ToUpper(layer.GeometryType), "POINT") {
+		return geomExpr
+	}
+	tolerance := layer.SimplifyFactor * tm.CellSize
+	return fmt.Sprintf("ST_SimplifyPreserveTopology(%s, %v)", geomExpr, tolerance)
 }
 
 // GenerateTile generates an MVT tile for the given layer and tile coordinates
 // Uses the shared connection pool for efficient resource management
-func (cat *CatalogDB) GenerateTile(ctx context.Context, layerName string, z, x, y int) ([]byte, error) {
+// params holds the request values for the layer parameters (if any)
+func (cat *CatalogDB) GenerateTile(ctx context.Context, layerName string, tms *TileMatrixSet, z, x, y int, params map[string]string) ([]byte, error) {
 	layer, err := cat.GetLayerByName(layerName)
 	if err != nil {
 		return nil, err
 	}
 
+	bounds := tms.TileBounds(z, x, y)
+	if bounds == nil {
+		return nil, fmt.Errorf("zoom level %d is not part of tile matrix set %s", z, tms.ID)
+	}
+
+	// Archive layers serve pre-built tiles
+	if layer.archive != nil {
+		return layer.archiveTile(ctx, tms, z, x, y, false)
+	}
+
+	// Bind the layer parameters (validates the request values)
+	paramArgs, err := layer.bindParameters(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Outside the configured zoom range the layer has no data
+	if !layer.hasZoom(z) {
+		log.Debugf("Zoom level %d outside zoom range of layer %s [%d-%d]", z, layerName, layer.MinZoom, layer.MaxZoom)
+		return []byte{}, nil
+	}
+
+	// Above the data max zoom, tiles are cut from the parent tile instead of querying the data
+	if layer.DataMaxZoom > 0 && z > layer.DataMaxZoom {
+		if tile, ok, err := cat.overzoomTile(ctx, layer, tms, z, x, y, params); ok {
+			return tile, err
+		}
+	}
+
 	// Use the shared connection pool (connection is automatically acquired and released)
 	db := cat.dbconn
 
 	// Build the SQL query using ST_AsMVT following the Python reference implementation
 	// https://github.com/bmcandr/fast-geoparquet-features/blob/main/app/main.py#L352-L418
 
-	// Transform geometry to Web Mercator (EPSG:3857) for tiles if needed
+	// Transform geometry to the CRS of the tile matrix set if needed
 	// DuckDB Spatial requires string CRS identifiers: ST_Transform(geom, 'source_crs', 'dest_crs', always_xy := true)
-	geomExpr := layer.GeometryColumn
-	if layer.SourceSrid != SRID_3857 && layer.SourceSrid != 0 {
-		geomExpr = fmt.Sprintf("ST_Transform(%s, 'EPSG:4326', 'EPSG:3857', always_xy := true)", layer.GeometryColumn)
-	}
+	geomExpr := transformToOutCrs(layer.GeometryColumn, layer.SourceSrid, tms.Srid)
+	// Geometries are simplified before clipping, since full resolution geometries are
+	// expensive to clip and encode at low zoom levels (the tile query still uses the original geometry)
+	simplifiedExpr := layer.simplifyGeomExpr(geomExpr, tms, z)
 
 	// Build column list for properties (all non-geometry columns)
 	// We must not include the original geometry column since ST_AsMVT only allows one geometry column
@@ -500,35 +894,68 @@ func (cat *CatalogDB) GenerateTile(ctx context.Context, layerName string, z, x,
 		}
 		propertyColumns += ", "
 	}
+	// The feature ids are selected as an extra column, so that the id column is kept as a property
+	propertyColumns = layer.featureIDSelect() + propertyColumns
+
+	// Restrict features by the configured filter condition
+	filterCond := ""
+	if layer.Filter != "" {
+		filterCond = fmt.Sprintf(" AND (%s)", layer.Filter)
+	}
 
 	// The MVT generation follows this pattern:
 	// 1. Filter features that intersect the tile envelope
-	// 2. Transform geometries to EPSG:3857 if needed
-	// 3. Clip geometries to tile extent using ST_AsMVTGeom
-	// 4. Aggregate into MVT format using ST_AsMVT
-	query := fmt.Sprintf(`
-		WITH tile_bounds AS (
-			SELECT ST_TileEnvelope($1::INTEGER, $2::INTEGER, $3::INTEGER) as envelope,
-			       ST_Extent(ST_TileEnvelope($1::INTEGER, $2::INTEGER, $3::INTEGER)) as extent
-		),
-		features AS (
-			SELECT
-				%sST_AsMVTGeom(
-					%s,
-					(SELECT extent FROM tile_bounds)
-				) as geom
-			FROM %s, tile_bounds
-			WHERE ST_Intersects(%s, tile_bounds.envelope)
-		)
-		SELECT ST_AsMVT(features, '%s')
-		FROM features
-		WHERE geom IS NOT NULL
-	`, propertyColumns, geomExpr, layer.Table, geomExpr, layerName)
+	// 2. Transform geometries to the CRS of the tile matrix set if needed
+	// 3. Simplify geometries at the resolution of the zoom level (if configured)
+	// 4. Clip geometries to tile extent (and buffer) using ST_AsMVTGeom
+	// 5. Aggregate into MVT format using ST_AsMVT
+	// The tile envelope is computed from the tile matrix set
+	// (ST_TileEnvelope only supports the Web Mercator tiling scheme)
+	var query string
+	var clusterArgs []interface{}
+	var budgetQuery func(limit int) string
+	if layer.clusters(z) {
+		// At low zoom levels the points of a point layer are aggregated into clusters
+		query, clusterArgs = layer.clusterTileQuery(geomExpr, filterCond, tms.TileMatrix(z))
+	} else if layer.hasTileBudget() {
+		// Tiles exceeding the feature count or size budget of the layer are thinned
+		budgetQuery = layer.budgetTileQuery(propertyColumns, simplifiedExpr, geomExpr, filterCond)
+	} else {
+		query = fmt.Sprintf(`
+			WITH tile_bounds AS (
+				SELECT ST_MakeEnvelope($tile_xmin - $tile_margin, $tile_ymin - $tile_margin,
+				                       $tile_xmax + $tile_margin, $tile_ymax + $tile_margin) as envelope,
+				       ST_Extent(ST_MakeEnvelope($tile_xmin, $tile_ymin, $tile_xmax, $tile_ymax)) as extent
+			),
+			features AS (
+				SELECT
+					%s%s as geom
+				FROM %s, tile_bounds
+				WHERE ST_Intersects(%s, tile_bounds.envelope)%s
+			)
+			SELECT %s
+			FROM features
+			WHERE geom IS NOT NULL
+		`, propertyColumns, layer.mvtGeomExpr(simplifiedExpr), layer.source(), geomExpr, filterCond, layer.mvtExpr("features", layer.IDColumn != ""))
+	}
+
+	log.Debugf("Generating tile for layer=%s tms=%s z=%d x=%d y=%d", layerName, tms.ID, z, x, y)
 
-	log.Debugf("Generating tile for layer=%s z=%d x=%d y=%d", layerName, z, x, y)
+	// z, x and y can also be referenced by the layer query and filter
+	args := append([]interface{}{
+		sql.Named("z", z), sql.Named("x", x), sql.Named("y", y),
+		sql.Named("tile_xmin", bounds.Minx), sql.Named("tile_ymin", bounds.Miny),
+		sql.Named("tile_xmax", bounds.Maxx), sql.Named("tile_ymax", bounds.Maxy),
+		sql.Named("tile_margin", layer.tileMargin(bounds)),
+	}, paramArgs...)
+	args = append(args, clusterArgs...)
 
 	var tileData []byte
-	err = db.QueryRowContext(ctx, query, z, x, y).Scan(&tileData)
+	if budgetQuery != nil {
+		tileData, err = layer.generateBudgetTile(ctx, db, budgetQuery, args, z, x, y)
+	} else {
+		err = db.QueryRowContext(ctx, query, args...).Scan(&tileData)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error generating tile: %w", err)
 	}
@@ -551,53 +978,148 @@ func (cat *CatalogDB) GenerateTile(ctx context.Context, layerName string, z, x,
 	return tileData, nil
 }
 
-// GetTileJSON returns TileJSON metadata for a layer
+// GenerateCompositeTile generates a single MVT tile containing several layers,
+// each encoded under its own name.
+// An MVT tile is a protobuf message holding only a repeated layers field,
+// so the tiles generated for the individual layers can simply be concatenated.
+func (cat *CatalogDB) GenerateCompositeTile(ctx context.Context, layerNames []string, tms *TileMatrixSet, z, x, y int, params map[string]string) ([]byte, error) {
+	// Generate the layer tiles concurrently (the connection pool bounds the DB load)
+	tiles := make([][]byte, len(layerNames))
+	errs := make([]error, len(layerNames))
+	var wg sync.WaitGroup
+	for i, name := range layerNames {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			tiles[i], errs[i] = cat.GenerateTile(ctx, name, tms, z, x, y, params)
+		}(i, name)
+	}
+	wg.Wait()
+
+	var tileData []byte
+	for i := range layerNames {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		tileData = append(tileData, tiles[i]...)
+	}
+
+	log.Debugf("Generated composite tile for layers=%v with %d bytes", layerNames, len(tileData))
+	return tileData, nil
+}
+
+// SplitLayerNames splits a layer specification such as "roads,water"
+// into its layer names. Blank and duplicate names are dropped.
+func SplitLayerNames(spec string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(spec, LayerNameSeparator) {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// GetTileJSON returns TileJSON metadata for a layer.
+// layerName may also be a composite layer specification (e.g. "roads,water"),
+// in which case the metadata describes all the layers contained in the tiles.
 func (cat *CatalogDB) GetTileJSON(layerName string, baseURL string) (*TileJSON, error) {
-	layer, err := cat.GetLayerByName(layerName)
+	layerNames := SplitLayerNames(layerName)
+	if len(layerNames) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrLayerNotFound, layerName)
+	}
+
+	layers := make([]*Layer, 0, len(layerNames))
+	for _, name := range layerNames {
+		layer, err := cat.GetLayerByName(name)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	tms, err := LayerTileMatrixSet(layerNames)
 	if err != nil {
 		return nil, err
 	}
 
-	tileURL := fmt.Sprintf("%s/tiles/%s/{z}/{x}/{y}.mvt", baseURL, layerName)
+	tileURL := fmt.Sprintf("%s/tiles/%s/{z}/{x}/{y}.mvt", baseURL, strings.Join(layerNames, LayerNameSeparator))
 
 	tj := &TileJSON{
 		TileJSON: "3.0.0",
-		Name:     layer.Name,
+		Name:     strings.Join(layerNames, LayerNameSeparator),
 		Version:  "1.0.0",
 		Scheme:   "xyz",
 		Tiles:    []string{tileURL},
-		MinZoom:  0,
-		MaxZoom:  22,
+		MinZoom:  layers[0].MinZoom,
+		MaxZoom:  layers[0].MaxZoom,
+
+		TileMatrixSet: tms.ID,
 	}
+	if len(layers) == 1 {
+		tj.Description = layers[0].Description
+	}
+	tj.Attribution = layersAttribution(layers)
 
-	// Add bounds if available
-	if layer.Bounds != nil {
+	// Add bounds if available (union of all layer bounds)
+	var bounds *Extent
+	for _, layer := range layers {
+		if layer.Bounds == nil {
+			continue
+		}
+		if bounds == nil {
+			b := *layer.Bounds
+			bounds = &b
+			continue
+		}
+		bounds.Minx = math.Min(bounds.Minx, layer.Bounds.Minx)
+		bounds.Miny = math.Min(bounds.Miny, layer.Bounds.Miny)
+		bounds.Maxx = math.Max(bounds.Maxx, layer.Bounds.Maxx)
+		bounds.Maxy = math.Max(bounds.Maxy, layer.Bounds.Maxy)
+	}
+	if bounds != nil {
+		// layer bounds are in Web Mercator, TileJSON bounds in longitude/latitude
+		bounds = webMercatorToLonLatExtent(bounds)
 		tj.Bounds = []float64{
-			layer.Bounds.Minx,
-			layer.Bounds.Miny,
-			layer.Bounds.Maxx,
-			layer.Bounds.Maxy,
+			bounds.Minx,
+			bounds.Miny,
+			bounds.Maxx,
+			bounds.Maxy,
 		}
 
 		// Calculate center point
-		centerX := (layer.Bounds.Minx + layer.Bounds.Maxx) / 2
-		centerY := (layer.Bounds.Miny + layer.Bounds.Maxy) / 2
+		centerX := (bounds.Minx + bounds.Maxx) / 2
+		centerY := (bounds.Miny + bounds.Maxy) / 2
 		tj.Center = []float64{centerX, centerY, 10} // default zoom 10
 	}
 
 	// Add vector layer metadata
-	fields := make(map[string]string)
-	for _, prop := range layer.Properties {
-		fields[prop] = "string" // simplified - could determine actual type
-	}
-
-	tj.VectorLayers = []VectorLayer{
-		{
-			ID:      layerName,
-			MinZoom: 0,
-			MaxZoom: 22,
-			Fields:  fields,
-		},
+	// (the tile source zoom range covers the zoom ranges of all layers)
+	for _, layer := range layers {
+		if layer.MinZoom < tj.MinZoom {
+			tj.MinZoom = layer.MinZoom
+		}
+		if layer.MaxZoom > tj.MaxZoom {
+			tj.MaxZoom = layer.MaxZoom
+		}
+
+		if layer.archive != nil {
+			tj.VectorLayers = append(tj.VectorLayers, layer.archiveVectorLayers()...)
+			continue
+		}
+
+		tj.VectorLayers = append(tj.VectorLayers, VectorLayer{
+			ID:           layer.Name,
+			Description:  layer.Description,
+			MinZoom:      layer.MinZoom,
+			MaxZoom:      layer.MaxZoom,
+			Fields:       layer.vectorLayerFields(cat.queryColumnComments(layer)),
+			GeometryType: mvtGeometryType(layer.GeometryType),
+		})
 	}
 
 	return tj, nil
@@ -1,9 +1,47 @@
 package data
 
 import (
+	"database/sql"
+	"fmt"
+	"math"
+	"path/filepath"
 	"testing"
+
+	"github.com/tobilg/duckdb-tileserver/internal/archive"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
 )
 
+// newSpatialTestCatalog returns a catalog on an in-memory DuckDB database with the spatial extension,
+// and restores the configuration at the end of the test.
+// The spatial extension is downloaded on first use: if it is not available the test fails,
+// except in short mode (go test -short) where it is skipped.
+func newSpatialTestCatalog(t *testing.T) *CatalogDB {
+	t.Helper()
+	originalConfig := conf.Configuration
+	t.Cleanup(func() {
+		conf.Configuration = originalConfig
+	})
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	if _, err := db.Exec("INSTALL spatial; LOAD spatial;"); err != nil {
+		if testing.Short() {
+			t.Skipf("Spatial extension not available: %v", err)
+		}
+		t.Fatalf("Spatial extension not available (run with -short to skip): %v", err)
+	}
+	return &CatalogDB{
+		dbconn:             db,
+		layerMetadataCache: make(map[string]*Layer),
+		archives:           make(map[string]archive.Reader),
+	}
+}
+
 func TestLayerStruct(t *testing.T) {
 	layer := &Layer{
 		Name:           "buildings",
@@ -101,3 +139,227 @@ func TestVectorLayerFields(t *testing.T) {
 		t.Errorf("Expected name field to be 'string', got '%s'", vl.Fields["name"])
 	}
 }
+
+func TestSplitLayerNames(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected []string
+	}{
+		{"roads", []string{"roads"}},
+		{"roads,water,buildings", []string{"roads", "water", "buildings"}},
+		{"roads, water", []string{"roads", "water"}},
+		{"roads,,water,roads", []string{"roads", "water"}},
+		{"", nil},
+		{",", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			testEquals(t, tt.expected, SplitLayerNames(tt.spec), "SplitLayerNames")
+		})
+	}
+}
+
+func TestApplyLayerConfig(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+
+	buffer := 0
+
+	conf.Configuration.Layers = []conf.Layer{
+		{
+			Name:        "roads",
+			Title:       "Roads",
+			Description: "Road network",
+			MinZoom:     5,
+			MaxZoom:     14,
+			Properties:  []string{"name", "class", "missing"},
+			Filter:      "class <> 'service'",
+			Extent:      512,
+			Buffer:      &buffer,
+			Srid:        25832,
+		},
+	}
+
+	layer := &Layer{
+		Name:          "roads",
+		Properties:    []string{"class", "internal_id", "name"},
+		PropertyTypes: map[string]string{"class": "VARCHAR", "internal_id": "BIGINT", "name": "VARCHAR"},
+	}
+	applyLayerConfig(layer)
+
+	testEquals(t, "Roads", layer.Title, "Title")
+	testEquals(t, "Road network", layer.Description, "Description")
+	testEquals(t, 5, layer.MinZoom, "MinZoom")
+	testEquals(t, 14, layer.MaxZoom, "MaxZoom")
+	testEquals(t, "class <> 'service'", layer.Filter, "Filter")
+	testEquals(t, []string{"class", "name"}, layer.Properties, "Properties")
+	testEquals(t, map[string]string{"class": "VARCHAR", "name": "VARCHAR"}, layer.PropertyTypes, "PropertyTypes")
+	testEquals(t, "ST_AsMVTGeom(geom, (SELECT extent FROM tile_bounds), 512, 0, true)", layer.mvtGeomExpr("geom"), "MVT geometry")
+	testEquals(t, "ST_AsMVT(features, 'roads', 512)", layer.mvtExpr("features", false), "MVT layer")
+	testEquals(t, 25832, configuredSrid("roads"), "Configured SRID")
+	testEquals(t, 0, configuredSrid("buildings"), "SRID without configuration")
+
+	if layer.hasZoom(4) || !layer.hasZoom(5) || !layer.hasZoom(14) || layer.hasZoom(15) {
+		t.Errorf("Unexpected zoom range check for [%d-%d]", layer.MinZoom, layer.MaxZoom)
+	}
+
+	// Layers without configuration keep all properties and the default zoom range
+	other := &Layer{
+		Name:       "buildings",
+		Properties: []string{"height", "name"},
+	}
+	applyLayerConfig(other)
+
+	testEquals(t, DefaultMinZoom, other.MinZoom, "Default MinZoom")
+	testEquals(t, DefaultMaxZoom, other.MaxZoom, "Default MaxZoom")
+	testEquals(t, "ST_AsMVTGeom(geom, (SELECT extent FROM tile_bounds), 4096, 256, true)", other.mvtGeomExpr("geom"), "Default MVT geometry")
+	// the buffer of 256 units is a sixteenth of the tile
+	testEquals(t, 10.0, other.tileMargin(&Extent{Minx: 0, Miny: 0, Maxx: 160, Maxy: 160}), "Tile margin")
+	testEquals(t, []string{"height", "name"}, other.Properties, "Properties")
+}
+
+func TestSimplifyGeomExpr(t *testing.T) {
+	webMercator := TileMatrixSetByID(TileMatrixSetWebMercatorQuad)
+	layer := &Layer{Name: "coastlines", GeometryType: "MULTIPOLYGON", SimplifyFactor: 0.5}
+
+	// the tolerance halves with each zoom level
+	cellSize := webMercator.TileMatrix(4).CellSize
+	testEquals(t, fmt.Sprintf("ST_SimplifyPreserveTopology(geom, %v)", 0.5*cellSize),
+		layer.simplifyGeomExpr("geom", webMercator, 4), "Simplified at zoom 4")
+	testEquals(t, fmt.Sprintf("ST_SimplifyPreserveTopology(geom, %v)", 0.25*cellSize),
+		layer.simplifyGeomExpr("geom", webMercator, 5), "Simplified at zoom 5")
+
+	points := &Layer{Name: "cities", GeometryType: "MULTIPOINT", SimplifyFactor: 0.5}
+	testEquals(t, "geom", points.simplifyGeomExpr("geom", webMercator, 4), "Points are not simplified")
+
+	layer.SimplifyFactor = 0
+	testEquals(t, "geom", layer.simplifyGeomExpr("geom", webMercator, 4), "Simplification disabled")
+}
+
+func TestQueryLayerSource(t *testing.T) {
+	layer := &Layer{
+		Name: "districts",
+		Sql:  "SELECT d.id, d.name, d.geom FROM districts d JOIN regions r ON d.region = r.id",
+	}
+	testEquals(t, "(SELECT d.id, d.name, d.geom FROM districts d JOIN regions r ON d.region = r.id) AS layer_source",
+		layer.source(), "Query layer source")
+
+	table := &Layer{Name: "roads", Table: "roads"}
+	testEquals(t, "roads", table.source(), "Table layer source")
+}
+
+func TestQueryLayerProperties(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cat := &CatalogDB{dbconn: db}
+
+	layer := &Layer{
+		Name:           "stats",
+		GeometryColumn: "geom",
+		Sql:            "SELECT 1::INTEGER AS zone, 'a' AS name, 2.5::DOUBLE AS area, 'POINT(0 0)' AS geom",
+	}
+	properties, propertyTypes, err := cat.queryLayerProperties(layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, []string{"area", "name", "zone"}, properties, "Properties")
+	testEquals(t, map[string]string{"area": "DOUBLE", "name": "VARCHAR", "zone": "INTEGER"}, propertyTypes, "PropertyTypes")
+
+	// A query without geometry column cannot be a layer
+	_, err = cat.newSourceLayer(&conf.Layer{Name: "nogeom", Sql: "SELECT 1 AS id;"})
+	if err == nil {
+		t.Error("Expected error for query layer without geometry column")
+	}
+}
+
+func TestFileSourceFunction(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"data/roads.parquet", "read_parquet('data/roads.parquet')"},
+		{"data/roads/*.parquet", "read_parquet('data/roads/*.parquet')"},
+		{"s3://bucket/lake/roads/*.parquet", "read_parquet('s3://bucket/lake/roads/*.parquet')"},
+		{"data/parcels.gpkg", "ST_Read('data/parcels.gpkg')"},
+		{"data/o'brien.geojson", "ST_Read('data/o''brien.geojson')"},
+		{" read_parquet('data/*.parquet', hive_partitioning = true) ", "read_parquet('data/*.parquet', hive_partitioning = true)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			testEquals(t, tt.expected, fileSourceFunction(tt.source), "fileSourceFunction")
+		})
+	}
+}
+
+func TestFileSourceLayer(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cat := &CatalogDB{dbconn: db}
+
+	// Write a local Parquet file with geometries stored as WKB blobs
+	dir := t.TempDir()
+	file := filepath.Join(dir, "points.parquet")
+	_, err = db.Exec(fmt.Sprintf(`COPY (SELECT 1 AS id, 'a' AS name, '\x01\x01'::BLOB AS wkb) TO '%s' (FORMAT PARQUET)`, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layerConf := &conf.Layer{
+		Name:           "points",
+		Source:         filepath.Join(dir, "*.parquet"),
+		GeometryColumn: "wkb",
+	}
+	layer, err := cat.newSourceLayer(layerConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedSql := fmt.Sprintf("SELECT * REPLACE (ST_GeomFromWKB(wkb) AS wkb) FROM read_parquet('%s')", layerConf.Source)
+	testEquals(t, expectedSql, layer.Sql, "WKB conversion query")
+	testEquals(t, "", layer.Table, "Table")
+
+	// Without a geometry column the files cannot be served
+	_, err = cat.newSourceLayer(&conf.Layer{Name: "points", Source: file})
+	if err == nil {
+		t.Error("Expected error for file layer without geometry column")
+	}
+}
+
+func TestProjectedLayerBounds(t *testing.T) {
+	cat := newSpatialTestCatalog(t)
+	// 10 points in Zurich, in the Swiss coordinate system (EPSG:2056)
+	_, err := cat.dbconn.Exec(`CREATE TABLE parcels AS
+		SELECT i AS id, ST_Point(2683000 + i * 100, 1247000 + i * 100) AS geom
+		FROM range(10) t(i)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Configuration.Layers = []conf.Layer{{Name: "parcels", Srid: 2056}}
+
+	layer, err := cat.GetLayerByName("parcels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEquals(t, 2056, layer.SourceSrid, "Configured source SRID")
+
+	// Zurich is at about 8.54°E, 47.38°N: x = 950 km, y = 6004 km in Web Mercator
+	bounds := cat.LayersBounds([]string{"parcels"})
+	if bounds == nil {
+		t.Fatal("Expected layer bounds")
+	}
+	if math.Abs(bounds.Minx-950000) > 5000 || math.Abs(bounds.Miny-6004000) > 5000 {
+		t.Errorf("Unexpected Web Mercator bounds: %+v", bounds)
+	}
+	if bounds.Maxx <= bounds.Minx || bounds.Maxy <= bounds.Miny {
+		t.Errorf("Empty Web Mercator bounds: %+v", bounds)
+	}
+}
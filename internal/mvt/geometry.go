@@ -0,0 +1,236 @@
+package mvt
+
+import (
+	"fmt"
+	"math"
+)
+
+// Point is a point in tile coordinates
+// (wide enough to hold the coordinates of a rescaled tile)
+type Point struct {
+	X, Y int64
+}
+
+// geometry commands
+const (
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+// DecodeGeometry returns the parts of a feature geometry:
+// the points of a (multi)point, the lines of a (multi)linestring or the rings of a (multi)polygon.
+// Rings are not closed (the first point isn't repeated).
+func DecodeGeometry(geometry []uint32) ([][]Point, error) {
+	var parts [][]Point
+	var cursor Point
+	for i := 0; i < len(geometry); {
+		cmd, count := geometry[i]&0x7, int(geometry[i]>>3)
+		i++
+		switch cmd {
+		case cmdMoveTo, cmdLineTo:
+			if i+2*count > len(geometry) {
+				return nil, errTruncated
+			}
+			for j := 0; j < count; j++ {
+				cursor.X += zigzagDecode(geometry[i])
+				cursor.Y += zigzagDecode(geometry[i+1])
+				i += 2
+				if cmd == cmdMoveTo || len(parts) == 0 {
+					parts = append(parts, nil)
+				}
+				parts[len(parts)-1] = append(parts[len(parts)-1], cursor)
+			}
+		case cmdClosePath:
+		default:
+			return nil, fmt.Errorf("unknown geometry command %d", cmd)
+		}
+	}
+	return parts, nil
+}
+
+// EncodeGeometry encodes the parts of a feature geometry (see DecodeGeometry) as commands
+func EncodeGeometry(geomType GeomType, parts [][]Point) []uint32 {
+	var geometry []uint32
+	var cursor Point
+	appendPoint := func(p Point) {
+		geometry = append(geometry, zigzagEncode(p.X-cursor.X), zigzagEncode(p.Y-cursor.Y))
+		cursor = p
+	}
+
+	if geomType == GeomPoint {
+		geometry = append(geometry, command(cmdMoveTo, len(parts)))
+		for _, part := range parts {
+			appendPoint(part[0])
+		}
+		return geometry
+	}
+	for _, part := range parts {
+		geometry = append(geometry, command(cmdMoveTo, 1))
+		appendPoint(part[0])
+		geometry = append(geometry, command(cmdLineTo, len(part)-1))
+		for _, p := range part[1:] {
+			appendPoint(p)
+		}
+		if geomType == GeomPolygon {
+			geometry = append(geometry, command(cmdClosePath, 1))
+		}
+	}
+	return geometry
+}
+
+func command(cmd int, count int) uint32 {
+	return uint32(cmd&0x7 | count<<3)
+}
+
+func zigzagDecode(value uint32) int64 {
+	return int64(int32(value>>1) ^ -int32(value&1))
+}
+
+func zigzagEncode(value int64) uint32 {
+	return uint32(int32(value<<1) ^ int32(value>>31))
+}
+
+// ringArea returns the signed area of a ring.
+// Exterior rings have a positive area in tile coordinates (y axis pointing down).
+func ringArea(ring []Point) float64 {
+	var area float64
+	for i, p := range ring {
+		q := ring[(i+1)%len(ring)]
+		area += float64(p.X)*float64(q.Y) - float64(q.X)*float64(p.Y)
+	}
+	return area / 2
+}
+
+// clipBox is the area geometries are clipped to
+type clipBox struct {
+	min, max int64
+}
+
+func (box clipBox) contains(p Point) bool {
+	return p.X >= box.min && p.X <= box.max && p.Y >= box.min && p.Y <= box.max
+}
+
+// clipLine clips a line to the box, which may split it into several lines
+func (box clipBox) clipLine(line []Point) [][]Point {
+	var lines [][]Point
+	var current []Point
+	for i := 0; i+1 < len(line); i++ {
+		a, b, ok := box.clipSegment(line[i], line[i+1])
+		if !ok {
+			continue
+		}
+		if len(current) == 0 || current[len(current)-1] != a {
+			if len(current) >= 2 {
+				lines = append(lines, current)
+			}
+			current = []Point{a}
+		}
+		if b != a {
+			current = append(current, b)
+		}
+	}
+	if len(current) >= 2 {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// clipSegment clips a segment to the box (Liang-Barsky)
+func (box clipBox) clipSegment(a, b Point) (Point, Point, bool) {
+	dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+	t0, t1 := 0.0, 1.0
+	edges := []struct{ p, q float64 }{
+		{-dx, float64(a.X - box.min)},
+		{dx, float64(box.max - a.X)},
+		{-dy, float64(a.Y - box.min)},
+		{dy, float64(box.max - a.Y)},
+	}
+	for _, e := range edges {
+		if e.p == 0 {
+			if e.q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		t := e.q / e.p
+		if e.p < 0 {
+			t0 = math.Max(t0, t)
+		} else {
+			t1 = math.Min(t1, t)
+		}
+		if t0 > t1 {
+			return a, b, false
+		}
+	}
+	clippedA, clippedB := a, b
+	if t0 > 0 {
+		clippedA = Point{a.X + int64(math.Round(t0*dx)), a.Y + int64(math.Round(t0*dy))}
+	}
+	if t1 < 1 {
+		clippedB = Point{a.X + int64(math.Round(t1*dx)), a.Y + int64(math.Round(t1*dy))}
+	}
+	return clippedA, clippedB, true
+}
+
+// clipRing clips a polygon ring to the box (Sutherland-Hodgman).
+// The orientation of the ring is kept.
+func (box clipBox) clipRing(ring []Point) []Point {
+	inside := []func(p Point) bool{
+		func(p Point) bool { return p.X >= box.min },
+		func(p Point) bool { return p.X <= box.max },
+		func(p Point) bool { return p.Y >= box.min },
+		func(p Point) bool { return p.Y <= box.max },
+	}
+	intersect := []func(a, b Point) Point{
+		func(a, b Point) Point { return intersectX(a, b, box.min) },
+		func(a, b Point) Point { return intersectX(a, b, box.max) },
+		func(a, b Point) Point { return intersectY(a, b, box.min) },
+		func(a, b Point) Point { return intersectY(a, b, box.max) },
+	}
+	for edge := range inside {
+		if len(ring) == 0 {
+			break
+		}
+		var clipped []Point
+		for i, b := range ring {
+			a := ring[(i+len(ring)-1)%len(ring)]
+			if inside[edge](b) {
+				if !inside[edge](a) {
+					clipped = append(clipped, intersect[edge](a, b))
+				}
+				clipped = append(clipped, b)
+			} else if inside[edge](a) {
+				clipped = append(clipped, intersect[edge](a, b))
+			}
+		}
+		ring = clipped
+	}
+	return dedupe(ring, true)
+}
+
+// intersectX returns the intersection of segment ab with the vertical line at x
+func intersectX(a, b Point, x int64) Point {
+	t := float64(x-a.X) / float64(b.X-a.X)
+	return Point{x, a.Y + int64(math.Round(t*float64(b.Y-a.Y)))}
+}
+
+// intersectY returns the intersection of segment ab with the horizontal line at y
+func intersectY(a, b Point, y int64) Point {
+	t := float64(y-a.Y) / float64(b.Y-a.Y)
+	return Point{a.X + int64(math.Round(t*float64(b.X-a.X))), y}
+}
+
+// dedupe removes repeated consecutive points (and the closing point of a ring)
+func dedupe(points []Point, ring bool) []Point {
+	var result []Point
+	for _, p := range points {
+		if len(result) == 0 || result[len(result)-1] != p {
+			result = append(result, p)
+		}
+	}
+	if ring && len(result) > 1 && result[0] == result[len(result)-1] {
+		result = result[:len(result)-1]
+	}
+	return result
+}
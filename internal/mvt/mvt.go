@@ -0,0 +1,280 @@
+// Package mvt decodes and encodes Mapbox Vector Tiles (MVT 2.1),
+// to derive tiles from pre-built tiles without querying the database.
+// See https://github.com/mapbox/vector-tile-spec/tree/master/2.1
+package mvt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// GeomType is the geometry type of a feature
+type GeomType uint32
+
+// Geometry types
+const (
+	GeomUnknown    GeomType = 0
+	GeomPoint      GeomType = 1
+	GeomLineString GeomType = 2
+	GeomPolygon    GeomType = 3
+)
+
+// DefaultExtent is the extent of a layer which doesn't specify one
+const DefaultExtent = 4096
+
+// Tile is a decoded vector tile
+type Tile struct {
+	Layers []*Layer
+}
+
+// Layer is a layer of a vector tile.
+// Property values are kept encoded, since they are not transformed.
+type Layer struct {
+	Version  uint32
+	Name     string
+	Extent   uint32
+	Features []*Feature
+	Keys     []string
+	Values   [][]byte // encoded Value messages
+}
+
+// Feature is a feature of a layer.
+// The geometry is kept as a command sequence (see DecodeGeometry).
+type Feature struct {
+	ID       uint64
+	HasID    bool
+	Tags     []uint32
+	Type     GeomType
+	Geometry []uint32
+}
+
+// protobuf field numbers
+const (
+	tileLayers = 3
+
+	layerVersion  = 15
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated vector tile")
+
+// Decode decodes a vector tile
+func Decode(data []byte) (*Tile, error) {
+	tile := &Tile{}
+	err := readMessage(data, func(field int, wire int, value uint64, bytes []byte) error {
+		if field == tileLayers && wire == wireBytes {
+			layer, err := decodeLayer(bytes)
+			if err != nil {
+				return err
+			}
+			tile.Layers = append(tile.Layers, layer)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tile, nil
+}
+
+func decodeLayer(data []byte) (*Layer, error) {
+	layer := &Layer{Version: 1, Extent: DefaultExtent}
+	err := readMessage(data, func(field int, wire int, value uint64, bytes []byte) error {
+		switch {
+		case field == layerVersion && wire == wireVarint:
+			layer.Version = uint32(value)
+		case field == layerName && wire == wireBytes:
+			layer.Name = string(bytes)
+		case field == layerExtent && wire == wireVarint:
+			layer.Extent = uint32(value)
+		case field == layerKeys && wire == wireBytes:
+			layer.Keys = append(layer.Keys, string(bytes))
+		case field == layerValues && wire == wireBytes:
+			layer.Values = append(layer.Values, bytes)
+		case field == layerFeatures && wire == wireBytes:
+			feature, err := decodeFeature(bytes)
+			if err != nil {
+				return err
+			}
+			layer.Features = append(layer.Features, feature)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("layer %q: %w", layer.Name, err)
+	}
+	return layer, nil
+}
+
+func decodeFeature(data []byte) (*Feature, error) {
+	feature := &Feature{}
+	err := readMessage(data, func(field int, wire int, value uint64, bytes []byte) error {
+		var err error
+		switch {
+		case field == featureID && wire == wireVarint:
+			feature.ID, feature.HasID = value, true
+		case field == featureType && wire == wireVarint:
+			feature.Type = GeomType(value)
+		case field == featureTags && wire == wireBytes:
+			feature.Tags, err = readPacked(bytes, feature.Tags)
+		case field == featureTags && wire == wireVarint:
+			feature.Tags = append(feature.Tags, uint32(value))
+		case field == featureGeometry && wire == wireBytes:
+			feature.Geometry, err = readPacked(bytes, feature.Geometry)
+		case field == featureGeometry && wire == wireVarint:
+			feature.Geometry = append(feature.Geometry, uint32(value))
+		}
+		return err
+	})
+	return feature, err
+}
+
+// readMessage calls handle for each field of a protobuf message.
+// value is set for varint and fixed fields, bytes for length-delimited fields.
+func readMessage(data []byte, handle func(field int, wire int, value uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		key, n := readVarint(data)
+		if n == 0 {
+			return errTruncated
+		}
+		data = data[n:]
+		field, wire := int(key>>3), int(key&0x7)
+
+		var value uint64
+		var bytes []byte
+		switch wire {
+		case wireVarint:
+			if value, n = readVarint(data); n == 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if wire == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return errTruncated
+			}
+			data = data[size:]
+		case wireBytes:
+			length, n := readVarint(data)
+			if n == 0 || uint64(len(data)-n) < length {
+				return errTruncated
+			}
+			bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wire)
+		}
+		if err := handle(field, wire, value, bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readVarint reads a varint, returning its value and size (0 if data is truncated)
+func readVarint(data []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		value |= uint64(data[i]&0x7f) << (7 * uint(i))
+		if data[i] < 0x80 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// readPacked appends the values of a packed repeated uint32 field
+func readPacked(data []byte, values []uint32) ([]uint32, error) {
+	for len(data) > 0 {
+		value, n := readVarint(data)
+		if n == 0 {
+			return nil, errTruncated
+		}
+		values = append(values, uint32(value))
+		data = data[n:]
+	}
+	return values, nil
+}
+
+// Encode encodes the tile
+func (tile *Tile) Encode() []byte {
+	var buf []byte
+	for _, layer := range tile.Layers {
+		buf = appendBytesField(buf, tileLayers, layer.encode())
+	}
+	return buf
+}
+
+func (layer *Layer) encode() []byte {
+	buf := appendVarintField(nil, layerVersion, uint64(layer.Version))
+	buf = appendBytesField(buf, layerName, []byte(layer.Name))
+	for _, feature := range layer.Features {
+		buf = appendBytesField(buf, layerFeatures, feature.encode())
+	}
+	for _, key := range layer.Keys {
+		buf = appendBytesField(buf, layerKeys, []byte(key))
+	}
+	for _, value := range layer.Values {
+		buf = appendBytesField(buf, layerValues, value)
+	}
+	return appendVarintField(buf, layerExtent, uint64(layer.Extent))
+}
+
+func (feature *Feature) encode() []byte {
+	var buf []byte
+	if feature.HasID {
+		buf = appendVarintField(buf, featureID, feature.ID)
+	}
+	if len(feature.Tags) > 0 {
+		buf = appendBytesField(buf, featureTags, packed(feature.Tags))
+	}
+	buf = appendVarintField(buf, featureType, uint64(feature.Type))
+	return appendBytesField(buf, featureGeometry, packed(feature.Geometry))
+}
+
+func packed(values []uint32) []byte {
+	var buf []byte
+	for _, value := range values {
+		buf = appendVarint(buf, uint64(value))
+	}
+	return buf
+}
+
+func appendVarintField(buf []byte, field int, value uint64) []byte {
+	buf = appendVarint(buf, uint64(field<<3|wireVarint))
+	return appendVarint(buf, value)
+}
+
+func appendBytesField(buf []byte, field int, value []byte) []byte {
+	buf = appendVarint(buf, uint64(field<<3|wireBytes))
+	buf = appendVarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func appendVarint(buf []byte, value uint64) []byte {
+	for value >= 0x80 {
+		buf = append(buf, byte(value)|0x80)
+		value >>= 7
+	}
+	return append(buf, byte(value))
+}
@@ -0,0 +1,122 @@
+package mvt
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testTile returns a tile with a layer holding a point, a line and a polygon with a hole
+func testTile() *Tile {
+	return &Tile{Layers: []*Layer{{
+		Version: 2,
+		Name:    "roads",
+		Extent:  4096,
+		Keys:    []string{"name"},
+		Values:  [][]byte{{0x0a, 0x01, 'a'}}, // string_value "a"
+		Features: []*Feature{
+			{ID: 1, HasID: true, Tags: []uint32{0, 0}, Type: GeomPoint,
+				Geometry: EncodeGeometry(GeomPoint, [][]Point{{{100, 100}}, {{3000, 3000}}})},
+			{ID: 2, HasID: true, Type: GeomLineString,
+				Geometry: EncodeGeometry(GeomLineString, [][]Point{{{0, 1000}, {4096, 1000}}})},
+			{Type: GeomPolygon,
+				Geometry: EncodeGeometry(GeomPolygon, [][]Point{
+					{{0, 0}, {2048, 0}, {2048, 2048}, {0, 2048}},
+					{{512, 512}, {512, 1536}, {1536, 1536}, {1536, 512}},
+				})},
+		},
+	}}}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tile := testTile()
+	decoded, err := Decode(tile.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tile, decoded) {
+		t.Errorf("expected %+v, got %+v", tile.Layers[0], decoded.Layers[0])
+	}
+
+	parts, err := DecodeGeometry(decoded.Layers[0].Features[2].Geometry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || ringArea(parts[0]) <= 0 || ringArea(parts[1]) >= 0 {
+		t.Errorf("expected exterior and interior ring, got %v", parts)
+	}
+
+	if _, err := Decode(tile.Encode()[:20]); err == nil {
+		t.Error("expected error for truncated tile")
+	}
+}
+
+func TestOverzoom(t *testing.T) {
+	data := testTile().Encode()
+
+	// top-left child: the point at 100,100, the line and the polygon (hole clipped at the buffer)
+	child, err := Overzoom(data, 1, 0, 0, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile, err := Decode(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := tile.Layers[0]
+	if layer.Name != "roads" || len(layer.Features) != 3 || !reflect.DeepEqual(layer.Keys, []string{"name"}) {
+		t.Fatalf("unexpected layer %+v", layer)
+	}
+	geometries := make([][][]Point, len(layer.Features))
+	for i, feature := range layer.Features {
+		if geometries[i], err = DecodeGeometry(feature.Geometry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := [][][]Point{
+		{{{200, 200}}},
+		{{{0, 2000}, {4160, 2000}}},
+		{
+			{{0, 0}, {4096, 0}, {4096, 4096}, {0, 4096}},
+			{{1024, 1024}, {1024, 3072}, {3072, 3072}, {3072, 1024}},
+		},
+	}
+	if !reflect.DeepEqual(expected, geometries) {
+		t.Errorf("expected geometries %v, got %v", expected, geometries)
+	}
+	if layer.Features[0].ID != 1 || !reflect.DeepEqual(layer.Features[0].Tags, []uint32{0, 0}) {
+		t.Errorf("expected feature id and tags to be kept, got %+v", layer.Features[0])
+	}
+
+	// without buffer, the grandchild in column 2 and row 2 only contains the second point
+	// (the polygon only touches its top-left corner)
+	child, err = Overzoom(data, 2, 2, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile, _ = Decode(child)
+	if len(tile.Layers) != 1 || len(tile.Layers[0].Features) != 1 {
+		t.Fatalf("expected a single feature, got %+v", tile.Layers)
+	}
+	if parts, _ := DecodeGeometry(tile.Layers[0].Features[0].Geometry); !reflect.DeepEqual(parts, [][]Point{{{3808, 3808}}}) {
+		t.Errorf("unexpected point %v", parts)
+	}
+
+	// the bottom-right grandchild has no features
+	child, err = Overzoom(data, 2, 3, 3, 0)
+	if err != nil || child != nil {
+		t.Errorf("expected empty tile, got %v (%v)", child, err)
+	}
+}
+
+func TestClipRing(t *testing.T) {
+	box := clipBox{min: 0, max: 10}
+	// a triangle crossing the right edge
+	ring := box.clipRing([]Point{{5, 0}, {15, 5}, {5, 10}})
+	expected := []Point{{5, 0}, {10, 3}, {10, 8}, {5, 10}}
+	if !reflect.DeepEqual(expected, ring) {
+		t.Errorf("expected %v, got %v", expected, ring)
+	}
+	if ring := box.clipRing([]Point{{20, 20}, {30, 20}, {30, 30}}); len(ring) != 0 {
+		t.Errorf("expected ring outside the box to be dropped, got %v", ring)
+	}
+}
@@ -0,0 +1,89 @@
+package mvt
+
+// Overzoom cuts the area of a descendant tile out of a tile, rescaled to the extent of the tile layers.
+// The descendant tile is dz zoom levels deeper, in column dx and row dy
+// of the 2^dz x 2^dz descendant tiles covering the tile.
+// Geometries are clipped to the descendant tile extended by buffer (in units of the layer extent).
+// Layers without features in the descendant tile are dropped, nil is returned if no layer remains.
+func Overzoom(data []byte, dz, dx, dy int, buffer int) ([]byte, error) {
+	tile, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	scale := int64(1) << uint(dz)
+	var layers []*Layer
+	for _, layer := range tile.Layers {
+		extent := int64(layer.Extent)
+		offset := Point{int64(dx) * extent, int64(dy) * extent}
+		box := clipBox{min: -int64(buffer), max: extent + int64(buffer)}
+
+		var features []*Feature
+		for _, feature := range layer.Features {
+			parts, err := DecodeGeometry(feature.Geometry)
+			if err != nil {
+				return nil, err
+			}
+			for _, part := range parts {
+				for i, p := range part {
+					part[i] = Point{p.X*scale - offset.X, p.Y*scale - offset.Y}
+				}
+			}
+			parts = clipGeometry(feature.Type, parts, box)
+			if len(parts) == 0 {
+				continue
+			}
+			clipped := *feature
+			clipped.Geometry = EncodeGeometry(feature.Type, parts)
+			features = append(features, &clipped)
+		}
+		if len(features) == 0 {
+			continue
+		}
+		clipped := *layer
+		clipped.Features = features
+		layers = append(layers, &clipped)
+	}
+
+	if len(layers) == 0 {
+		return nil, nil
+	}
+	return (&Tile{Layers: layers}).Encode(), nil
+}
+
+// clipGeometry clips the parts of a geometry to a box.
+// The interior rings of a polygon are dropped with their exterior ring.
+func clipGeometry(geomType GeomType, parts [][]Point, box clipBox) [][]Point {
+	var clipped [][]Point
+	switch geomType {
+	case GeomPoint:
+		for _, part := range parts {
+			if box.contains(part[0]) {
+				clipped = append(clipped, part[:1])
+			}
+		}
+	case GeomLineString:
+		for _, line := range parts {
+			clipped = append(clipped, box.clipLine(dedupe(line, false))...)
+		}
+	case GeomPolygon:
+		keepInteriors := false
+		for _, ring := range parts {
+			area := ringArea(ring)
+			exterior := area > 0
+			if area == 0 || (!exterior && !keepInteriors) {
+				continue
+			}
+			ring = box.clipRing(ring)
+			if len(ring) < 3 || ringArea(ring) == 0 {
+				if exterior {
+					keepInteriors = false
+				}
+				continue
+			}
+			keepInteriors = keepInteriors || exterior
+			clipped = append(clipped, ring)
+		}
+	}
+	return clipped
+}
@@ -1,10 +1,13 @@
 package service
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/data"
 )
 
 // handleCacheStats returns cache statistics as JSON
@@ -26,6 +29,7 @@ func (s *Service) handleCacheClear(w http.ResponseWriter, r *http.Request) *appE
 	}
 
 	s.cache.Clear()
+	clearParentTiles("")
 
 	return writeJSON(w, "application/json", map[string]string{
 		"status":  "ok",
@@ -43,6 +47,7 @@ func (s *Service) handleCacheClearLayer(w http.ResponseWriter, r *http.Request)
 	layer := vars["layer"]
 
 	removed := s.cache.ClearLayer(layer)
+	clearParentTiles(layer)
 
 	return writeJSON(w, "application/json", map[string]interface{}{
 		"status":  "ok",
@@ -51,3 +56,72 @@ func (s *Service) handleCacheClearLayer(w http.ResponseWriter, r *http.Request)
 		"layer":   layer,
 	})
 }
+
+// invalidateRequest is the body of a cache invalidation request
+type invalidateRequest struct {
+	Layer    string          `json:"layer"`
+	Bbox     []float64       `json:"bbox"`     // minx, miny, maxx, maxy in EPSG:4326
+	Geometry json.RawMessage `json:"geometry"` // GeoJSON geometry in EPSG:4326
+	MinZoom  int             `json:"minzoom"`
+	MaxZoom  *int            `json:"maxzoom"`
+}
+
+// handleCacheInvalidate removes the cached tiles of a layer intersecting
+// a geometry or bbox (EPSG:4326) in a zoom range
+func (s *Service) handleCacheInvalidate(w http.ResponseWriter, r *http.Request) *appError {
+	if !s.cache.Enabled() {
+		return appErrorBadRequest(nil, "Cache is disabled")
+	}
+
+	var req invalidateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return appErrorBadRequest(err, fmt.Sprintf("Invalid invalidation request: %v", err))
+	}
+	if req.Layer == "" {
+		return appErrorBadRequest(nil, "Invalidation request requires a layer")
+	}
+	var bbox *data.Extent
+	if req.Bbox != nil {
+		if len(req.Bbox) != 4 {
+			return appErrorBadRequest(nil, "Invalid bbox: expected [minx, miny, maxx, maxy]")
+		}
+		bbox = &data.Extent{Minx: req.Bbox[0], Miny: req.Bbox[1], Maxx: req.Bbox[2], Maxy: req.Bbox[3]}
+	}
+	maxZoom := -1
+	if req.MaxZoom != nil {
+		maxZoom = *req.MaxZoom
+	}
+	area, err := data.NewTileArea(req.Geometry, bbox, req.MinZoom, maxZoom)
+	if err != nil {
+		return appErrorBadRequest(err, fmt.Sprintf("Invalid invalidation request: %v", err))
+	}
+	if catDB, ok := catalogInstance.(*data.CatalogDB); ok {
+		if err := catDB.ProjectTileArea(area); err != nil {
+			return appErrorInternal(err, fmt.Sprintf("Error projecting invalidation area: %v", err))
+		}
+		area.Buffer = catDB.TileAreaBuffer(req.Layer)
+	}
+
+	removed := s.cache.ClearTiles(req.Layer, func(key string) bool {
+		_, tms, z, x, y, ok := parseTileCacheKey(key)
+		// keys which can't be located are removed
+		return !ok || area.Intersects(tms, z, x, y)
+	})
+	clearParentTiles(req.Layer)
+	log.Infof("Invalidated %d tiles for layer %s", removed, req.Layer)
+
+	return writeJSON(w, "application/json", map[string]interface{}{
+		"status":  "ok",
+		"message": fmt.Sprintf("Invalidated %d tiles for layer %s", removed, req.Layer),
+		"removed": removed,
+		"layer":   req.Layer,
+	})
+}
+
+// clearParentTiles discards the parent tiles kept for overzooming the tiles of a layer
+// (of all layers if layer is empty), so that cleared tiles are not cut from stale parents
+func clearParentTiles(layer string) {
+	if catDB, ok := catalogInstance.(*data.CatalogDB); ok {
+		catDB.ClearParentTiles(layer)
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/tobilg/duckdb-tileserver/internal/conf"
+	"github.com/tobilg/duckdb-tileserver/internal/data"
 )
 
 // tileCacheMiddleware wraps the tile handler to check cache first
@@ -25,7 +29,7 @@ func (s *Service) tileCacheMiddleware(next appHandler) appHandler {
 		y := vars["y"]
 
 		// Build cache key
-		cacheKey := fmt.Sprintf("%s:%s:%s:%s", layer, z, x, y)
+		cacheKey := tileCacheKey(layer, vars["tms"], z, x, y, r.URL.Query())
 
 		// Try cache first
 		if cachedTile, found := s.cache.Get(r.Context(), cacheKey); found {
@@ -37,13 +41,7 @@ func (s *Service) tileCacheMiddleware(next appHandler) appHandler {
 			maxAge := conf.Configuration.Cache.BrowserCacheMaxAge
 			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
 
-			if len(cachedTile) == 0 {
-				w.WriteHeader(http.StatusNoContent)
-			} else {
-				w.WriteHeader(http.StatusOK)
-				w.Write(cachedTile)
-			}
-			return nil
+			return writeTile(w, r, cachedTile)
 		}
 
 		// Cache miss - set headers before calling next handler
@@ -52,36 +50,151 @@ func (s *Service) tileCacheMiddleware(next appHandler) appHandler {
 		maxAge := conf.Configuration.Cache.BrowserCacheMaxAge
 		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
 
-		// Capture the response to store it
-		recorder := &responseCapturer{
-			ResponseWriter: w,
-			body:           &bytes.Buffer{},
+		// Concurrent misses of the same tile share a single tile generation.
+		// If the request generating the tile is cancelled, the waiting requests try again.
+		generate := func() *tileResponse {
+			return s.generateTile(next, r, cacheKey)
+		}
+		response, shared := s.tileFlights.do(r.Context(), cacheKey, generate)
+		for shared && response.canceled && r.Context().Err() == nil {
+			response, shared = s.tileFlights.do(r.Context(), cacheKey, generate)
+		}
+		if response.appErr != nil {
+			return response.appErr
+		}
+
+		// the tile is negotiated for each request (the captured headers are those of the generating request)
+		if response.statusCode == http.StatusOK || response.statusCode == http.StatusNoContent {
+			return writeTile(w, r, response.body)
+		}
+		for name, values := range response.header {
+			w.Header()[name] = values
 		}
+		if response.statusCode != 0 {
+			w.WriteHeader(response.statusCode)
+		}
+		w.Write(response.body)
+		return nil
+	}
+}
+
+// generateTile calls the tile handler and stores the tile in cache.
+// The tile is requested compressed, so that the cached tile (and its ETag)
+// does not depend on the encodings accepted by the request which generates it.
+func (s *Service) generateTile(next appHandler, r *http.Request, cacheKey string) *tileResponse {
+	// Capture the response to store it
+	recorder := &responseCapturer{
+		header: make(http.Header),
+		body:   &bytes.Buffer{},
+	}
+
+	// Call original handler without the validators of the request,
+	// so that the tile is generated for the cache even if the client has it
+	tileReq := r.Clone(r.Context())
+	tileReq.Header.Del("If-None-Match")
+	tileReq.Header.Set("Accept-Encoding", "gzip")
+	appErr := next(recorder, tileReq)
+
+	response := &tileResponse{
+		statusCode: recorder.statusCode,
+		header:     recorder.header,
+		body:       recorder.body.Bytes(),
+		appErr:     appErr,
+		canceled:   r.Context().Err() != nil,
+	}
+	if appErr != nil {
+		return response
+	}
+
+	// If successful, store in cache before the flight completes,
+	// so that requests following the flight find the tile in cache
+	if recorder.statusCode == http.StatusOK {
+		s.cache.Set(r.Context(), cacheKey, response.body)
+	}
 
-		// Call original handler
-		appErr := next(recorder, r)
+	// Also cache empty tiles (204 No Content)
+	if recorder.statusCode == http.StatusNoContent {
+		s.cache.Set(r.Context(), cacheKey, []byte{})
+	}
+	return response
+}
 
-		// If successful, store in cache (async to not block response)
-		if appErr == nil && recorder.statusCode == http.StatusOK {
-			go s.cache.Set(r.Context(), cacheKey, recorder.body.Bytes())
+// tileCacheKey builds the cache key of a tile ("layer:z:x:y").
+// Composite layer lists are normalized, so that equivalent requests share an entry.
+// Tiles requested in another tile matrix set than the one of the layers
+// get the tile matrix set appended (e.g. "roads:10:512:384@WorldCRS84Quad").
+// Values of the layer parameters are appended as a sorted query string
+// (e.g. "roads:10:512:384?category=road&year=2020"); other query values are ignored.
+func tileCacheKey(layer string, tmsID string, z, x, y string, query url.Values) string {
+	layerNames := data.SplitLayerNames(layer)
+	key := fmt.Sprintf("%s:%s:%s:%s", strings.Join(layerNames, data.LayerNameSeparator), z, x, y)
+	if tmsID != "" {
+		if tms, err := data.LayerTileMatrixSet(layerNames); err != nil || tms.ID != tmsID {
+			key += "@" + tmsID
 		}
+	}
 
-		// Also cache empty tiles (204 No Content)
-		if appErr == nil && recorder.statusCode == http.StatusNoContent {
-			go s.cache.Set(r.Context(), cacheKey, []byte{})
+	params := url.Values{}
+	for _, name := range layerNames {
+		layerConf := conf.Configuration.LayerConfig(name)
+		if layerConf == nil {
+			continue
+		}
+		for _, param := range layerConf.Parameters {
+			if query.Has(param.Name) {
+				params.Set(param.Name, query.Get(param.Name))
+			}
 		}
+	}
+	if len(params) > 0 {
+		key += "?" + params.Encode()
+	}
+	return key
+}
 
-		return appErr
+// parseTileCacheKey returns the layer names, tile matrix set and tile coordinates of a tile cache key.
+// The tile matrix set is the one of the layers unless the key names another one.
+func parseTileCacheKey(key string) (layerNames []string, tms *data.TileMatrixSet, z, x, y int, ok bool) {
+	key, _, _ = strings.Cut(key, "?")
+	key, tmsID, hasTms := strings.Cut(key, "@")
+	parts := strings.Split(key, ":")
+	if len(parts) != 4 {
+		return nil, nil, 0, 0, 0, false
 	}
+	var err error
+	if z, err = strconv.Atoi(parts[1]); err != nil {
+		return nil, nil, 0, 0, 0, false
+	}
+	if x, err = strconv.Atoi(parts[2]); err != nil {
+		return nil, nil, 0, 0, 0, false
+	}
+	if y, err = strconv.Atoi(parts[3]); err != nil {
+		return nil, nil, 0, 0, 0, false
+	}
+	layerNames = data.SplitLayerNames(parts[0])
+	if hasTms {
+		tms = data.TileMatrixSetByID(tmsID)
+	} else {
+		tms, _ = data.LayerTileMatrixSet(layerNames)
+	}
+	if tms == nil {
+		return nil, nil, 0, 0, 0, false
+	}
+	return layerNames, tms, z, x, y, true
 }
 
-// responseCapturer captures the response body to store in cache
+// responseCapturer captures the response of the tile handler to store it in cache.
+// The response is written by the middleware, for every request waiting for the tile.
 type responseCapturer struct {
-	http.ResponseWriter
+	header     http.Header
 	body       *bytes.Buffer
 	statusCode int
 }
 
+func (rc *responseCapturer) Header() http.Header {
+	return rc.header
+}
+
 func (rc *responseCapturer) Write(b []byte) (int, error) {
 	// If WriteHeader wasn't called explicitly, assume 200 OK
 	if rc.statusCode == 0 {
@@ -89,13 +202,9 @@ func (rc *responseCapturer) Write(b []byte) (int, error) {
 	}
 
 	// Capture body
-	rc.body.Write(b)
-
-	// Write to original response
-	return rc.ResponseWriter.Write(b)
+	return rc.body.Write(b)
 }
 
 func (rc *responseCapturer) WriteHeader(statusCode int) {
 	rc.statusCode = statusCode
-	rc.ResponseWriter.WriteHeader(statusCode)
 }
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/cache"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+	"github.com/tobilg/duckdb-tileserver/internal/data"
+)
+
+// Seed job states
+const (
+	SeedRunning   = "running"
+	SeedCompleted = "completed"
+	SeedCancelled = "cancelled"
+	SeedFailed    = "failed"
+)
+
+// maxFinishedSeedJobs is the number of finished seed jobs kept for status requests
+const maxFinishedSeedJobs = 20
+
+// errSeedRunning is returned when a seed job is started for a layer which is already being seeded
+var errSeedRunning = errors.New("a seed job is already running for the layer")
+
+// SeedStatus reports the progress of a seed job
+type SeedStatus struct {
+	ID       string     `json:"id"`
+	Layer    string     `json:"layer"`
+	MinZoom  int        `json:"minzoom"`
+	MaxZoom  int        `json:"maxzoom"`
+	Bbox     []float64  `json:"bbox,omitempty"`
+	Status   string     `json:"status"`
+	Total    int        `json:"total"`
+	Done     int        `json:"done"`
+	Empty    int        `json:"empty"`
+	Progress float64    `json:"progress"`
+	Error    string     `json:"error,omitempty"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// seedJob generates the tiles of a layer into the tile cache
+type seedJob struct {
+	mutex  sync.Mutex
+	status SeedStatus
+	cancel context.CancelFunc
+}
+
+// seedRequest is the body of a seed request
+type seedRequest struct {
+	Layer   string            `json:"layer"`
+	MinZoom *int              `json:"minzoom"`
+	MaxZoom *int              `json:"maxzoom"`
+	Bbox    []float64         `json:"bbox"`
+	Params  map[string]string `json:"params"`
+	Workers int               `json:"workers"`
+}
+
+// Status returns a snapshot of the job progress
+func (job *seedJob) Status() SeedStatus {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	status := job.status
+	if status.Total > 0 {
+		status.Progress = float64(status.Done) / float64(status.Total) * 100.0
+	}
+	return status
+}
+
+// newSeedJob creates the job of a tile plan
+func newSeedJob(id string, plan *data.TilePlan, opts data.GenerateOptions) *seedJob {
+	job := &seedJob{
+		status: SeedStatus{
+			ID:      id,
+			Layer:   opts.Layer,
+			MinZoom: plan.MinZoom,
+			MaxZoom: plan.MaxZoom,
+			Status:  SeedRunning,
+			Total:   plan.Total,
+			Started: time.Now(),
+		},
+	}
+	if opts.Bounds != nil {
+		job.status.Bbox = []float64{opts.Bounds.Minx, opts.Bounds.Miny, opts.Bounds.Maxx, opts.Bounds.Maxy}
+	}
+	return job
+}
+
+// seedTiles generates the tiles of a plan into the tile cache,
+// under the same cache keys as tile requests for the layer.
+// The job is finished when seedTiles returns.
+func seedTiles(ctx context.Context, catDB *data.CatalogDB, tileCache *cache.TileCache, plan *data.TilePlan, opts data.GenerateOptions, job *seedJob) {
+	query := url.Values{}
+	for name, value := range opts.Params {
+		query.Set(name, value)
+	}
+
+	log.Infof("Seed job %s: seeding %d tiles of layer %s (zoom %d-%d)", job.status.ID, plan.Total, opts.Layer, plan.MinZoom, plan.MaxZoom)
+	err := catDB.GenerateTiles(ctx, plan, func(z, x, y int, tile []byte) error {
+		key := tileCacheKey(opts.Layer, "", strconv.Itoa(z), strconv.Itoa(x), strconv.Itoa(y), query)
+		if tile == nil {
+			tile = []byte{}
+		}
+		// tiles are cached compressed, like the tiles generated for requests
+		gzipTile, err := data.GzipTile(tile)
+		if err != nil {
+			return err
+		}
+		if err := tileCache.Set(ctx, key, gzipTile); err != nil {
+			log.Warnf("Seed job %s: error caching tile %s: %v", job.status.ID, key, err)
+		}
+
+		job.mutex.Lock()
+		job.status.Done++
+		if len(tile) == 0 {
+			job.status.Empty++
+		}
+		done, total := job.status.Done, job.status.Total
+		job.mutex.Unlock()
+		if done%1000 == 0 {
+			log.Infof("Seed job %s: seeded %d/%d tiles (%.1f%%)", job.status.ID, done, total, float64(done)/float64(total)*100.0)
+		}
+		return nil
+	})
+
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	finished := time.Now()
+	job.status.Finished = &finished
+	switch {
+	case err == nil:
+		job.status.Status = SeedCompleted
+		log.Infof("Seed job %s: seeded %d tiles of layer %s in %v", job.status.ID, job.status.Done, opts.Layer, finished.Sub(job.status.Started).Round(time.Millisecond))
+	case ctx.Err() != nil:
+		job.status.Status = SeedCancelled
+		log.Infof("Seed job %s: cancelled after %d/%d tiles", job.status.ID, job.status.Done, job.status.Total)
+	default:
+		job.status.Status = SeedFailed
+		job.status.Error = err.Error()
+		log.Warnf("Seed job %s: failed after %d/%d tiles: %v", job.status.ID, job.status.Done, job.status.Total, err)
+	}
+}
+
+// Seed generates tiles of a layer into the disk tier of the tile cache configured for the service,
+// so that they are available when the service starts. Used by the seed command.
+func Seed(ctx context.Context, catDB *data.CatalogDB, opts data.GenerateOptions) (SeedStatus, error) {
+	if !conf.Configuration.Cache.Enabled || conf.Configuration.Cache.DiskPath == "" {
+		return SeedStatus{}, fmt.Errorf("seeding requires the disk cache tier (Cache.DiskPath)")
+	}
+	tileCache := newTileCache()
+	if !tileCache.HasDisk() {
+		return SeedStatus{}, fmt.Errorf("disk cache %s is not available", conf.Configuration.Cache.DiskPath)
+	}
+
+	plan, err := catDB.PlanTiles(opts)
+	if err != nil {
+		return SeedStatus{}, err
+	}
+	job := newSeedJob("cli", plan, opts)
+	seedTiles(ctx, catDB, tileCache, plan, opts, job)
+	status := job.Status()
+	if status.Error != "" {
+		return status, errors.New(status.Error)
+	}
+	return status, nil
+}
+
+// startSeed starts a background seed job
+func (s *Service) startSeed(catDB *data.CatalogDB, opts data.GenerateOptions) (*seedJob, error) {
+	plan, err := catDB.PlanTiles(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.seedMutex.Lock()
+	defer s.seedMutex.Unlock()
+	if s.seeds == nil {
+		s.seeds = make(map[string]*seedJob)
+	}
+	if running := s.runningSeedLocked(opts.Layer); running != nil {
+		return nil, fmt.Errorf("%w: job %s", errSeedRunning, running.status.ID)
+	}
+	s.seedCount++
+	job := newSeedJob(strconv.Itoa(s.seedCount), plan, opts)
+	// the job outlives the request
+	ctx, cancel := context.WithCancel(context.Background())
+	job.cancel = cancel
+	s.seeds[job.status.ID] = job
+	s.pruneSeedsLocked()
+
+	go func() {
+		defer cancel()
+		seedTiles(ctx, catDB, s.cache, plan, opts, job)
+	}()
+	return job, nil
+}
+
+// seedJobs returns the status of the seed jobs, in start order
+func (s *Service) seedJobs() []SeedStatus {
+	s.seedMutex.Lock()
+	defer s.seedMutex.Unlock()
+	statuses := make([]SeedStatus, 0, len(s.seeds))
+	for _, job := range s.seeds {
+		statuses = append(statuses, job.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Started.Before(statuses[j].Started)
+	})
+	return statuses
+}
+
+// findSeedJob returns a seed job by id
+func (s *Service) findSeedJob(id string) (*seedJob, bool) {
+	s.seedMutex.Lock()
+	defer s.seedMutex.Unlock()
+	job, ok := s.seeds[id]
+	return job, ok
+}
+
+// runningSeedLocked returns the running seed job of a layer, or nil if there is none
+func (s *Service) runningSeedLocked(layer string) *seedJob {
+	for _, job := range s.seeds {
+		if status := job.Status(); status.Layer == layer && status.Finished == nil {
+			return job
+		}
+	}
+	return nil
+}
+
+// pruneSeedsLocked removes the oldest finished jobs beyond maxFinishedSeedJobs
+func (s *Service) pruneSeedsLocked() {
+	var finished []SeedStatus
+	for _, job := range s.seeds {
+		if status := job.Status(); status.Finished != nil {
+			finished = append(finished, status)
+		}
+	}
+	if len(finished) <= maxFinishedSeedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].Finished.Before(*finished[j].Finished)
+	})
+	for _, status := range finished[:len(finished)-maxFinishedSeedJobs] {
+		delete(s.seeds, status.ID)
+	}
+}
+
+// handleCacheSeed starts a background job generating tiles into the cache.
+// The request body gives the layer, zoom range and bbox (EPSG:4326) to seed.
+func (s *Service) handleCacheSeed(w http.ResponseWriter, r *http.Request) *appError {
+	if !s.cache.Enabled() {
+		return appErrorBadRequest(nil, "Cache is disabled")
+	}
+
+	var req seedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return appErrorBadRequest(err, fmt.Sprintf("Invalid seed request: %v", err))
+	}
+	if req.Layer == "" {
+		return appErrorBadRequest(nil, "Seed request requires a layer")
+	}
+	opts := data.GenerateOptions{
+		Layer:   req.Layer,
+		MinZoom: -1,
+		MaxZoom: -1,
+		Params:  req.Params,
+		Workers: req.Workers,
+	}
+	if req.MinZoom != nil {
+		opts.MinZoom = *req.MinZoom
+	}
+	if req.MaxZoom != nil {
+		opts.MaxZoom = *req.MaxZoom
+	}
+	if opts.MinZoom < -1 || opts.MaxZoom < -1 {
+		return appErrorBadRequest(nil, "Invalid zoom range")
+	}
+	if req.Bbox != nil {
+		if len(req.Bbox) != 4 || req.Bbox[0] > req.Bbox[2] || req.Bbox[1] > req.Bbox[3] {
+			return appErrorBadRequest(nil, "Invalid bbox: expected [minx, miny, maxx, maxy]")
+		}
+		opts.Bounds = &data.Extent{Minx: req.Bbox[0], Miny: req.Bbox[1], Maxx: req.Bbox[2], Maxy: req.Bbox[3]}
+	}
+	// seeding must not starve the tile requests
+	maxWorkers := conf.Configuration.Cache.SeedWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = runtime.NumCPU()
+	}
+	if opts.Workers <= 0 || opts.Workers > maxWorkers {
+		opts.Workers = maxWorkers
+	}
+
+	catDB, ok := catalogInstance.(*data.CatalogDB)
+	if !ok {
+		return appErrorInternal(nil, "Invalid catalog type")
+	}
+	job, err := s.startSeed(catDB, opts)
+	if err != nil {
+		if errors.Is(err, data.ErrLayerNotFound) {
+			return appErrorNotFound(err, fmt.Sprintf("Layer not found: %s", req.Layer))
+		}
+		if errors.Is(err, errSeedRunning) {
+			return appErrorMsg(err, fmt.Sprintf("Seed job already running for layer %s", req.Layer), http.StatusConflict)
+		}
+		return appErrorBadRequest(err, fmt.Sprintf("Invalid seed request: %v", err))
+	}
+
+	content, err := json.Marshal(job.Status())
+	if err != nil {
+		return appErrorInternal(err, "Error encoding JSON")
+	}
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.Header().Set("Location", fmt.Sprintf("%s/cache/seed/%s", getBaseURL(r), job.status.ID))
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(content)
+	return nil
+}
+
+// handleCacheSeedJobs lists the seed jobs
+func (s *Service) handleCacheSeedJobs(w http.ResponseWriter, r *http.Request) *appError {
+	return writeJSON(w, ContentTypeJSON, s.seedJobs())
+}
+
+// handleCacheSeedJob returns the progress of a seed job
+func (s *Service) handleCacheSeedJob(w http.ResponseWriter, r *http.Request) *appError {
+	id := mux.Vars(r)["id"]
+	job, ok := s.findSeedJob(id)
+	if !ok {
+		return appErrorNotFoundFmt(nil, "Seed job not found: %v", id)
+	}
+	return writeJSON(w, ContentTypeJSON, job.Status())
+}
+
+// handleCacheSeedCancel cancels a running seed job
+func (s *Service) handleCacheSeedCancel(w http.ResponseWriter, r *http.Request) *appError {
+	id := mux.Vars(r)["id"]
+	job, ok := s.findSeedJob(id)
+	if !ok {
+		return appErrorNotFoundFmt(nil, "Seed job not found: %v", id)
+	}
+	job.cancel()
+
+	return writeJSON(w, ContentTypeJSON, map[string]string{
+		"status":  "ok",
+		"message": fmt.Sprintf("Seed job %s cancelled", id),
+	})
+}
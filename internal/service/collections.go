@@ -0,0 +1,444 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+	"github.com/tobilg/duckdb-tileserver/internal/data"
+)
+
+// OGC API - Features Part 1 constants
+const (
+	CrsCRS84 = "http://www.opengis.net/def/crs/OGC/1.3/CRS84"
+
+	RelSelf        = "self"
+	RelAlternate   = "alternate"
+	RelConformance = "conformance"
+	RelData        = "data"
+	RelItems       = "items"
+	RelCollection  = "collection"
+	RelNext        = "next"
+	RelPrev        = "prev"
+
+	paramLimit      = "limit"
+	paramOffset     = "offset"
+	paramBbox       = "bbox"
+	paramProperties = "properties"
+	paramSortBy     = "sortby"
+	paramFormat     = "f"
+	paramDatetime   = "datetime"
+)
+
+// conformanceClasses lists the OGC API conformance classes implemented by the service
+var conformanceClasses = []string{
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/core",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/geojson",
+	"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/core",
+	"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/tileset",
+	"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/tilesets-list",
+	"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/geodata-tilesets",
+	"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/mvt",
+	"http://www.opengis.net/spec/tms/2.0/conf/tilematrixset",
+	"http://www.opengis.net/spec/tms/2.0/conf/json-tilematrixset",
+}
+
+// itemsQueryParams are the query parameters understood by the items endpoint
+// (besides property filters)
+var itemsQueryParams = map[string]bool{
+	paramLimit:      true,
+	paramOffset:     true,
+	paramBbox:       true,
+	paramProperties: true,
+	paramSortBy:     true,
+	paramFormat:     true,
+	paramDatetime:   true,
+}
+
+// Link is a link in an OGC API response
+type Link struct {
+	Href      string `json:"href"`
+	Rel       string `json:"rel"`
+	Type      string `json:"type,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Templated bool   `json:"templated,omitempty"`
+}
+
+// LandingPage is the JSON landing page of the service
+type LandingPage struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Links       []*Link `json:"links"`
+}
+
+// Conformance lists the conformance classes of the service
+type Conformance struct {
+	ConformsTo []string `json:"conformsTo"`
+}
+
+// CollectionsInfo is the JSON response for the /collections endpoint
+type CollectionsInfo struct {
+	Links       []*Link           `json:"links"`
+	Collections []*CollectionInfo `json:"collections"`
+}
+
+// CollectionInfo describes a feature collection
+type CollectionInfo struct {
+	ID          string            `json:"id"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Extent      *CollectionExtent `json:"extent,omitempty"`
+	ItemType    string            `json:"itemType"`
+	Crs         []string          `json:"crs"`
+	Links       []*Link           `json:"links"`
+}
+
+// CollectionExtent is the spatial extent of a collection
+type CollectionExtent struct {
+	Spatial *SpatialExtent `json:"spatial"`
+}
+
+// SpatialExtent is a bounding box in CRS84
+type SpatialExtent struct {
+	Bbox [][]float64 `json:"bbox"`
+	Crs  string      `json:"crs"`
+}
+
+// FeatureCollection is the GeoJSON response for the items endpoint
+type FeatureCollection struct {
+	Type           string            `json:"type"`
+	Features       []json.RawMessage `json:"features"`
+	NumberReturned int               `json:"numberReturned"`
+	TimeStamp      string            `json:"timeStamp"`
+	Links          []*Link           `json:"links"`
+}
+
+// wantsJSON reports whether a request asks for a JSON representation
+// (via the f parameter or the Accept header)
+func wantsJSON(r *http.Request) bool {
+	switch r.URL.Query().Get(paramFormat) {
+	case "json":
+		return true
+	case "html":
+		return false
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, ContentTypeJSON) && !strings.Contains(accept, "text/html")
+}
+
+// handleLandingPage returns the JSON landing page
+func handleLandingPage(w http.ResponseWriter, r *http.Request) *appError {
+	urlBase := serveURLBase(r)
+	content := &LandingPage{
+		Title:       conf.Configuration.Metadata.Title,
+		Description: conf.Configuration.Metadata.Description,
+		Links: []*Link{
+			{Href: urlPath(urlBase, "?f=json"), Rel: RelSelf, Type: ContentTypeJSON, Title: "This document"},
+			{Href: urlPath(urlBase, ""), Rel: RelAlternate, Type: ContentTypeHTML, Title: "Map viewer"},
+			{Href: urlPath(urlBase, "conformance"), Rel: RelConformance, Type: ContentTypeJSON, Title: "Conformance classes"},
+			{Href: urlPath(urlBase, "collections"), Rel: RelData, Type: ContentTypeJSON, Title: "Feature collections"},
+			{Href: urlPath(urlBase, "tileMatrixSets"), Rel: RelTilingSchemes, Type: ContentTypeJSON, Title: "Tile matrix sets"},
+		},
+	}
+	return writeJSON(w, ContentTypeJSON, content)
+}
+
+// handleConformance returns the conformance classes
+func handleConformance(w http.ResponseWriter, r *http.Request) *appError {
+	return writeJSON(w, ContentTypeJSON, &Conformance{ConformsTo: conformanceClasses})
+}
+
+// handleCollections returns the list of feature collections
+func handleCollections(w http.ResponseWriter, r *http.Request) *appError {
+	log.Debug("Collections request")
+
+	tables, err := catalogInstance.Tables()
+	if err != nil {
+		return appErrorInternal(err, fmt.Sprintf("Error retrieving collections: %v", err))
+	}
+
+	urlBase := serveURLBase(r)
+	content := &CollectionsInfo{
+		Links: []*Link{
+			{Href: urlPath(urlBase, "collections"), Rel: RelSelf, Type: ContentTypeJSON, Title: "This document"},
+		},
+		Collections: make([]*CollectionInfo, 0, len(tables)),
+	}
+	for _, tbl := range tables {
+		content.Collections = append(content.Collections, newCollectionInfo(tbl, urlBase))
+	}
+	return writeJSON(w, ContentTypeJSON, content)
+}
+
+// handleCollection returns the description of a feature collection
+func handleCollection(w http.ResponseWriter, r *http.Request) *appError {
+	name := getRequestVar("id", r)
+	log.Debugf("Collection request: %s", name)
+
+	// the extent is loaded on first request, since it requires a full table scan
+	catalogInstance.TableReload(name)
+	tbl, err := catalogInstance.TableByName(name)
+	if err != nil {
+		return appErrorInternal(err, fmt.Sprintf("Error retrieving collection: %v", err))
+	}
+	if tbl == nil {
+		return appErrorNotFoundFmt(nil, "Collection not found: %v", name)
+	}
+
+	return writeJSON(w, ContentTypeJSON, newCollectionInfo(tbl, serveURLBase(r)))
+}
+
+// handleCollectionItems returns the features of a collection as a GeoJSON FeatureCollection
+func handleCollectionItems(w http.ResponseWriter, r *http.Request) *appError {
+	name := getRequestVar("id", r)
+	log.Debugf("Collection items request: %s", name)
+
+	tbl, err := catalogInstance.TableByName(name)
+	if err != nil {
+		return appErrorInternal(err, fmt.Sprintf("Error retrieving collection: %v", err))
+	}
+	if tbl == nil {
+		return appErrorNotFoundFmt(nil, "Collection not found: %v", name)
+	}
+
+	param, err := parseItemsQuery(r.URL.Query(), tbl)
+	if err != nil {
+		return appErrorBadRequest(err, err.Error())
+	}
+
+	features, err := catalogInstance.TableFeatures(r.Context(), name, param)
+	if err != nil {
+		return appErrorInternal(err, fmt.Sprintf("Error retrieving features: %v", err))
+	}
+
+	content := &FeatureCollection{
+		Type:           "FeatureCollection",
+		Features:       make([]json.RawMessage, 0, len(features)),
+		NumberReturned: len(features),
+		TimeStamp:      time.Now().UTC().Format(time.RFC3339),
+		Links:          itemsLinks(serveURLBase(r), name, r.URL.Query(), param, len(features)),
+	}
+	for _, feature := range features {
+		content.Features = append(content.Features, json.RawMessage(feature))
+	}
+	return writeJSON(w, ContentTypeGeoJSON, content)
+}
+
+// handleCollectionItem returns a single feature of a collection as GeoJSON
+func handleCollectionItem(w http.ResponseWriter, r *http.Request) *appError {
+	name := getRequestVar("id", r)
+	fid := getRequestVar("fid", r)
+	log.Debugf("Collection item request: %s/%s", name, fid)
+
+	tbl, err := catalogInstance.TableByName(name)
+	if err != nil {
+		return appErrorInternal(err, fmt.Sprintf("Error retrieving collection: %v", err))
+	}
+	if tbl == nil {
+		return appErrorNotFoundFmt(nil, "Collection not found: %v", name)
+	}
+
+	param := &data.QueryParam{
+		Crs:       data.SRID_4326,
+		Limit:     1,
+		Columns:   tbl.Columns,
+		Precision: -1,
+	}
+	feature, err := catalogInstance.TableFeature(r.Context(), name, fid, param)
+	if err != nil {
+		return appErrorInternal(err, fmt.Sprintf("Error retrieving feature: %v", err))
+	}
+	if feature == "" {
+		return appErrorNotFoundFmt(nil, "Feature not found: %v", fid)
+	}
+
+	// add the links required for GeoJSON features
+	var content map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(feature), &content); err != nil {
+		return appErrorInternal(err, "Error decoding feature")
+	}
+	urlBase := serveURLBase(r)
+	links, _ := json.Marshal([]*Link{
+		{Href: urlPath(urlBase, "collections/"+url.PathEscape(name)+"/items/"+url.PathEscape(fid)), Rel: RelSelf, Type: ContentTypeGeoJSON, Title: "This document"},
+		{Href: urlPath(urlBase, "collections/"+url.PathEscape(name)), Rel: RelCollection, Type: ContentTypeJSON, Title: "The collection"},
+	})
+	content["links"] = links
+	return writeJSON(w, ContentTypeGeoJSON, content)
+}
+
+// newCollectionInfo creates the description of the collection for a table
+func newCollectionInfo(tbl *data.Table, urlBase string) *CollectionInfo {
+	collectionPath := "collections/" + url.PathEscape(tbl.ID)
+	return &CollectionInfo{
+		ID:          tbl.ID,
+		Title:       tbl.Title,
+		Description: tbl.Description,
+		Extent: &CollectionExtent{
+			Spatial: &SpatialExtent{
+				Bbox: [][]float64{{tbl.Extent.Minx, tbl.Extent.Miny, tbl.Extent.Maxx, tbl.Extent.Maxy}},
+				Crs:  CrsCRS84,
+			},
+		},
+		ItemType: "feature",
+		Crs:      []string{CrsCRS84},
+		Links: []*Link{
+			{Href: urlPath(urlBase, collectionPath), Rel: RelSelf, Type: ContentTypeJSON, Title: "This document"},
+			{Href: urlPath(urlBase, collectionPath+"/items"), Rel: RelItems, Type: ContentTypeGeoJSON, Title: "Features"},
+			{Href: urlPath(urlBase, collectionPath+"/tiles"), Rel: RelTilesetsVector, Type: ContentTypeJSON, Title: "Vector tilesets"},
+		},
+	}
+}
+
+// parseItemsQuery converts the items query parameters to query parameters for the catalog.
+// Query parameters matching a column name are used as property filters.
+func parseItemsQuery(query url.Values, tbl *data.Table) (*data.QueryParam, error) {
+	param := &data.QueryParam{
+		Crs:       data.SRID_4326,
+		Limit:     conf.Configuration.Paging.LimitDefault,
+		BboxCrs:   data.SRID_4326,
+		Columns:   tbl.Columns,
+		Precision: -1,
+	}
+
+	if value := query.Get(paramLimit); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("Invalid limit: %s", value)
+		}
+		param.Limit = limit
+	}
+	if max := conf.Configuration.Paging.LimitMax; max > 0 && param.Limit > max {
+		param.Limit = max
+	}
+
+	if value := query.Get(paramOffset); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("Invalid offset: %s", value)
+		}
+		param.Offset = offset
+	}
+
+	// the collections have no temporal extent, so a datetime filter can't be applied
+	if query.Has(paramDatetime) {
+		return nil, fmt.Errorf("Unsupported query parameter: %s", paramDatetime)
+	}
+
+	if value := query.Get(paramBbox); value != "" {
+		bbox, err := parseBbox(value)
+		if err != nil {
+			return nil, err
+		}
+		param.Bbox = bbox
+	}
+
+	if value := query.Get(paramProperties); value != "" {
+		columns := []string{}
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !hasColumn(tbl, name) {
+				return nil, fmt.Errorf("Invalid property: %s", name)
+			}
+			columns = append(columns, name)
+		}
+		param.Columns = columns
+	}
+
+	if value := query.Get(paramSortBy); value != "" {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			sorting := data.Sorting{Name: strings.TrimLeft(item, "+-"), IsDesc: strings.HasPrefix(item, "-")}
+			if !hasColumn(tbl, sorting.Name) {
+				return nil, fmt.Errorf("Invalid sortby property: %s", sorting.Name)
+			}
+			param.SortBy = append(param.SortBy, sorting)
+		}
+	}
+
+	for name, values := range query {
+		if itemsQueryParams[name] {
+			continue
+		}
+		if !hasColumn(tbl, name) {
+			return nil, fmt.Errorf("Unknown query parameter: %s", name)
+		}
+		param.Filter = append(param.Filter, &data.PropertyFilter{Name: name, Value: values[0]})
+	}
+	return param, nil
+}
+
+// parseBbox parses a bbox parameter in CRS84:
+// minx,miny,maxx,maxy or minx,miny,minz,maxx,maxy,maxz (the heights are ignored)
+func parseBbox(value string) (*data.Extent, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 && len(parts) != 6 {
+		return nil, fmt.Errorf("Invalid bbox: %s", value)
+	}
+	coords := make([]float64, len(parts))
+	for i, part := range parts {
+		coord, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid bbox: %s", value)
+		}
+		coords[i] = coord
+	}
+	if len(coords) == 6 {
+		coords = []float64{coords[0], coords[1], coords[3], coords[4]}
+	}
+	return &data.Extent{Minx: coords[0], Miny: coords[1], Maxx: coords[2], Maxy: coords[3]}, nil
+}
+
+// hasColumn reports whether a table has a property column
+func hasColumn(tbl *data.Table, name string) bool {
+	for _, col := range tbl.Columns {
+		if col == name {
+			return true
+		}
+	}
+	return false
+}
+
+// itemsLinks creates the self and paging links for an items response
+func itemsLinks(urlBase string, name string, query url.Values, param *data.QueryParam, numberReturned int) []*Link {
+	itemsPath := "collections/" + url.PathEscape(name) + "/items"
+	pageURL := func(offset int) string {
+		q := url.Values{}
+		for key, values := range query {
+			q[key] = values
+		}
+		if offset > 0 {
+			q.Set(paramOffset, strconv.Itoa(offset))
+		} else {
+			q.Del(paramOffset)
+		}
+		if encoded := q.Encode(); encoded != "" {
+			return urlPath(urlBase, itemsPath+"?"+encoded)
+		}
+		return urlPath(urlBase, itemsPath)
+	}
+
+	links := []*Link{
+		{Href: pageURL(param.Offset), Rel: RelSelf, Type: ContentTypeGeoJSON, Title: "This document"},
+		{Href: urlPath(urlBase, "collections/"+url.PathEscape(name)), Rel: RelCollection, Type: ContentTypeJSON, Title: "The collection"},
+	}
+	if numberReturned >= param.Limit {
+		links = append(links, &Link{Href: pageURL(param.Offset + param.Limit), Rel: RelNext, Type: ContentTypeGeoJSON, Title: "Next page"})
+	}
+	if param.Offset > 0 {
+		prev := param.Offset - param.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, &Link{Href: pageURL(prev), Rel: RelPrev, Type: ContentTypeGeoJSON, Title: "Previous page"})
+	}
+	return links
+}
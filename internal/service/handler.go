@@ -10,10 +10,11 @@ import (
 )
 
 const (
-	ContentTypeJSON = "application/json"
-	ContentTypeHTML = "text/html; charset=utf-8"
-	ContentTypeMVT  = "application/vnd.mapbox-vector-tile"
-	ContentTypeText = "text/plain"
+	ContentTypeJSON    = "application/json"
+	ContentTypeGeoJSON = "application/geo+json"
+	ContentTypeHTML    = "text/html; charset=utf-8"
+	ContentTypeMVT     = "application/vnd.mapbox-vector-tile"
+	ContentTypeText    = "text/plain"
 )
 
 // initRouter sets up the HTTP routes
@@ -34,6 +35,20 @@ func initRouter(basePath string) *mux.Router {
 	r.Handle("/index.html", appHandler(handleRoot)).Methods("GET")
 	r.Handle("/home.html", appHandler(handleRoot)).Methods("GET")
 
+	// OGC API - Features endpoints
+	r.Handle("/conformance", appHandler(handleConformance)).Methods("GET")
+	r.Handle("/collections", appHandler(handleCollections)).Methods("GET")
+	r.Handle("/collections/{id}", appHandler(handleCollection)).Methods("GET")
+	r.Handle("/collections/{id}/items", appHandler(handleCollectionItems)).Methods("GET")
+	r.Handle("/collections/{id}/items/{fid}", appHandler(handleCollectionItem)).Methods("GET")
+
+	// OGC API - Tiles endpoints
+	r.Handle("/tileMatrixSets", appHandler(handleTileMatrixSets)).Methods("GET")
+	r.Handle("/tileMatrixSets/{tms}", appHandler(handleTileMatrixSet)).Methods("GET")
+	r.Handle("/collections/{layer}/tiles", appHandler(handleCollectionTileSets)).Methods("GET")
+	r.Handle("/collections/{layer}/tiles/{tms}", appHandler(handleCollectionTileSet)).Methods("GET")
+	r.Handle("/collections/{layer}/tiles/{tms}/{z:[0-9]+}/{y:[0-9]+}/{x:[0-9]+}", collectionTilesHandler(serviceInstance.tileCacheMiddleware(appHandler(handleTile)))).Methods("GET")
+
 	// Health check endpoint
 	r.Handle("/health", appHandler(handleHealth)).Methods("GET")
 
@@ -55,6 +70,11 @@ func initRouter(basePath string) *mux.Router {
 		r.Handle("/cache/stats", appHandler(cacheAuthMiddleware(serviceInstance.handleCacheStats))).Methods("GET")
 		r.Handle("/cache/clear", appHandler(cacheAuthMiddleware(serviceInstance.handleCacheClear))).Methods("DELETE")
 		r.Handle("/cache/layer/{layer}", appHandler(cacheAuthMiddleware(serviceInstance.handleCacheClearLayer))).Methods("DELETE")
+		r.Handle("/cache/invalidate", appHandler(cacheAuthMiddleware(serviceInstance.handleCacheInvalidate))).Methods("POST")
+		r.Handle("/cache/seed", appHandler(cacheAuthMiddleware(serviceInstance.handleCacheSeed))).Methods("POST")
+		r.Handle("/cache/seed", appHandler(cacheAuthMiddleware(serviceInstance.handleCacheSeedJobs))).Methods("GET")
+		r.Handle("/cache/seed/{id}", appHandler(cacheAuthMiddleware(serviceInstance.handleCacheSeedJob))).Methods("GET")
+		r.Handle("/cache/seed/{id}", appHandler(cacheAuthMiddleware(serviceInstance.handleCacheSeedCancel))).Methods("DELETE")
 	} else {
 		log.Info("Cache management endpoints disabled")
 	}
@@ -75,8 +95,12 @@ func initRouter(basePath string) *mux.Router {
 	return router
 }
 
-// handleRoot serves the main HTML map viewer
+// handleRoot serves the main HTML map viewer,
+// or the OGC API landing page if JSON is requested
 func handleRoot(w http.ResponseWriter, r *http.Request) *appError {
+	if wantsJSON(r) {
+		return handleLandingPage(w, r)
+	}
 	return serveMapViewer(w, r)
 }
 
@@ -1,11 +1,19 @@
 package service
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/tobilg/duckdb-tileserver/internal/cache"
@@ -94,6 +102,14 @@ func TestHandleTileInvalidCoordinates(t *testing.T) {
 		{"Invalid y", "/tiles/test/10/0/9999.mvt", http.StatusBadRequest},
 		{"Negative x", "/tiles/test/10/-1/0.mvt", http.StatusNotFound}, // Regex pattern doesn't match negative numbers
 		{"Negative y", "/tiles/test/10/0/-1.mvt", http.StatusNotFound}, // Regex pattern doesn't match negative numbers
+		{"Empty layer list", "/tiles/,/10/0/0.mvt", http.StatusBadRequest},
+		{"OGC invalid column", "/collections/mock_a/tiles/WebMercatorQuad/10/0/9999", http.StatusBadRequest},
+		{"OGC invalid row", "/collections/mock_a/tiles/WebMercatorQuad/10/9999/0", http.StatusBadRequest},
+		{"OGC unknown tile matrix set", "/collections/mock_a/tiles/Unknown/10/0/0", http.StatusNotFound},
+		{"OGC unknown collection", "/collections/test/tiles/WebMercatorQuad/10/0/0", http.StatusNotFound},
+		{"OGC unknown collection tilesets", "/collections/test/tiles", http.StatusNotFound},
+		{"OGC unknown collection tileset", "/collections/mock_a,test/tiles/WebMercatorQuad", http.StatusNotFound},
+		{"WorldCRS84Quad invalid row", "/collections/mock_a/tiles/WorldCRS84Quad/0/1/0", http.StatusBadRequest},
 	}
 
 	for _, tt := range tests {
@@ -129,6 +145,25 @@ func TestRouter(t *testing.T) {
 		{"GET", "/tiles/buildings.json", true},
 		{"GET", "/tiles/buildings/10/512/384.mvt", true},
 		{"GET", "/tiles/buildings/10/512/384.pbf", true},
+		{"GET", "/tiles/buildings,roads,water/10/512/384.mvt", true},
+		{"GET", "/tiles/buildings,roads.json", true},
+		{"GET", "/conformance", true},
+		{"GET", "/collections", true},
+		{"GET", "/collections/buildings", true},
+		{"GET", "/collections/buildings/items", true},
+		{"GET", "/collections/buildings/items/42", true},
+		{"GET", "/tileMatrixSets", true},
+		{"GET", "/tileMatrixSets/WebMercatorQuad", true},
+		{"GET", "/collections/buildings/tiles", true},
+		{"GET", "/collections/buildings/tiles/WebMercatorQuad", true},
+		{"GET", "/collections/buildings/tiles/WebMercatorQuad/10/384/512", true},
+		{"GET", "/collections/buildings,roads/tiles/WebMercatorQuad/10/384/512", true},
+		{"GET", "/collections/buildings/tiles/WorldCRS84Quad/10/384/512", true},
+		{"POST", "/cache/invalidate", true},
+		{"POST", "/cache/seed", true},
+		{"GET", "/cache/seed", true},
+		{"GET", "/cache/seed/1", true},
+		{"DELETE", "/cache/seed/1", true},
 		{"POST", "/", false},
 		{"GET", "/invalid", false},
 	}
@@ -155,6 +190,75 @@ func TestRouter(t *testing.T) {
 	}
 }
 
+func TestHandleCacheSeed(t *testing.T) {
+	setupTestCatalog()
+	tileCache, err := cache.NewTileCache(100, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serviceInstance.cache = tileCache
+	defer setupTestCatalog()
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		code   int
+	}{
+		{"Invalid body", "POST", "/cache/seed", "{", http.StatusBadRequest},
+		{"Missing layer", "POST", "/cache/seed", `{"maxzoom": 5}`, http.StatusBadRequest},
+		{"Invalid bbox", "POST", "/cache/seed", `{"layer": "roads", "bbox": [10, 10, 0, 0]}`, http.StatusBadRequest},
+		{"Invalid zoom", "POST", "/cache/seed", `{"layer": "roads", "minzoom": -5}`, http.StatusBadRequest},
+		{"Mock catalog", "POST", "/cache/seed", `{"layer": "roads", "maxzoom": 5}`, http.StatusInternalServerError},
+		{"List jobs", "GET", "/cache/seed", "", http.StatusOK},
+		{"Unknown job", "GET", "/cache/seed/42", "", http.StatusNotFound},
+		{"Cancel unknown job", "DELETE", "/cache/seed/42", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router := initRouter("")
+			router.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.code {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.code)
+			}
+		})
+	}
+}
+
+func TestSeedJobs(t *testing.T) {
+	s := &Service{cache: cache.NewDisabledCache(), seeds: make(map[string]*seedJob)}
+	start := time.Now()
+	for i := 1; i <= maxFinishedSeedJobs+5; i++ {
+		started := start.Add(time.Duration(i) * time.Second)
+		job := &seedJob{status: SeedStatus{ID: strconv.Itoa(i), Status: SeedCompleted, Total: 4, Done: 4, Started: started, Finished: &started}}
+		s.seeds[job.status.ID] = job
+	}
+	running := &seedJob{status: SeedStatus{ID: "running", Status: SeedRunning, Total: 4, Done: 1, Started: start}}
+	s.seeds["running"] = running
+
+	s.pruneSeedsLocked()
+	jobs := s.seedJobs()
+	equals(t, maxFinishedSeedJobs+1, len(jobs), "Jobs kept")
+	equals(t, "running", jobs[0].ID, "Running job kept")
+	equals(t, "6", jobs[1].ID, "Oldest finished job kept")
+	equals(t, 25.0, jobs[0].Progress, "Progress")
+
+	running.status.Layer = "roads"
+	equals(t, running, s.runningSeedLocked("roads"), "Running job of layer")
+	if job := s.runningSeedLocked("buildings"); job != nil {
+		t.Errorf("Expected no running job for buildings, got %s", job.status.ID)
+	}
+}
+
 func TestGetBaseURL(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -219,3 +323,581 @@ func TestFormatTileURL(t *testing.T) {
 		})
 	}
 }
+
+func TestTileCacheKey(t *testing.T) {
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+	conf.Configuration.Layers = []conf.Layer{
+		{
+			Name: "roads",
+			Parameters: []conf.LayerParameter{
+				{Name: "year", Type: "INTEGER"},
+				{Name: "category"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		layer    string
+		tms      string
+		query    string
+		expected string
+	}{
+		{"No parameters", "buildings", "", "", "buildings:10:512:384"},
+		{"Undeclared parameters ignored", "buildings", "", "year=2020", "buildings:10:512:384"},
+		{"Declared parameters sorted", "roads", "", "year=2020&category=road&foo=bar", "roads:10:512:384?category=road&year=2020"},
+		{"Composite layers normalized", "roads, buildings", "", "year=2020", "roads,buildings:10:512:384?year=2020"},
+		{"Layer tile matrix set", "buildings", "WebMercatorQuad", "", "buildings:10:512:384"},
+		{"Other tile matrix set", "roads", "WorldCRS84Quad", "year=2020", "roads:10:512:384@WorldCRS84Quad?year=2020"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := tileCacheKey(tt.layer, tt.tms, "10", "512", "384", query)
+			if key != tt.expected {
+				t.Errorf("Expected cache key %s, got %s", tt.expected, key)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := contentETag([]byte("tile"))
+	equals(t, etag, contentETag([]byte("tile")), "Same content, same tag")
+	if etag == contentETag([]byte("other tile")) {
+		t.Error("Expected different tags for different content")
+	}
+
+	strong := strings.TrimPrefix(etag, "W/")
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		expected    bool
+	}{
+		{"No header", "", false},
+		{"Matching tag", etag, true},
+		{"Strong tag", strong, true},
+		{"Tag list", `"abc", ` + etag, true},
+		{"Any tag", "*", true},
+		{"Other tag", `W/"abc"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/tiles/roads/1/0/0.mvt", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			equals(t, tt.expected, notModified(req, etag), "Not modified")
+		})
+	}
+}
+
+func TestTileCacheMiddlewareETag(t *testing.T) {
+	setupTestCatalog()
+	defer setupTestCatalog()
+
+	tileCache, err := cache.NewTileCache(100, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serviceInstance.cache = tileCache
+	generated := 0
+	handler := serviceInstance.tileCacheMiddleware(func(w http.ResponseWriter, r *http.Request) *appError {
+		generated++
+		return writeTile(w, r, []byte("tile"))
+	})
+	etag := contentETag([]byte("tile"))
+
+	request := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/tiles/roads/1/0/0.mvt", nil)
+		req = mux.SetURLVars(req, map[string]string{"layer": "roads", "z": "1", "x": "0", "y": "0"})
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	// a miss with a matching validator generates and caches the tile
+	rr := request(etag)
+	equals(t, http.StatusNotModified, rr.Code, "Miss status code")
+	equals(t, "MISS", rr.Header().Get("X-Cache"), "Miss")
+	equals(t, etag, rr.Header().Get("ETag"), "Miss ETag")
+	equals(t, 0, rr.Body.Len(), "Miss body")
+
+	rr = request(etag)
+	equals(t, http.StatusNotModified, rr.Code, "Hit status code")
+	equals(t, "HIT", rr.Header().Get("X-Cache"), "Hit")
+	equals(t, 0, rr.Body.Len(), "Hit body")
+
+	rr = request(`W/"outdated"`)
+	equals(t, http.StatusOK, rr.Code, "Outdated status code")
+	equals(t, etag, rr.Header().Get("ETag"), "Hit ETag")
+	equals(t, "tile", rr.Body.String(), "Hit body")
+	equals(t, 1, generated, "Generated tiles")
+}
+
+func TestTileCacheMiddlewareCoalescing(t *testing.T) {
+	setupTestCatalog()
+	defer setupTestCatalog()
+
+	tileCache, err := cache.NewTileCache(100, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serviceInstance.cache = tileCache
+	var generated atomic.Int32
+	release := make(chan struct{})
+	handler := serviceInstance.tileCacheMiddleware(func(w http.ResponseWriter, r *http.Request) *appError {
+		generated.Add(1)
+		<-release
+		return writeTile(w, r, []byte("tile"))
+	})
+
+	const requests = 10
+	responses := make(chan *httptest.ResponseRecorder, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			req := httptest.NewRequest("GET", "/tiles/roads/1/0/0.mvt", nil)
+			req = mux.SetURLVars(req, map[string]string{"layer": "roads", "z": "1", "x": "0", "y": "0"})
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			responses <- rr
+		}()
+	}
+	// wait for the requests to reach the tile generation in progress
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < requests; i++ {
+		rr := <-responses
+		equals(t, http.StatusOK, rr.Code, "Status code")
+		equals(t, "tile", rr.Body.String(), "Tile")
+		equals(t, ContentTypeMVT, rr.Header().Get("Content-Type"), "Content type")
+	}
+	equals(t, int32(1), generated.Load(), "Generated tiles")
+}
+
+func TestTileCacheMiddlewareEncoding(t *testing.T) {
+	setupTestCatalog()
+	defer setupTestCatalog()
+
+	gzipTile, err := data.GzipTile([]byte("tile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		leaderEncoding string
+		waiterEncoding string
+	}{
+		{"gzip leader", "gzip", ""},
+		{"identity leader", "", "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tileCache, err := cache.NewTileCache(100, 10, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			serviceInstance.cache = tileCache
+			started := make(chan struct{})
+			release := make(chan struct{})
+			handler := serviceInstance.tileCacheMiddleware(func(w http.ResponseWriter, r *http.Request) *appError {
+				close(started)
+				<-release
+				return writeTile(w, r, gzipTile)
+			})
+
+			request := func(encoding string, responses chan<- *httptest.ResponseRecorder) {
+				req := httptest.NewRequest("GET", "/tiles/roads/1/0/0.mvt", nil)
+				req = mux.SetURLVars(req, map[string]string{"layer": "roads", "z": "1", "x": "0", "y": "0"})
+				if encoding != "" {
+					req.Header.Set("Accept-Encoding", encoding)
+				}
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				responses <- rr
+			}
+			leader := make(chan *httptest.ResponseRecorder, 1)
+			waiter := make(chan *httptest.ResponseRecorder, 1)
+			go request(tt.leaderEncoding, leader)
+			<-started
+			go request(tt.waiterEncoding, waiter)
+			// wait for the waiting request to reach the tile generation in progress
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+
+			etag := contentETag(gzipTile)
+			for _, response := range []struct {
+				rr       *httptest.ResponseRecorder
+				encoding string
+			}{{<-leader, tt.leaderEncoding}, {<-waiter, tt.waiterEncoding}} {
+				equals(t, http.StatusOK, response.rr.Code, "Status code")
+				equals(t, etag, response.rr.Header().Get("ETag"), "ETag")
+				equals(t, response.encoding, response.rr.Header().Get("Content-Encoding"), "Content-Encoding")
+				if response.encoding == "gzip" {
+					equals(t, gzipTile, response.rr.Body.Bytes(), "Compressed tile")
+				} else {
+					equals(t, "tile", response.rr.Body.String(), "Decompressed tile")
+				}
+			}
+			cached, _ := tileCache.Get(context.Background(), "roads:1:0:0")
+			equals(t, gzipTile, cached, "Cached tile")
+		})
+	}
+}
+
+func TestTileFlightsPanic(t *testing.T) {
+	var flights tileFlights
+	started := make(chan struct{})
+	release := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			panicked <- recover()
+		}()
+		flights.do(context.Background(), "roads:1:0:0", func() *tileResponse {
+			close(started)
+			<-release
+			panic("generation failed")
+		})
+	}()
+	<-started
+
+	waiter := make(chan *tileResponse, 1)
+	go func() {
+		response, shared := flights.do(context.Background(), "roads:1:0:0", func() *tileResponse {
+			return &tileResponse{statusCode: http.StatusOK}
+		})
+		equals(t, true, shared, "Shared response")
+		waiter <- response
+	}()
+	// wait for the request to wait for the generation in progress
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	equals(t, "generation failed", <-panicked, "Panic propagated")
+	response := <-waiter
+	if response == nil || response.appErr == nil {
+		t.Fatalf("Expected error response, got %+v", response)
+	}
+	equals(t, http.StatusInternalServerError, response.appErr.Code, "Status code")
+}
+
+func TestWriteTileCompressed(t *testing.T) {
+	gzipTile, err := data.GzipTile([]byte("tile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		encoding       string
+		body           []byte
+	}{
+		{"Gzip accepted", "gzip, deflate, br", "gzip", gzipTile},
+		{"Gzip not accepted", "", "", []byte("tile")},
+		{"Gzip refused", "gzip;q=0", "", []byte("tile")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/tiles/roads/1/0/0.mvt", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			gzipHandler(appHandler(func(w http.ResponseWriter, r *http.Request) *appError {
+				return writeTile(w, r, gzipTile)
+			})).ServeHTTP(rr, req)
+
+			equals(t, http.StatusOK, rr.Code, "Status code")
+			equals(t, tt.encoding, rr.Header().Get("Content-Encoding"), "Content encoding")
+			equals(t, tt.body, rr.Body.Bytes(), "Tile")
+			equals(t, ContentTypeMVT, rr.Header().Get("Content-Type"), "Content type")
+		})
+	}
+}
+
+func TestGzipHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/layers", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	gzipHandler(appHandler(func(w http.ResponseWriter, r *http.Request) *appError {
+		return writeText(w, ContentTypeText, []byte("layers"))
+	})).ServeHTTP(rr, req)
+
+	equals(t, "gzip", rr.Header().Get("Content-Encoding"), "Content encoding")
+	equals(t, "Accept-Encoding", rr.Header().Get("Vary"), "Vary")
+	body, err := data.GunzipTile(rr.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	equals(t, "layers", string(body), "Decompressed body")
+}
+
+func TestParseTileCacheKey(t *testing.T) {
+	layerNames, tms, z, x, y, ok := parseTileCacheKey("roads,water:10:536:358?year=2020")
+	equals(t, true, ok, "Valid key")
+	equals(t, []string{"roads", "water"}, layerNames, "Layer names")
+	equals(t, data.TileMatrixSetWebMercatorQuad, tms.ID, "Layer tile matrix set")
+	equals(t, []int{10, 536, 358}, []int{z, x, y}, "Tile coordinates")
+
+	_, tms, _, _, _, ok = parseTileCacheKey("roads:10:1072:242@WorldCRS84Quad?year=2020")
+	equals(t, true, ok, "Valid key with tile matrix set")
+	equals(t, data.TileMatrixSetWorldCRS84Quad, tms.ID, "Tile matrix set")
+
+	for _, key := range []string{"roads:10:536", "roads:z:536:358", "roads:10:536:358@Unknown"} {
+		if _, _, _, _, _, ok := parseTileCacheKey(key); ok {
+			t.Errorf("Expected invalid key: %s", key)
+		}
+	}
+}
+
+func TestHandleCacheInvalidate(t *testing.T) {
+	setupTestCatalog()
+	defer setupTestCatalog()
+
+	tests := []struct {
+		name    string
+		body    string
+		code    int
+		removed []string
+	}{
+		{"Missing layer", `{"bbox": [8.5, 47.3, 8.6, 47.4]}`, http.StatusBadRequest, nil},
+		{"Missing area", `{"layer": "roads"}`, http.StatusBadRequest, nil},
+		{"Invalid bbox", `{"layer": "roads", "bbox": [8.5, 47.3]}`, http.StatusBadRequest, nil},
+		{"Bbox", `{"layer": "roads", "bbox": [8.5, 47.3, 8.6, 47.4]}`, http.StatusOK,
+			[]string{"roads:10:536:358", "roads,water:10:536:358", "roads:10:1072:242@WorldCRS84Quad"}},
+		{"Zoom range", `{"layer": "roads", "bbox": [8.5, 47.3, 8.6, 47.4], "minzoom": 11}`, http.StatusOK, nil},
+		{"Geometry", `{"layer": "roads", "geometry": {"type": "Point", "coordinates": [8.55, 47.37]}, "maxzoom": 10}`, http.StatusOK,
+			[]string{"roads:10:536:358", "roads,water:10:536:358", "roads:10:1072:242@WorldCRS84Quad"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tileCache, err := cache.NewTileCache(100, 10, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			serviceInstance.cache = tileCache
+			keys := []string{"roads:10:536:358", "roads:10:537:358", "roads,water:10:536:358", "water:10:536:358", "roads:10:1072:242@WorldCRS84Quad"}
+			for _, key := range keys {
+				tileCache.Set(context.Background(), key, []byte("tile"))
+			}
+
+			req, err := http.NewRequest("POST", "/cache/invalidate", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			initRouter("").ServeHTTP(rr, req)
+
+			equals(t, tt.code, rr.Code, "Status code")
+			for _, key := range keys {
+				_, found := tileCache.Get(context.Background(), key)
+				equals(t, !slices.Contains(tt.removed, key), found, "Cached tile "+key)
+			}
+		})
+	}
+}
+
+func TestHandleRootLandingPage(t *testing.T) {
+	setupTestCatalog()
+
+	req := httptest.NewRequest("GET", "/?f=json", nil)
+	rr := httptest.NewRecorder()
+	initRouter("").ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var response LandingPage
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse landing page: %v", err)
+	}
+	rels := map[string]bool{}
+	for _, link := range response.Links {
+		rels[link.Rel] = true
+	}
+	for _, rel := range []string{RelSelf, RelConformance, RelData} {
+		if !rels[rel] {
+			t.Errorf("Expected landing page link with rel %s", rel)
+		}
+	}
+}
+
+func TestHandleCollections(t *testing.T) {
+	setupTestCatalog()
+
+	req := httptest.NewRequest("GET", "/collections", nil)
+	rr := httptest.NewRecorder()
+	initRouter("").ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var response CollectionsInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse collections: %v", err)
+	}
+	if len(response.Collections) != 3 {
+		t.Fatalf("Expected 3 collections, got %d", len(response.Collections))
+	}
+	collection := response.Collections[0]
+	if collection.ID != "mock_a" || collection.ItemType != "feature" {
+		t.Errorf("Unexpected collection: %+v", collection)
+	}
+	equals(t, []float64{-120, 40, -74, 50}, collection.Extent.Spatial.Bbox[0], "Collection bbox")
+}
+
+func TestHandleCollectionItems(t *testing.T) {
+	setupTestCatalog()
+	originalConfig := conf.Configuration
+	defer func() {
+		conf.Configuration = originalConfig
+	}()
+	conf.Configuration.Paging.LimitDefault = 10
+	conf.Configuration.Paging.LimitMax = 50
+
+	tests := []struct {
+		name     string
+		url      string
+		code     int
+		returned int
+		hasNext  bool
+	}{
+		{"Default limit", "/collections/mock_b/items", http.StatusOK, 10, true},
+		{"Limit and offset", "/collections/mock_b/items?limit=20&offset=90", http.StatusOK, 10, false},
+		{"Limit clamped", "/collections/mock_c/items?limit=5000", http.StatusOK, 50, true},
+		{"Property filter", "/collections/mock_a/items?prop_d=2", http.StatusOK, 1, false},
+		{"Properties", "/collections/mock_a/items?properties=prop_a,prop_b", http.StatusOK, 9, false},
+		{"Invalid limit", "/collections/mock_a/items?limit=-1", http.StatusBadRequest, 0, false},
+		{"Bbox with heights", "/collections/mock_a/items?bbox=-180,-90,0,180,90,100", http.StatusOK, 9, false},
+		{"Invalid bbox", "/collections/mock_a/items?bbox=1,2,3", http.StatusBadRequest, 0, false},
+		{"Unsupported datetime", "/collections/mock_a/items?datetime=2020-01-01T00:00:00Z", http.StatusBadRequest, 0, false},
+		{"Invalid property", "/collections/mock_a/items?properties=unknown", http.StatusBadRequest, 0, false},
+		{"Unknown parameter", "/collections/mock_a/items?foo=bar", http.StatusBadRequest, 0, false},
+		{"Unknown collection", "/collections/unknown/items", http.StatusNotFound, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			rr := httptest.NewRecorder()
+			initRouter("").ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.code {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, tt.code)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			equals(t, ContentTypeGeoJSON, rr.Header().Get("Content-Type"), "Content-Type")
+
+			var response FeatureCollection
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse feature collection: %v", err)
+			}
+			equals(t, "FeatureCollection", response.Type, "Type")
+			equals(t, tt.returned, len(response.Features), "Number of features")
+			equals(t, tt.returned, response.NumberReturned, "numberReturned")
+
+			hasNext := false
+			for _, link := range response.Links {
+				if link.Rel == RelNext {
+					hasNext = true
+				}
+			}
+			equals(t, tt.hasNext, hasNext, "Next link")
+		})
+	}
+}
+
+func TestParseBbox(t *testing.T) {
+	bbox, err := parseBbox("-10,40,-5,45")
+	equals(t, nil, err, "Error")
+	equals(t, &data.Extent{Minx: -10, Miny: 40, Maxx: -5, Maxy: 45}, bbox, "2D bbox")
+
+	bbox, err = parseBbox("-10,40,0,-5,45,100")
+	equals(t, nil, err, "Error")
+	equals(t, &data.Extent{Minx: -10, Miny: 40, Maxx: -5, Maxy: 45}, bbox, "3D bbox")
+
+	_, err = parseBbox("-10,40,0,-5,45")
+	equals(t, true, err != nil, "5 values")
+}
+
+func TestHandleCollectionItem(t *testing.T) {
+	setupTestCatalog()
+
+	req := httptest.NewRequest("GET", "/collections/mock_a/items/1", nil)
+	rr := httptest.NewRecorder()
+	initRouter("").ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var feature map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &feature); err != nil {
+		t.Fatalf("Failed to parse feature: %v", err)
+	}
+	equals(t, "Feature", feature["type"], "Type")
+	if _, ok := feature["links"]; !ok {
+		t.Errorf("Expected feature links")
+	}
+
+	req = httptest.NewRequest("GET", "/collections/mock_a/items/999", nil)
+	rr = httptest.NewRecorder()
+	initRouter("").ServeHTTP(rr, req)
+	equals(t, http.StatusNotFound, rr.Code, "Unknown feature status")
+}
+
+func TestHandleTileMatrixSets(t *testing.T) {
+	setupTestCatalog()
+	router := initRouter("")
+
+	req := httptest.NewRequest("GET", "/tileMatrixSets", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	equals(t, http.StatusOK, rr.Code, "List status")
+
+	var list TileMatrixSetsInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to parse tile matrix sets: %v", err)
+	}
+	equals(t, 2, len(list.TileMatrixSets), "Number of tile matrix sets")
+	equals(t, "WebMercatorQuad", list.TileMatrixSets[0].ID, "Tile matrix set id")
+
+	req = httptest.NewRequest("GET", "/tileMatrixSets/WebMercatorQuad", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	equals(t, http.StatusOK, rr.Code, "Definition status")
+
+	var tms data.TileMatrixSet
+	if err := json.Unmarshal(rr.Body.Bytes(), &tms); err != nil {
+		t.Fatalf("Failed to parse tile matrix set: %v", err)
+	}
+	equals(t, "0", tms.TileMatrices[0].ID, "First tile matrix")
+	equals(t, 1024, tms.TileMatrices[10].MatrixWidth, "Matrix width at zoom 10")
+
+	req = httptest.NewRequest("GET", "/tileMatrixSets/Unknown", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	equals(t, http.StatusNotFound, rr.Code, "Unknown tile matrix set status")
+}
+
+// equals fails the test if exp is not equal to act
+func equals(tb testing.TB, exp, act interface{}, msg string) {
+	tb.Helper()
+	if !reflect.DeepEqual(exp, act) {
+		tb.Fatalf("%s - expected: %#v; got: %#v", msg, exp, act)
+	}
+}
@@ -33,5 +33,5 @@ func handleLayers(w http.ResponseWriter, r *http.Request) *appError {
 		Layers: layers,
 	}
 
-	return writeJSON(w, ContentTypeJSON, response)
+	return writeConditionalJSON(w, r, ContentTypeJSON, response)
 }
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/gorilla/handlers"
@@ -26,7 +28,12 @@ var serviceInstance *Service
 
 // Service holds references to persistent objects
 type Service struct {
-	cache *cache.TileCache
+	cache       *cache.TileCache
+	tileFlights tileFlights // Tile generations in progress, shared by concurrent requests
+
+	seeds     map[string]*seedJob // Cache seed jobs by id
+	seedCount int
+	seedMutex sync.Mutex
 }
 
 // logCacheStats periodically logs cache statistics
@@ -48,6 +55,21 @@ func (s *Service) logCacheStats() {
 			stats.MemoryBytes/1024/1024,
 			stats.Evictions,
 		)
+		if stats.DiskSize > 0 {
+			log.Infof("Disk cache stats: hits=%d items=%d size=%dMB evictions=%d",
+				stats.DiskHits,
+				stats.DiskSize,
+				stats.DiskBytes/1024/1024,
+				stats.DiskEvictions,
+			)
+		}
+	}
+}
+
+// invalidateLayers removes the cached tiles of layers whose data changed
+func (s *Service) invalidateLayers(layerNames []string) {
+	for _, name := range layerNames {
+		s.cache.ClearLayer(name)
 	}
 }
 
@@ -82,7 +104,7 @@ func createServers() {
 	corsHeaders := handlers.AllowedHeaders([]string{"Content-Type", "Accept", "Authorization", "X-Requested-With", "X-API-Key"})
 	corsMethods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD"})
 	corsHandler := handlers.CORS(corsOpt, corsHeaders, corsMethods)(router)
-	compressHandler := handlers.CompressHandler(corsHandler)
+	compressHandler := gzipHandler(corsHandler)
 
 	// Use a TimeoutHandler to ensure a request does not run past the WriteTimeout duration.
 	// This provides a context that allows cancellation to be propagated
@@ -114,18 +136,28 @@ func createServers() {
 	}
 }
 
-// Serve starts the web service
-func Serve(catalog data.Catalog) {
-	confServ := conf.Configuration.Server
-	catalogInstance = catalog
-
-	// Initialize tile cache
+// newTileCache creates the tile cache from configuration
+func newTileCache() *cache.TileCache {
 	var tileCache *cache.TileCache
 	if conf.Configuration.Cache.Enabled {
+		var diskCache *cache.DiskCache
+		if conf.Configuration.Cache.DiskPath != "" {
+			var err error
+			diskCache, err = cache.NewDiskCache(
+				conf.Configuration.Cache.DiskPath,
+				conf.Configuration.Cache.DiskMaxSizeMB,
+			)
+			if err != nil {
+				log.Warnf("Failed to initialize disk cache: %v (continuing with memory cache only)", err)
+				diskCache = nil
+			}
+		}
+
 		var err error
 		tileCache, err = cache.NewTileCache(
 			conf.Configuration.Cache.MaxItems,
 			conf.Configuration.Cache.MaxMemoryMB,
+			diskCache,
 		)
 		if err != nil {
 			log.Warnf("Failed to initialize cache: %v (continuing without cache)", err)
@@ -135,6 +167,16 @@ func Serve(catalog data.Catalog) {
 		log.Info("Tile cache disabled by configuration")
 		tileCache = cache.NewDisabledCache()
 	}
+	return tileCache
+}
+
+// Serve starts the web service
+func Serve(catalog data.Catalog) {
+	confServ := conf.Configuration.Server
+	catalogInstance = catalog
+
+	// Initialize tile cache
+	tileCache := newTileCache()
 
 	// Create service instance
 	svc := &Service{
@@ -149,6 +191,17 @@ func Serve(catalog data.Catalog) {
 	// Store service instance globally for handlers to access
 	serviceInstance = svc
 
+	// Invalidate cached tiles when the layer data changes.
+	// The tiles of the disk tier are checked against the data before serving requests.
+	if catDB, ok := catalog.(*data.CatalogDB); ok && conf.Configuration.Cache.ChangePollSec > 0 {
+		interval := time.Duration(conf.Configuration.Cache.ChangePollSec) * time.Second
+		markersPath := ""
+		if tileCache.Enabled() && conf.Configuration.Cache.DiskPath != "" {
+			markersPath = filepath.Join(conf.Configuration.Cache.DiskPath, data.ChangeMarkersFile)
+		}
+		catDB.WatchChanges(context.Background(), interval, markersPath, svc.invalidateLayers)
+	}
+
 	createServers()
 
 	log.Infof("====  Service: %s  ====\n", conf.Configuration.Metadata.Title)
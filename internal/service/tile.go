@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,7 +11,9 @@ import (
 	"github.com/tobilg/duckdb-tileserver/internal/data"
 )
 
-// handleTile serves MVT tiles for a given layer and tile coordinates
+// handleTile serves MVT tiles for a given layer and tile coordinates.
+// Several comma-separated layers (e.g. /tiles/roads,water/{z}/{x}/{y}.mvt)
+// are served as a single composite tile.
 func handleTile(w http.ResponseWriter, r *http.Request) *appError {
 	vars := mux.Vars(r)
 	layer := vars["layer"]
@@ -34,21 +37,33 @@ func handleTile(w http.ResponseWriter, r *http.Request) *appError {
 		return appErrorBadRequest(err, fmt.Sprintf("Invalid y coordinate: %s", yStr))
 	}
 
+	layerNames := data.SplitLayerNames(layer)
+	if len(layerNames) == 0 {
+		return appErrorBadRequest(nil, fmt.Sprintf("Invalid layer: %s", layer))
+	}
+
+	// The OGC API tile route names the tile matrix set,
+	// otherwise the tile matrix set configured for the layers is used
+	tms, appErr := tileMatrixSet(vars["tms"], layerNames)
+	if appErr != nil {
+		return appErr
+	}
+
 	// Validate tile coordinates
-	if z < 0 || z > 30 {
+	tm := tms.TileMatrix(z)
+	if tm == nil {
 		return appErrorBadRequest(nil, fmt.Sprintf("Zoom level out of range: %d", z))
 	}
 
-	maxCoord := 1 << uint(z) // 2^z
-	if x < 0 || x >= maxCoord {
-		return appErrorBadRequest(nil, fmt.Sprintf("X coordinate out of range: %d (max: %d)", x, maxCoord-1))
+	if x < 0 || x >= tm.MatrixWidth {
+		return appErrorBadRequest(nil, fmt.Sprintf("X coordinate out of range: %d (max: %d)", x, tm.MatrixWidth-1))
 	}
 
-	if y < 0 || y >= maxCoord {
-		return appErrorBadRequest(nil, fmt.Sprintf("Y coordinate out of range: %d (max: %d)", y, maxCoord-1))
+	if y < 0 || y >= tm.MatrixHeight {
+		return appErrorBadRequest(nil, fmt.Sprintf("Y coordinate out of range: %d (max: %d)", y, tm.MatrixHeight-1))
 	}
 
-	log.Debugf("Tile request: layer=%s z=%d x=%d y=%d", layer, z, x, y)
+	log.Debugf("Tile request: layer=%s tms=%s z=%d x=%d y=%d", layer, tms.ID, z, x, y)
 
 	// Get catalog instance (cast to access tile methods)
 	catDB, ok := catalogInstance.(*data.CatalogDB)
@@ -56,26 +71,63 @@ func handleTile(w http.ResponseWriter, r *http.Request) *appError {
 		return appErrorInternal(nil, "Invalid catalog type")
 	}
 
-	// Generate the tile
-	tileData, err := catDB.GenerateTile(r.Context(), layer, z, x, y)
+	// Layer parameter values are provided in the query string
+	params := tileParams(r)
+
+	// Generate the tile (compressed, so that it is cached and served without compressing it again)
+	tileData, err := catDB.GenerateGzipTile(r.Context(), layerNames, tms, z, x, y, params)
 	if err != nil {
-		if err.Error() == fmt.Sprintf("layer not found: %s", layer) {
+		if errors.Is(err, data.ErrLayerNotFound) {
 			return appErrorNotFound(err, fmt.Sprintf("Layer not found: %s", layer))
 		}
+		if errors.Is(err, data.ErrInvalidParameter) {
+			return appErrorBadRequest(err, err.Error())
+		}
+		if errors.Is(err, data.ErrTileMatrixSetMismatch) {
+			// e.g. archive layers requested in another tile matrix set
+			return appErrorNotFound(err, err.Error())
+		}
 		return appErrorInternal(err, fmt.Sprintf("Error generating tile: %v", err))
 	}
 
+	return writeTile(w, r, tileData)
+}
+
+// writeTile writes a tile response with an ETag validator.
+// Empty tiles are returned as 204 No Content, and tiles matching
+// the If-None-Match header of the request as 304 Not Modified.
+// Tiles compressed with gzip are sent as is to clients accepting gzip,
+// and decompressed for other clients.
+func writeTile(w http.ResponseWriter, r *http.Request, tileData []byte) *appError {
+	etag := contentETag(tileData)
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
 	// Return empty tile as 204 No Content if there's no data
 	if len(tileData) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return nil
 	}
 
+	if data.IsGzipTile(tileData) {
+		if acceptsGzip(r) {
+			w.Header().Set("Content-Encoding", "gzip")
+		} else {
+			var err error
+			if tileData, err = data.GunzipTile(tileData); err != nil {
+				return appErrorInternal(err, "Error decompressing tile data")
+			}
+		}
+	}
+
 	// Write the tile data
 	w.Header().Set("Content-Type", ContentTypeMVT)
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(tileData)
+	_, err := w.Write(tileData)
 	if err != nil {
 		return appErrorInternal(err, "Error writing tile data")
 	}
@@ -83,6 +135,35 @@ func handleTile(w http.ResponseWriter, r *http.Request) *appError {
 	return nil
 }
 
+// tileMatrixSet returns the tile matrix set with the given id,
+// or the tile matrix set of the layers if id is empty
+func tileMatrixSet(id string, layerNames []string) (*data.TileMatrixSet, *appError) {
+	if id != "" {
+		tms := data.TileMatrixSetByID(id)
+		if tms == nil {
+			return nil, appErrorNotFoundFmt(nil, "Tile matrix set not found: %v", id)
+		}
+		return tms, nil
+	}
+	tms, err := data.LayerTileMatrixSet(layerNames)
+	if err != nil {
+		return nil, appErrorBadRequest(err, err.Error())
+	}
+	return tms, nil
+}
+
+// tileParams returns the tile request query string values
+// (the first value of each query parameter)
+func tileParams(r *http.Request) map[string]string {
+	params := make(map[string]string)
+	for name, values := range r.URL.Query() {
+		if len(values) > 0 {
+			params[name] = values[0]
+		}
+	}
+	return params
+}
+
 // handleTileJSON serves TileJSON metadata for a layer
 func handleTileJSON(w http.ResponseWriter, r *http.Request) *appError {
 	vars := mux.Vars(r)
@@ -102,12 +183,15 @@ func handleTileJSON(w http.ResponseWriter, r *http.Request) *appError {
 	// Generate TileJSON
 	tileJSON, err := catDB.GetTileJSON(layer, baseURL)
 	if err != nil {
-		if err.Error() == fmt.Sprintf("layer not found: %s", layer) {
+		if errors.Is(err, data.ErrLayerNotFound) {
 			return appErrorNotFound(err, fmt.Sprintf("Layer not found: %s", layer))
 		}
+		if errors.Is(err, data.ErrTileMatrixSetMismatch) {
+			return appErrorBadRequest(err, err.Error())
+		}
 		return appErrorInternal(err, fmt.Sprintf("Error generating TileJSON: %v", err))
 	}
 
 	// Return JSON response
-	return writeJSON(w, ContentTypeJSON, tileJSON)
+	return writeConditionalJSON(w, r, ContentTypeJSON, tileJSON)
 }
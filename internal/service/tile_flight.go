@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// tileResponse is the captured response of the tile handler
+type tileResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	appErr     *appError
+	// canceled is set if the request generating the tile was cancelled,
+	// so that the response does not apply to the requests waiting for it
+	canceled bool
+}
+
+// tileFlight is a tile generation in progress
+type tileFlight struct {
+	done     chan struct{}
+	response *tileResponse
+}
+
+// tileFlights coalesces concurrent requests for the same tile,
+// so that only one of them generates the tile and the others wait for its response
+type tileFlights struct {
+	mutex   sync.Mutex
+	flights map[string]*tileFlight
+}
+
+// do calls generate for the tile with the given key, unless the tile is already being generated.
+// In that case it waits for the response of the running generation, and shared is true.
+// If generate panics, the waiting requests get an error response, and the panic is propagated.
+func (tf *tileFlights) do(ctx context.Context, key string, generate func() *tileResponse) (response *tileResponse, shared bool) {
+	tf.mutex.Lock()
+	if tf.flights == nil {
+		tf.flights = make(map[string]*tileFlight)
+	}
+	if flight, ok := tf.flights[key]; ok {
+		tf.mutex.Unlock()
+		log.Debugf("Waiting for tile generation in progress: %s", key)
+		select {
+		case <-flight.done:
+			return flight.response, true
+		case <-ctx.Done():
+			return &tileResponse{appErr: appErrorInternal(ctx.Err(), "Request cancelled")}, true
+		}
+	}
+	flight := &tileFlight{done: make(chan struct{})}
+	tf.flights[key] = flight
+	tf.mutex.Unlock()
+
+	defer func() {
+		r := recover()
+		if r != nil {
+			flight.response = &tileResponse{appErr: appErrorInternal(fmt.Errorf("panic: %v", r), "Error generating tile")}
+		}
+		tf.mutex.Lock()
+		delete(tf.flights, key)
+		tf.mutex.Unlock()
+		close(flight.done)
+		if r != nil {
+			panic(r)
+		}
+	}()
+	flight.response = generate()
+	return flight.response, false
+}
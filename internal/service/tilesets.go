@@ -0,0 +1,247 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/data"
+)
+
+// OGC API - Tiles link relations
+const (
+	RelTilingScheme   = "http://www.opengis.net/def/rel/ogc/1.0/tiling-scheme"
+	RelTilingSchemes  = "http://www.opengis.net/def/rel/ogc/1.0/tiling-schemes"
+	RelTilesetsVector = "http://www.opengis.net/def/rel/ogc/1.0/tilesets-vector"
+	RelItem           = "item"
+)
+
+// TileMatrixSetsInfo is the JSON response for the /tileMatrixSets endpoint
+type TileMatrixSetsInfo struct {
+	TileMatrixSets []*TileMatrixSetRef `json:"tileMatrixSets"`
+}
+
+// TileMatrixSetRef is a reference to a tile matrix set
+type TileMatrixSetRef struct {
+	ID    string  `json:"id"`
+	Title string  `json:"title,omitempty"`
+	URI   string  `json:"uri,omitempty"`
+	Links []*Link `json:"links"`
+}
+
+// TileSetsInfo is the JSON response listing the tilesets of a collection
+type TileSetsInfo struct {
+	Links    []*Link    `json:"links"`
+	TileSets []*TileSet `json:"tilesets"`
+}
+
+// TileSet describes the vector tiles of a collection in a tile matrix set
+type TileSet struct {
+	Title               string                   `json:"title,omitempty"`
+	Description         string                   `json:"description,omitempty"`
+	DataType            string                   `json:"dataType"`
+	Crs                 string                   `json:"crs"`
+	TileMatrixSetURI    string                   `json:"tileMatrixSetURI,omitempty"`
+	TileMatrixSetLimits []*data.TileMatrixLimits `json:"tileMatrixSetLimits,omitempty"`
+	Layers              []*TileSetLayer          `json:"layers,omitempty"`
+	Links               []*Link                  `json:"links"`
+}
+
+// TileSetLayer describes a layer contained in the tiles of a tileset
+type TileSetLayer struct {
+	ID            string `json:"id"`
+	Title         string `json:"title,omitempty"`
+	Description   string `json:"description,omitempty"`
+	DataType      string `json:"dataType"`
+	MinTileMatrix string `json:"minTileMatrix"`
+	MaxTileMatrix string `json:"maxTileMatrix"`
+}
+
+// handleTileMatrixSets returns the list of supported tile matrix sets
+func handleTileMatrixSets(w http.ResponseWriter, r *http.Request) *appError {
+	urlBase := serveURLBase(r)
+	content := &TileMatrixSetsInfo{}
+	for _, tms := range data.TileMatrixSets() {
+		content.TileMatrixSets = append(content.TileMatrixSets, &TileMatrixSetRef{
+			ID:    tms.ID,
+			Title: tms.Title,
+			URI:   tms.URI,
+			Links: []*Link{
+				{Href: urlPath(urlBase, "tileMatrixSets/"+tms.ID), Rel: RelTilingScheme, Type: ContentTypeJSON, Title: tms.Title},
+			},
+		})
+	}
+	return writeJSON(w, ContentTypeJSON, content)
+}
+
+// handleTileMatrixSet returns the definition of a tile matrix set
+func handleTileMatrixSet(w http.ResponseWriter, r *http.Request) *appError {
+	id := getRequestVar("tms", r)
+	tms := data.TileMatrixSetByID(id)
+	if tms == nil {
+		return appErrorNotFoundFmt(nil, "Tile matrix set not found: %v", id)
+	}
+	return writeJSON(w, ContentTypeJSON, tms)
+}
+
+// handleCollectionTileSets returns the list of tilesets available for a collection
+func handleCollectionTileSets(w http.ResponseWriter, r *http.Request) *appError {
+	layer := getRequestVar("layer", r)
+	log.Debugf("Tilesets request: %s", layer)
+	if appErr := checkCollectionLayers(layer); appErr != nil {
+		return appErr
+	}
+
+	urlBase := serveURLBase(r)
+	content := &TileSetsInfo{
+		Links: []*Link{
+			{Href: urlPath(urlBase, collectionTilesPath(layer)), Rel: RelSelf, Type: ContentTypeJSON, Title: "This document"},
+		},
+	}
+	for _, tms := range data.TileMatrixSets() {
+		tileSet, appErr := newTileSet(layer, tms, urlBase, false)
+		if appErr != nil {
+			return appErr
+		}
+		if tileSet == nil {
+			continue
+		}
+		// the list only contains a summary of each tileset
+		tileSet.Layers = nil
+		content.TileSets = append(content.TileSets, tileSet)
+	}
+	return writeJSON(w, ContentTypeJSON, content)
+}
+
+// handleCollectionTileSet returns the metadata of the tileset of a collection in a tile matrix set
+func handleCollectionTileSet(w http.ResponseWriter, r *http.Request) *appError {
+	layer := getRequestVar("layer", r)
+	id := getRequestVar("tms", r)
+	log.Debugf("Tileset request: %s/%s", layer, id)
+	if appErr := checkCollectionLayers(layer); appErr != nil {
+		return appErr
+	}
+
+	tms := data.TileMatrixSetByID(id)
+	if tms == nil {
+		return appErrorNotFoundFmt(nil, "Tile matrix set not found: %v", id)
+	}
+	tileSet, appErr := newTileSet(layer, tms, serveURLBase(r), true)
+	if appErr != nil {
+		return appErr
+	}
+	if tileSet == nil {
+		return appErrorNotFoundFmt(nil, "Tileset not found: %v", layer+"/"+id)
+	}
+	return writeJSON(w, ContentTypeJSON, tileSet)
+}
+
+// collectionTilesHandler serves the tiles of collections with a tile handler
+func collectionTilesHandler(tiles http.Handler) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) *appError {
+		if appErr := checkCollectionLayers(getRequestVar("layer", r)); appErr != nil {
+			return appErr
+		}
+		tiles.ServeHTTP(w, r)
+		return nil
+	}
+}
+
+// checkCollectionLayers checks that the layers of a collection tiles request are collections.
+// Only tables are collections: the other layers (query, file and archive layers)
+// are served by the /tiles endpoints.
+func checkCollectionLayers(layer string) *appError {
+	for _, name := range data.SplitLayerNames(layer) {
+		tbl, err := catalogInstance.TableByName(name)
+		if err != nil {
+			return appErrorInternal(err, fmt.Sprintf("Error retrieving collection: %v", err))
+		}
+		if tbl == nil {
+			return appErrorNotFoundFmt(nil, "Collection not found: %v", name)
+		}
+	}
+	return nil
+}
+
+// newTileSet creates the tileset metadata for a (possibly composite) layer in a tile matrix set.
+// The tile matrix set limits are only included if withLimits is set.
+// It returns nil if a layer can't be served in the tile matrix set.
+func newTileSet(layer string, tms *data.TileMatrixSet, urlBase string, withLimits bool) (*TileSet, *appError) {
+	catDB, ok := catalogInstance.(*data.CatalogDB)
+	if !ok {
+		return nil, appErrorInternal(nil, "Invalid catalog type")
+	}
+	layerNames := data.SplitLayerNames(layer)
+	if len(layerNames) == 0 {
+		return nil, appErrorBadRequest(nil, fmt.Sprintf("Invalid layer: %s", layer))
+	}
+
+	tilesetPath := collectionTilesPath(layer) + "/" + tms.ID
+	tileSet := &TileSet{
+		Title:            layer,
+		DataType:         "vector",
+		Crs:              tms.Crs,
+		TileMatrixSetURI: tms.URI,
+		Links: []*Link{
+			{Href: urlPath(urlBase, tilesetPath), Rel: RelSelf, Type: ContentTypeJSON, Title: "This document"},
+			{Href: urlPath(urlBase, "tileMatrixSets/"+tms.ID), Rel: RelTilingScheme, Type: ContentTypeJSON, Title: tms.Title},
+			{Href: urlPath(urlBase, tilesetPath+"/{tileMatrix}/{tileRow}/{tileCol}"), Rel: RelItem, Type: ContentTypeMVT, Title: "Tiles", Templated: true},
+		},
+	}
+
+	minZoom, maxZoom := -1, -1
+	for _, name := range layerNames {
+		lyr, err := catDB.GetLayerByName(name)
+		if err != nil {
+			if errors.Is(err, data.ErrLayerNotFound) {
+				return nil, appErrorNotFoundFmt(err, "Layer not found: %v", name)
+			}
+			return nil, appErrorInternal(err, fmt.Sprintf("Error retrieving layer: %v", err))
+		}
+		if !lyr.ServesTileMatrixSet(tms) {
+			return nil, nil
+		}
+		if len(layerNames) == 1 {
+			if lyr.Title != "" {
+				tileSet.Title = lyr.Title
+			}
+			tileSet.Description = lyr.Description
+		}
+		tileSet.Layers = append(tileSet.Layers, &TileSetLayer{
+			ID:            lyr.Name,
+			Title:         lyr.Title,
+			Description:   lyr.Description,
+			DataType:      "vector",
+			MinTileMatrix: strconv.Itoa(lyr.MinZoom),
+			MaxTileMatrix: strconv.Itoa(lyr.MaxZoom),
+		})
+		if minZoom < 0 || lyr.MinZoom < minZoom {
+			minZoom = lyr.MinZoom
+		}
+		if lyr.MaxZoom > maxZoom {
+			maxZoom = lyr.MaxZoom
+		}
+	}
+
+	// the limits require the bounds of the layers (in Web Mercator),
+	// which are computed once with a full scan of table layers
+	if !withLimits || tms.Srid != data.SRID_3857 {
+		return tileSet, nil
+	}
+	if bounds := catDB.LayersBounds(layerNames); bounds != nil {
+		for z := minZoom; z <= maxZoom; z++ {
+			if limits := tms.TileLimits(z, bounds); limits != nil {
+				tileSet.TileMatrixSetLimits = append(tileSet.TileMatrixSetLimits, limits)
+			}
+		}
+	}
+	return tileSet, nil
+}
+
+// collectionTilesPath returns the path of the tilesets of a collection
+func collectionTilesPath(layer string) string {
+	return "collections/" + url.PathEscape(layer) + "/tiles"
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -270,6 +272,23 @@ func writeJSON(w http.ResponseWriter, contype string, content interface{}) *appE
 	return nil
 }
 
+// writeConditionalJSON writes a JSON response with an ETag validator,
+// or 304 Not Modified if the request has a matching If-None-Match header
+func writeConditionalJSON(w http.ResponseWriter, r *http.Request, contype string, content interface{}) *appError {
+	encodedContent, err := json.Marshal(content)
+	if err != nil {
+		log.Printf("JSON encoding error: %v", err.Error())
+		return appErrorInternal(err, "Error encoding JSON")
+	}
+	etag := contentETag(encodedContent)
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	return writeResponse(w, contype, encodedContent)
+}
+
 func writeText(w http.ResponseWriter, contype string, encodedContent []byte) *appError {
 	//fmt.Println(string(encodedContent))
 	writeResponse(w, contype, encodedContent)
@@ -303,6 +322,28 @@ func writeResponse(w http.ResponseWriter, contype string, encodedContent []byte)
 	return nil
 }
 
+// contentETag returns the entity tag of a response content (a hash of the content).
+// The tag is weak, since the response may be sent compressed or not.
+func contentETag(content []byte) string {
+	hash := sha256.Sum256(content)
+	return `W/"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// notModified tests whether the If-None-Match header of a request matches an entity tag
+// (using the weak comparison required for If-None-Match)
+func notModified(r *http.Request, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, header := range r.Header.Values("If-None-Match") {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Sets response 'status', and writes a json-encoded object with property "description" having value "msg".
 //
 //nolint:all
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pborman/getopt/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/tobilg/duckdb-tileserver/internal/conf"
+	"github.com/tobilg/duckdb-tileserver/internal/data"
+	"github.com/tobilg/duckdb-tileserver/internal/service"
+)
+
+// runSeed runs the seed command, which generates the tiles of a layer
+// into the disk cache tier, so that they are served from the cache once the server starts.
+// Usage: ./duckdb-tileserver seed --layer roads --minzoom 0 --maxzoom 8 --bbox ...
+func runSeed(args []string) {
+	var layer, bbox string
+	var params []string
+	minZoom, maxZoom, workers := -1, -1, 0
+	var help bool
+
+	set := getopt.New()
+	set.SetProgram(conf.AppConfig.Name + " seed")
+	set.SetParameters("")
+	set.FlagLong(&help, "help", '?', "Show command usage")
+	set.FlagLong(&flagConfigFilename, "config", 'c', "", "config file name")
+	set.FlagLong(&flagDebugOn, "debug", 'd', "Set logging level to TRACE")
+	set.FlagLong(&flagDuckDBPath, "database-path", 0, "", "Path to DuckDB database file")
+	set.FlagLong(&layer, "layer", 'l', "Layer to seed (comma-separated layers for composite tiles)", "name")
+	set.FlagLong(&minZoom, "minzoom", 0, "Minimum zoom level (default: layer minimum zoom)", "z")
+	set.FlagLong(&maxZoom, "maxzoom", 0, "Maximum zoom level (default: layer maximum zoom)", "z")
+	set.FlagLong(&bbox, "bbox", 0, "Area to seed in EPSG:4326 (default: layer bounds)", "minx,miny,maxx,maxy")
+	set.FlagLong(&params, "param", 'p', "Layer parameter value (repeatable)", "name=value")
+	set.FlagLong(&workers, "workers", 0, "Number of tiles generated concurrently (default: number of CPUs)", "n")
+	set.Parse(args)
+
+	if help {
+		set.PrintUsage(os.Stderr)
+		os.Exit(1)
+	}
+	if layer == "" {
+		fmt.Fprintln(os.Stderr, "--layer is required")
+		set.PrintUsage(os.Stderr)
+		os.Exit(1)
+	}
+
+	layerParams, err := parseParams(params)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := data.GenerateOptions{
+		Layer:   layer,
+		MinZoom: minZoom,
+		MaxZoom: maxZoom,
+		Params:  layerParams,
+		Workers: workers,
+	}
+	if bbox != "" {
+		bounds, err := parseBbox(bbox)
+		if err != nil {
+			log.Fatalf("Invalid bbox: %v", err)
+		}
+		opts.Bounds = bounds
+	}
+
+	initConfig()
+	initLogging()
+
+	catalog := data.CatDBInstance()
+	catalog.SetIncludeExclude(conf.Configuration.Database.TableIncludes, conf.Configuration.Database.TableExcludes)
+	catDB := catalog.(*data.CatalogDB)
+
+	// interrupting the seed keeps the tiles seeded so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	status, err := service.Seed(ctx, catDB, opts)
+	catDB.Close()
+	if err != nil {
+		log.Fatalf("Seeding failed: %v", err)
+	}
+	log.Infof("Seeded %d/%d tiles into %s (%s)", status.Done, status.Total, conf.Configuration.Cache.DiskPath, status.Status)
+}
@@ -0,0 +1,26 @@
+-- Sample GeoParquet files for DuckDB Tileserver file-backed layers
+-- Run after sample_data.sql against the same database:
+--   mkdir -p data/poi
+--   duckdb tiles.db < testing/sample_geoparquet.sql
+-- and declare the layers in the config file:
+--   [[Layers]]
+--   Name = "poi_files"
+--   Source = "data/poi/*.parquet"
+--
+--   [[Layers]]
+--   Name = "parcels_file"
+--   Source = "data/parcels.parquet"
+
+LOAD spatial;
+
+-- GeoParquet files split by POI type (read with a glob)
+COPY (SELECT * FROM poi WHERE type IN ('restaurant', 'cafe')) TO 'data/poi/part-0.parquet' (FORMAT PARQUET);
+COPY (SELECT * FROM poi WHERE type NOT IN ('restaurant', 'cafe')) TO 'data/poi/part-1.parquet' (FORMAT PARQUET);
+
+-- A single GeoParquet file
+COPY parcels TO 'data/parcels.parquet' (FORMAT PARQUET);
+
+-- Show the file-backed data
+SELECT 'poi_files' AS layer, count(*) AS count FROM read_parquet('data/poi/*.parquet')
+UNION ALL
+SELECT 'parcels_file', count(*) FROM read_parquet('data/parcels.parquet');